@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// noAIPrompt is --no-ai-prompt: describe AI-generated images like any
+// other rather than naming them after the prompt they carry.
+var noAIPrompt bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noAIPrompt, "no-ai-prompt", false, "don't name AI-generated images after the prompt saved in them; describe them like any other image")
+}
+
+// aiGeneration is what an AI-generated image records about how it was
+// made.
+type aiGeneration struct {
+	Generator string
+	Prompt    string
+}
+
+// aiGenerationOf reads the prompt an image generator saved in the image
+// at path: Stable Diffusion web UIs, ComfyUI, InvokeAI, NovelAI and
+// Midjourney in PNG text chunks or EXIF, and DALL·E in the names of its
+// downloads. Images with a C2PA manifest that only says they were
+// generated come back with no prompt.
+func aiGenerationOf(path string) (aiGeneration, bool) {
+	if !isImageFile(path) {
+		return aiGeneration{}, false
+	}
+	if g, ok := dallePrompt(path); ok {
+		return g, true
+	}
+	text := pngText(path)
+	if len(text) == 0 {
+		if comment := exifUserComment(path); comment != "" {
+			text["parameters"] = comment
+		}
+	}
+	if p := text["parameters"]; p != "" {
+		return aiGeneration{Generator: "Stable Diffusion", Prompt: sdPrompt(p)}, true
+	}
+	if p := text["invokeai_metadata"]; p != "" {
+		var meta struct {
+			PositivePrompt string `json:"positive_prompt"`
+		}
+		if json.Unmarshal([]byte(p), &meta) == nil && meta.PositivePrompt != "" {
+			return aiGeneration{Generator: "InvokeAI", Prompt: meta.PositivePrompt}, true
+		}
+	}
+	if p := text["prompt"]; p != "" {
+		if prompt := comfyPrompt(p); prompt != "" {
+			return aiGeneration{Generator: "ComfyUI", Prompt: prompt}, true
+		}
+	}
+	if d := text["description"]; d != "" {
+		switch {
+		case strings.Contains(text["software"], "NovelAI"):
+			return aiGeneration{Generator: "NovelAI", Prompt: d}, true
+		case strings.Contains(d, "Job ID:"):
+			prompt, _, _ := strings.Cut(d, "Job ID:")
+			return aiGeneration{Generator: "Midjourney", Prompt: strings.TrimSpace(prompt)}, true
+		}
+	}
+	if generator := c2paGenerator(path); generator != "" {
+		return aiGeneration{Generator: generator}, true
+	}
+	return aiGeneration{}, false
+}
+
+// dalleNamePattern matches the names of images downloaded from DALL·E, which
+// end with the prompt:
+//
+//	DALL·E 2023-10-01 12.34.56 - A cat in a space suit, digital art.png
+var dalleNamePattern = regexp.MustCompile(`^DALL[·.-]?E(?: \d)? \d{4}-\d{2}-\d{2} \d{2}\.\d{2}\.\d{2} - (.+)$`)
+
+func dallePrompt(path string) (aiGeneration, bool) {
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if m := dalleNamePattern.FindStringSubmatch(stem); m != nil {
+		return aiGeneration{Generator: "DALL·E", Prompt: m[1]}, true
+	}
+	return aiGeneration{}, false
+}
+
+// sdPrompt takes the prompt from the parameters the Stable Diffusion web
+// UIs write: the prompt, then the negative prompt and the settings on
+// lines of their own.
+func sdPrompt(parameters string) string {
+	prompt := parameters
+	for _, marker := range []string{"\nNegative prompt:", "\nSteps:"} {
+		prompt, _, _ = strings.Cut(prompt, marker)
+	}
+	return strings.TrimSpace(prompt)
+}
+
+// comfyPrompt finds the positive prompt in a ComfyUI workflow: the text of
+// the node a sampler takes as "positive", or failing that the first text
+// encoder's.
+func comfyPrompt(workflow string) string {
+	var nodes map[string]struct {
+		ClassType string                     `json:"class_type"`
+		Inputs    map[string]json.RawMessage `json:"inputs"`
+	}
+	if json.Unmarshal([]byte(workflow), &nodes) != nil {
+		return ""
+	}
+	text := func(id string) string {
+		var s string
+		if n, ok := nodes[id]; ok && json.Unmarshal(n.Inputs["text"], &s) == nil {
+			return s
+		}
+		return ""
+	}
+	ids := slices.Sorted(maps.Keys(nodes))
+	for _, id := range ids {
+		var link []any
+		n := nodes[id]
+		if json.Unmarshal(n.Inputs["positive"], &link) == nil && len(link) > 0 {
+			if id, ok := link[0].(string); ok && text(id) != "" {
+				return text(id)
+			}
+		}
+	}
+	for _, id := range ids {
+		if strings.HasPrefix(nodes[id].ClassType, "CLIPTextEncode") && text(id) != "" {
+			return text(id)
+		}
+	}
+	return ""
+}
+
+// exifUserComment is the EXIF UserComment of a JPEG, where the Stable
+// Diffusion web UIs keep their parameters in images they save as JPEG.
+func exifUserComment(path string) string {
+	x, err := readEXIF(path)
+	if err != nil {
+		return ""
+	}
+	tag, err := x.Get(exif.UserComment)
+	if err != nil || len(tag.Val) < 8 {
+		return ""
+	}
+	charset, data := string(bytes.TrimRight(tag.Val[:8], "\x00 ")), tag.Val[8:]
+	if charset != "UNICODE" {
+		return strings.TrimRight(string(data), "\x00 ")
+	}
+	// UTF-16, big-endian unless the first character says otherwise.
+	order := binary.ByteOrder(binary.BigEndian)
+	if len(data) >= 2 && data[0] != 0 && data[1] == 0 {
+		order = binary.LittleEndian
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00 ")
+}
+
+// c2paScanLimit is how far into a file a C2PA manifest is looked for; it
+// comes before the image data.
+const c2paScanLimit = 2 << 20
+
+// c2paGenerators are the tools whose C2PA manifests are recognised, by
+// how they name themselves in it.
+var c2paGenerators = []struct{ marker, name string }{
+	{"DALL", "DALL·E"},
+	{"ChatGPT", "ChatGPT"},
+	{"OpenAI", "OpenAI"},
+	{"Firefly", "Adobe Firefly"},
+	{"Bing Image Creator", "Bing Image Creator"},
+	{"Microsoft Designer", "Microsoft Designer"},
+	{"Imagen", "Imagen"},
+}
+
+// c2paGenerator names the generator of an image whose C2PA manifest says
+// it was made by a trained model, or returns "" for anything else.
+func c2paGenerator(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head, _ := io.ReadAll(io.LimitReader(f, c2paScanLimit))
+	if !bytes.Contains(head, []byte("c2pa")) || !bytes.Contains(head, []byte("trainedAlgorithmicMedia")) {
+		return ""
+	}
+	for _, g := range c2paGenerators {
+		if bytes.Contains(head, []byte(g.marker)) {
+			return g.name
+		}
+	}
+	return "an AI image generator"
+}
+
+// promptNoise are the words prompts are padded with to steer the model,
+// which say nothing about what the image shows.
+var promptNoise = map[string]bool{
+	"masterpiece": true, "best quality": true, "high quality": true, "highres": true,
+	"absurdres": true, "ultra detailed": true, "highly detailed": true, "extremely detailed": true,
+	"detailed": true, "4k": true, "8k": true, "uhd": true, "hdr": true, "photorealistic": true,
+	"realistic": true, "ultra realistic": true, "hyperrealistic": true, "sharp focus": true,
+	"trending on artstation": true, "award winning": true, "digital art": true, "cinematic": true,
+	"cinematic lighting": true, "octane render": true, "unreal engine": true, "intricate": true,
+	"score_9": true, "score_8_up": true, "score_7_up": true,
+}
+
+var (
+	// The web UIs' syntax for LoRAs, <lora:name:0.8>, and for weights,
+	// (word:1.2) and [word].
+	promptLoRAPattern   = regexp.MustCompile(`<[^<>]*>`)
+	promptWeightPattern = regexp.MustCompile(`:\s*[\d.]+\s*\)|[()\[\]{}]`)
+	// Midjourney puts its parameters after the prompt: --ar 16:9 --v 6.
+	promptParamPattern = regexp.MustCompile(`\s--\w+(\s+[^-\s]\S*)*`)
+	promptURLPattern   = regexp.MustCompile(`https?://\S+`)
+)
+
+// promptSubject is what a prompt says the image is of: its first
+// phrases, without links, weights, parameters and quality boilerplate.
+func promptSubject(prompt string) string {
+	prompt = promptURLPattern.ReplaceAllString(prompt, "")
+	prompt = promptLoRAPattern.ReplaceAllString(prompt, "")
+	prompt = promptParamPattern.ReplaceAllString(prompt, "")
+	prompt = promptWeightPattern.ReplaceAllString(prompt, "")
+	var phrases []string
+	for _, p := range strings.FieldsFunc(prompt, func(r rune) bool { return r == ',' || r == '\n' || r == '|' }) {
+		p = strings.TrimSpace(p)
+		if p != "" && !promptNoise[strings.ToLower(p)] {
+			phrases = append(phrases, p)
+		}
+	}
+	return strings.Join(phrases, ", ")
+}
+
+// suggestFromPrompt names an AI-generated image after the prompt g it was
+// made from, without a request. It returns false when the prompt is all
+// boilerplate, which leaves the image to be described.
+func suggestFromPrompt(path string, g aiGeneration) (suggestion, bool) {
+	name := slugify(promptSubject(g.Prompt), 40)
+	if name == "" {
+		return suggestion{}, false
+	}
+	if namingMode != "seo" {
+		name = strings.ReplaceAll(name, "-", "_")
+	}
+	s := suggestion{
+		path:        path,
+		description: "An image generated with " + g.Generator + " from the prompt: " + strings.TrimSpace(g.Prompt),
+		name:        name,
+		// The name comes from what the image was asked to show.
+		confidence: 1,
+		rated:      true,
+	}
+	s.markGenerated()
+	traceNaming(path, name)
+	return s, true
+}
+
+// markGenerated tags s as AI-generated and files it under ai-art, whatever
+// the model made of it.
+func (s *suggestion) markGenerated() {
+	if !slices.Contains(s.tags, "ai-generated") {
+		s.tags = append(s.tags, "ai-generated")
+	}
+	s.category = "ai-art"
+}
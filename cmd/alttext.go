@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	altFormat    string
+	altMaxLength int
+)
+
+type altText struct {
+	Path string `json:"path"`
+	Alt  string `json:"alt"`
+}
+
+var altTextCmd = &cobra.Command{
+	Use:   "alt-text <dir or image>...",
+	Short: "Write accessibility alt text for web images",
+	Long: `Describes each image the way alt text should: what it shows and why it matters,
+within --max-length characters and without "image of" style openers. Files are
+left untouched; the result is printed as JSON or as <img> tags.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if altFormat != "json" && altFormat != "html" {
+			return fmt.Errorf("unknown --format %q (want json or html)", altFormat)
+		}
+		if altMaxLength < 2 {
+			return fmt.Errorf("--max-length must be at least 2, got %d", altMaxLength)
+		}
+		var images []string
+		for _, arg := range args {
+			if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+				images = append(images, arg)
+				continue
+			}
+			found, err := folderImages(arg)
+			if err != nil {
+				return err
+			}
+			images = append(images, found...)
+		}
+
+		results := []altText{}
+		for _, path := range images {
+			alt, err := getAltText(path)
+			if err != nil {
+				log.Printf("Error writing alt text for %s: %v", path, err)
+				continue
+			}
+			results = append(results, altText{Path: filepath.ToSlash(path), Alt: alt})
+		}
+
+		if altFormat == "html" {
+			for _, r := range results {
+				fmt.Printf("<img src=\"%s\" alt=\"%s\">\n", html.EscapeString(r.Path), html.EscapeString(r.Alt))
+			}
+			return nil
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	},
+}
+
+func init() {
+	altTextCmd.Flags().StringVar(&altFormat, "format", "json", "output format: json or html")
+	altTextCmd.Flags().IntVar(&altMaxLength, "max-length", 125, "maximum alt text length in characters")
+	rootCmd.AddCommand(altTextCmd)
+}
+
+func getAltText(path string) (string, error) {
+	prompt := fmt.Sprintf(`Write alt text for this image as it would appear on a website, for people using a screen reader.
+
+Describe what the image shows and, if it contains text that matters, include that text. Be concrete and concise. Do not start with "Image of", "Picture of", "Photo of" or similar, and do not mention that it is an image unless the medium matters (a chart, a screenshot, an illustration). Reply with the alt text only, in at most %d characters.`, altMaxLength)
+	alt, err := askGeminiAboutFile(path, prompt+langPrompt())
+	if err != nil {
+		return "", err
+	}
+	return cleanAltText(alt, altMaxLength), nil
+}
+
+var altPrefixPattern = regexp.MustCompile(`(?i)^(alt text:\s*)?((an?|the)\s+)?(image|picture|photo|photograph)\s+(of|showing|shows)\s+`)
+
+// cleanAltText removes "image of" openers and quoting the model adds
+// despite being asked not to, and enforces the length limit.
+func cleanAltText(alt string, max int) string {
+	alt = strings.TrimSpace(strings.SplitN(strings.TrimSpace(alt), "\n", 2)[0])
+	alt = strings.Trim(alt, "\"'`")
+	alt = altPrefixPattern.ReplaceAllString(alt, "")
+	if first, size := utf8.DecodeRuneInString(alt); size > 0 {
+		alt = string(unicode.ToUpper(first)) + alt[size:]
+	}
+	if runes := []rune(alt); len(runes) > max {
+		cut := string(runes[:max-1]) // leave room for the ellipsis
+		if i := strings.LastIndex(cut, " "); i > max/2 {
+			cut = cut[:i]
+		}
+		alt = strings.TrimRight(cut, " ,;:") + "…"
+	}
+	return alt
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanAltText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"opener removed", "An image of a cat asleep on a sofa", 125, "A cat asleep on a sofa"},
+		{"quotes and label", `"Alt text: photo showing the team at the offsite"`, 125, "The team at the offsite"},
+		{"only the first line", "A bar chart of sales\nIt rises in May.", 125, "A bar chart of sales"},
+		{"accented opener", "éclair on a plate", 125, "Éclair on a plate"},
+		{"japanese is left as it is", "東京の夜景", 125, "東京の夜景"},
+		{"cut at a word", "A cat asleep on a sofa in the sun", 20, "A cat asleep on a…"},
+		{"cut inside a long word", "Donaudampfschifffahrt", 10, "Donaudamp…"},
+		{"cut between characters", "ÄÖÜäöüßÄÖÜ", 5, "ÄÖÜä…"},
+		{"shortest limit", "A cat", 2, "A…"},
+		{"empty", "  ", 125, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cleanAltText(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("cleanAltText(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("cleanAltText(%q, %d) = %q is not valid UTF-8", tt.in, tt.max, got)
+			}
+		})
+	}
+}
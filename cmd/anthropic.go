@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const anthropicMessagesURL = "https://api.anthropic.com/v1/messages"
+
+var (
+	anthropicModel = "claude-3-5-sonnet-20241022"
+	anthropicKeys  = &keyPool{provider: "anthropic", env: "ANTHROPIC_API_KEY"}
+)
+
+func init() {
+	registerProvider("anthropic", anthropic{})
+	registerModel("anthropic", &anthropicModel)
+}
+
+// anthropic has Claude both describe the image and name it, and answers
+// every other prompt too, so no OpenAI or Gemini key is needed for images.
+type anthropic struct{}
+
+func (a anthropic) Describe(ctx context.Context, imagePath string) (string, error) {
+	return a.AskAboutFile(ctx, imagePath, describePrompt())
+}
+
+func (a anthropic) SuggestName(ctx context.Context, description string) (string, error) {
+	return a.Ask(ctx, namingPrompt(description))
+}
+
+func (anthropic) Ask(ctx context.Context, prompt string) (string, error) {
+	return askClaude(ctx, nil, "", prompt)
+}
+
+func (anthropic) AskAboutImage(ctx context.Context, data []byte, mimeType, prompt string) (string, error) {
+	return askClaude(ctx, data, mimeType, prompt)
+}
+
+func (a anthropic) AskAboutFile(ctx context.Context, path, prompt string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading image file: %v", err)
+	}
+	return a.AskAboutImage(ctx, data, imageMIMEType(path), prompt)
+}
+
+// imageMIMEType guesses an image's media type from its extension.
+func imageMIMEType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if mt := mime.TypeByExtension(ext); mt != "" {
+		return mt
+	}
+	return "image/" + strings.TrimPrefix(ext, ".")
+}
+
+// askClaude sends prompt, with the image in data if there is one, as a
+// single user message.
+func askClaude(ctx context.Context, data []byte, mimeType, prompt string) (string, error) {
+	content := ""
+	key := cacheKey("anthropic", anthropicModel, prompt)
+	if data != nil {
+		sum := sha256.Sum256(data)
+		content = hex.EncodeToString(sum[:])
+		key = cacheKey("anthropic", content, anthropicModel, prompt, uploadVariant())
+	}
+	return cachedAnswer(key, content, prompt, func() (string, string, error) {
+		reply, err := withKey(anthropicKeys, func(apiKey string) (string, error) {
+			return askClaudeWithKey(ctx, apiKey, data, mimeType, prompt)
+		})
+		return reply, anthropicModel, err
+	})
+}
+
+type claudeContent struct {
+	Type   string        `json:"type"`
+	Text   string        `json:"text,omitempty"`
+	Source *claudeSource `json:"source,omitempty"`
+}
+
+type claudeSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type claudeMessage struct {
+	Role    string          `json:"role"`
+	Content []claudeContent `json:"content"`
+}
+
+type claudeRequest struct {
+	Model     string          `json:"model"`
+	MaxTokens int             `json:"max_tokens"`
+	Messages  []claudeMessage `json:"messages"`
+}
+
+type claudeResponse struct {
+	Model   string          `json:"model"`
+	Content []claudeContent `json:"content"`
+	Usage   struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func askClaudeWithKey(ctx context.Context, apiKey string, data []byte, mimeType, prompt string) (string, error) {
+	if apiKey == "" {
+		return "", fmt.Errorf("Anthropic API key not set")
+	}
+	var parts []claudeContent
+	if data != nil {
+		data, mimeType, err := fitImageBytes(data, mimeType)
+		if err != nil {
+			return "", err
+		}
+		uploadLimiter.wait(len(data))
+		parts = append(parts, claudeContent{Type: "image", Source: &claudeSource{
+			Type:      "base64",
+			MediaType: mimeType,
+			Data:      base64.StdEncoding.EncodeToString(data),
+		}})
+	}
+	parts = append(parts, claudeContent{Type: "text", Text: prompt})
+
+	body, err := json.Marshal(claudeRequest{
+		Model:     anthropicModel,
+		MaxTokens: 1024,
+		Messages:  []claudeMessage{{Role: "user", Content: parts}},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, anthropicMessagesURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", apiKey)
+	req.Header.Set("Anthropic-Version", "2023-06-01")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Claude API error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("Claude API error: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var result claudeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding Claude response: %v", err)
+	}
+	var reply strings.Builder
+	for _, c := range result.Content {
+		if c.Type == "text" {
+			reply.WriteString(c.Text)
+		}
+	}
+	if reply.Len() == 0 {
+		return "", fmt.Errorf("no response from Claude API")
+	}
+	text := strings.TrimSpace(reply.String())
+	rememberReply(text, result.Model, prompt)
+	recordUsage(text, result.Model, result.Usage.InputTokens, result.Usage.OutputTokens)
+	return text, nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// zipListLimit caps how many entries are shown to the model.
+	zipListLimit = 150
+	// zipImageLimit is the largest contained image we'll read into memory
+	// to describe. It is sent inline with the request, so it is held to
+	// the inline limit.
+	zipImageLimit = inlineImageLimit
+)
+
+var zipImages int
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&zipImages, "zip-images", 0, "also describe up to this many images inside each zip archive")
+}
+
+func isZipFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// describeZip lists an archive's contents, and optionally describes a few
+// of the images in it, without extracting anything to disk.
+func describeZip(zipPath string) (string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	var files []*zip.File
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() && !strings.HasPrefix(f.Name, "__MACOSX/") {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("%s is empty", zipPath)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "A zip archive containing %d files:\n", len(files))
+	for i, f := range files {
+		if i == zipListLimit {
+			fmt.Fprintf(&b, "... and %d more\n", len(files)-zipListLimit)
+			break
+		}
+		fmt.Fprintf(&b, "%s (%d bytes)\n", f.Name, f.UncompressedSize64)
+	}
+
+	for i, f := range largestImages(files, zipImages) {
+		description, err := describeZipEntry(f)
+		if err != nil {
+			log.Printf("Error describing %s in %s: %v", f.Name, zipPath, err)
+			continue
+		}
+		fmt.Fprintf(&b, "\nDescription of contained image %d (%s):\n%s\n", i+1, f.Name, description)
+	}
+	return b.String(), nil
+}
+
+// largestImages picks the n biggest images that are small enough to send,
+// on the theory that thumbnails and icons say least about an archive.
+func largestImages(files []*zip.File, n int) []*zip.File {
+	var images []*zip.File
+	for _, f := range files {
+		if isImageFile(f.Name) && f.UncompressedSize64 <= zipImageLimit {
+			images = append(images, f)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].UncompressedSize64 > images[j].UncompressedSize64
+	})
+	if len(images) > n {
+		images = images[:n]
+	}
+	return images
+}
+
+func describeZipEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, zipImageLimit))
+	if err != nil {
+		return "", err
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(path.Ext(f.Name)))
+	if mimeType == "" {
+		mimeType = "image/" + strings.TrimPrefix(strings.ToLower(path.Ext(f.Name)), ".")
+	}
+	return describeImageBytes(data, mimeType)
+}
+
+func getNameForArchive(listing string) (string, error) {
+	prompt := fmt.Sprintf(`You are a creative assistant that generates human-like filenames for zip archives.
+
+%s
+Work out what the archive is (a project, a photo set, a document bundle, an installer) and suggest a short, descriptive, and human-friendly filename for it (without file extension), for example 'tax_documents_2023' or 'wedding_photos_selection'.
+
+Make sure the name suggestion is under 40 characters, the fewer words the better:`, listing)
+	return askChatGPT(prompt)
+}
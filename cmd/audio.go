@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// whisperMaxSize is the upload limit of the OpenAI transcription endpoint.
+const whisperMaxSize = 25 << 20
+
+var audioExts = map[string]bool{
+	".m4a":  true,
+	".mp3":  true,
+	".wav":  true,
+	".webm": true,
+	".mpga": true,
+	".mpeg": true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true, // WhatsApp voice notes
+}
+
+func isAudioFile(path string) bool {
+	return audioExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// transcribeAudio runs a voice memo through Whisper, the API or a local
+// one as --whisper says, and returns the text.
+func transcribeAudio(path string) (string, error) {
+	if transcribesLocally() {
+		ask := func() (string, string, error) {
+			text, err := transcribeLocally(path)
+			return text, "whisper-" + whisperModel, err
+		}
+		content, err := fileSHA256(path)
+		if err != nil {
+			text, _, err := ask()
+			return text, err
+		}
+		return cachedAnswer(cacheKey("whisper-local", content, whisperModel, strings.Join(textLangs, ",")), content, "", ask)
+	}
+	ask := func() (string, string, error) {
+		text, err := withKey(openaiKeys, func(openaiAPIKey string) (string, error) {
+			return transcribeAudioWithKey(openaiAPIKey, path)
+		})
+		return text, openai.Whisper1, err
+	}
+	content, err := fileSHA256(path)
+	if err != nil {
+		text, _, err := ask()
+		return text, err
+	}
+	return cachedAnswer(cacheKey("whisper", content, openai.Whisper1, strings.Join(textLangs, ",")), content, "", ask)
+}
+
+func transcribeAudioWithKey(openaiAPIKey, path string) (string, error) {
+	if openaiAPIKey == "" {
+		return "", fmt.Errorf("OpenAI API key not set")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > whisperMaxSize {
+		return "", fmt.Errorf("%s is %d MB, over the %d MB transcription limit", path, info.Size()>>20, whisperMaxSize>>20)
+	}
+
+	upload, cleanup, err := prepareUpload(path)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	f, err := os.Open(upload)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	req := openai.AudioRequest{
+		Model:    openai.Whisper1,
+		FilePath: path,
+		Reader:   throttled(f),
+	}
+	// Opus files are Ogg, but the API goes by the extension.
+	if strings.EqualFold(filepath.Ext(path), ".opus") {
+		req.FilePath = strings.TrimSuffix(path, filepath.Ext(path)) + ".ogg"
+	}
+	req.Language = whisperLanguage()
+
+	client := openaiClient(openaiAPIKey)
+	resp, err := client.CreateTranscription(runCtx, req)
+	if err != nil {
+		return "", fmt.Errorf("Whisper API error: %v", err)
+	}
+	text := strings.TrimSpace(resp.Text)
+	if text == "" {
+		return "", fmt.Errorf("no speech found in %s", path)
+	}
+	return text, nil
+}
+
+func getNameFromTranscript(transcript string) (string, error) {
+	prompt := fmt.Sprintf(`You are a creative assistant that generates human-like filenames for voice memos.
+
+Here is the transcript of the recording:
+%s
+
+Work out what the memo is about and suggest a short, descriptive, and human-friendly filename for it (without file extension), naming the topic rather than summarising every detail. For example a memo rambling about watering the vegetable patch automatically would be 'idea_for_garden_irrigation_timer'.
+
+Make sure the name suggestion is under 40 characters, the fewer words the better:`, transcript)
+	return askChatGPT(prompt)
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// auditFile is append-only: entries are never rewritten, and each one
+// carries the hash of the one before it, so editing or dropping an entry
+// breaks the chain from that point on.
+const auditFile = "audit.log"
+
+// namingTrace is what named a file: the model and a hash of the prompt it
+// was sent, so the prompt itself (and the description in it) stays out of
+// the log.
+type namingTrace struct {
+	Model      string `json:"model,omitempty"`
+	PromptHash string `json:"prompt_hash,omitempty"`
+}
+
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	Host string    `json:"host"`
+	Op   string    `json:"op"`
+	Old  string    `json:"old"`
+	New  string    `json:"new"`
+	namingTrace
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+var (
+	auditMu sync.Mutex
+
+	// replyTraces maps a model reply to the request that produced it, and
+	// namingTraces a file to the trace of the name suggested for it.
+	replyTraces  sync.Map
+	namingTraces sync.Map
+)
+
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// rememberReply notes that reply came from sending prompt to model.
+func rememberReply(reply, model, prompt string) {
+	replyTraces.Store(reply, namingTrace{Model: model, PromptHash: promptHash(prompt)})
+}
+
+// traceNaming ties the request that produced name to path. Names that did
+// not come from a model, such as EPUB metadata, get an empty trace.
+func traceNaming(path, name string) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	t, _ := replyTraces.Load(name)
+	trace, _ := t.(namingTrace)
+	namingTraces.Store(key, trace)
+}
+
+// absPath makes local paths in the log unambiguous.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func namingTraceFor(path string) namingTrace {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return namingTrace{}
+	}
+	t, _ := namingTraces.Load(key)
+	trace, _ := t.(namingTrace)
+	return trace
+}
+
+// audit appends op to the audit log, attributing it to whatever named
+// namedPath. A failure is logged but never undoes the operation.
+func audit(op, old, new, namedPath string) {
+	if err := appendAudit(auditEntry{Op: op, Old: old, New: new, namingTrace: namingTraceFor(namedPath)}); err != nil {
+		log.Printf("Error writing audit log: %v", err)
+	}
+}
+
+func appendAudit(e auditEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, auditFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	prev, err := lastAuditHash(f)
+	if err != nil {
+		return err
+	}
+
+	e.Time = time.Now().UTC()
+	if u, err := user.Current(); err == nil {
+		e.User = u.Username
+	}
+	e.Host, _ = os.Hostname()
+	e.Prev = prev
+	if e.Hash, err = auditHash(e); err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// lastAuditHash reads the hash of the final entry, looking only at the end
+// of the file so appending stays cheap however long the log gets.
+func lastAuditHash(f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return "", err
+	}
+	offset := max(info.Size()-64<<10, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return "", err
+	}
+	lines := bytes.Split(bytes.TrimSpace(tail), []byte("\n"))
+	var last auditEntry
+	if err := json.Unmarshal(lines[len(lines)-1], &last); err != nil {
+		return "", fmt.Errorf("last audit entry is unreadable: %v", err)
+	}
+	return last.Hash, nil
+}
+
+// auditHash is the SHA-256 of e with its own hash left out.
+func auditHash(e auditEntry) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the log of every file operation",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that no audit log entry has been changed, removed or reordered",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := dataDir()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Join(dir, auditFile))
+		if os.IsNotExist(err) {
+			fmt.Println("The audit log is empty")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		prev, n := "", 0
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			n++
+			var e auditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				return fmt.Errorf("entry %d is unreadable: %v", n, err)
+			}
+			if e.Prev != prev {
+				return fmt.Errorf("entry %d (%s %s) does not follow the one before it: entries were removed or reordered", n, e.Op, e.Old)
+			}
+			hash, err := auditHash(e)
+			if err != nil {
+				return err
+			}
+			if hash != e.Hash {
+				return fmt.Errorf("entry %d (%s %s) has been modified", n, e.Op, e.Old)
+			}
+			prev = e.Hash
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		fmt.Printf("%d entries, chain intact (last hash %s)\n", n, prev)
+		return nil
+	},
+}
+
+func init() {
+	auditCmd.AddCommand(auditVerifyCmd)
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	batchStateFile = "batch.json"
+	// batchPollInterval is how often a submitted batch is checked on.
+	batchPollInterval = 30 * time.Second
+)
+
+// batchMode is --batch: the naming requests of the whole run go to the
+// OpenAI Batch API, at half the price, before any file is reviewed.
+var batchMode bool
+
+// errQueuedForBatch is what askChatGPT returns for a prompt it has queued
+// instead of sending while a batch is being put together.
+var errQueuedForBatch = errors.New("queued for the batch")
+
+var (
+	batchCollecting atomic.Bool
+	batchMu         sync.Mutex
+	batchPrompts    []string
+	batchQueued     = map[string]bool{}
+)
+
+func init() {
+	rootCmd.Flags().BoolVar(&batchMode, "batch", false, "send all the naming requests through the OpenAI Batch API, which is half the price but can take up to 24 hours, then review as usual")
+}
+
+// pendingBatch is a submitted batch, kept in the state directory until
+// its answers are in so an interrupted run can pick it up again.
+type pendingBatch struct {
+	ID    string `json:"id"`
+	Model string `json:"model"`
+	// Prompts maps each request's custom ID to its prompt.
+	Prompts map[string]string `json:"prompts"`
+}
+
+// queueForBatch adds prompt to the batch being put together, and reports
+// false when there is none.
+func queueForBatch(prompt string) bool {
+	if !batchCollecting.Load() {
+		return false
+	}
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	if !batchQueued[prompt] {
+		batchQueued[prompt] = true
+		batchPrompts = append(batchPrompts, prompt)
+	}
+	return true
+}
+
+// runBatch describes every file under args, sends the naming requests
+// that are not cached yet as one batch and waits for it. The answers go
+// into the cache, where the review that follows finds them.
+func runBatch(args []string) error {
+	if providerName != "gemini-openai" || pipeline != "default" {
+		return fmt.Errorf("--batch needs --provider gemini-openai and --pipeline default")
+	}
+	if noCache {
+		return fmt.Errorf("--batch hands its answers to the review through the cache, so it can't be used with --no-cache")
+	}
+	openaiKeys.load()
+	if len(openaiKeys.keys) == 0 {
+		return fmt.Errorf("OpenAI API key not set")
+	}
+	// A batch can only be looked up with a key of the project that made
+	// it, so it always uses the first one.
+	client := openaiClient(openaiKeys.keys[0])
+
+	var pending pendingBatch
+	if err := loadState(batchStateFile, &pending); err != nil {
+		return err
+	}
+	if pending.ID != "" {
+		fmt.Fprintf(msgOut, "Waiting for batch %s from an earlier run\n", pending.ID)
+		if err := finishBatch(client, pending); err != nil {
+			return err
+		}
+	}
+
+	var local []string
+	for _, arg := range args {
+		if !isRemoteURL(arg) {
+			local = append(local, arg)
+		}
+	}
+	fmt.Fprintln(msgOut, "Describing files for the batch")
+	batchCollecting.Store(true)
+	forEachTarget(local, func(suggestion) {})
+	batchCollecting.Store(false)
+	if runCtx.Err() != nil || len(batchPrompts) == 0 {
+		return nil
+	}
+
+	var upload openai.UploadBatchFileRequest
+	pending = pendingBatch{Model: openaiModel, Prompts: map[string]string{}}
+	for i, prompt := range batchPrompts {
+		id := fmt.Sprintf("name-%d", i)
+		upload.AddChatCompletion(id, chatRequest(prompt))
+		pending.Prompts[id] = prompt
+	}
+	resp, err := client.CreateBatchWithUploadFile(runCtx, openai.CreateBatchWithUploadFileRequest{
+		Endpoint:               openai.BatchEndpointChatCompletions,
+		CompletionWindow:       "24h",
+		UploadBatchFileRequest: upload,
+	})
+	if err != nil {
+		return fmt.Errorf("submitting the batch: %v", err)
+	}
+	pending.ID = resp.ID
+	if err := saveState(batchStateFile, pending); err != nil {
+		return err
+	}
+	fmt.Fprintf(msgOut, "Submitted batch %s with %d naming requests; OpenAI has up to 24 hours to answer\n", pending.ID, len(batchPrompts))
+	return finishBatch(client, pending)
+}
+
+// finishBatch waits for batch b, caches its answers and forgets it.
+// Requests that failed are simply not cached, so the review asks them
+// again directly.
+func finishBatch(client *openai.Client, b pendingBatch) error {
+	var batch openai.BatchResponse
+	last := ""
+	for {
+		var err error
+		if batch, err = client.RetrieveBatch(runCtx, b.ID); err != nil {
+			return fmt.Errorf("checking on batch %s: %v", b.ID, err)
+		}
+		counts := batch.RequestCounts
+		status := fmt.Sprintf("%s, %d of %d done", batch.Status, counts.Completed+counts.Failed, counts.Total)
+		if status != last {
+			fmt.Fprintf(msgOut, "Batch %s: %s\n", b.ID, status)
+			last = status
+		}
+		switch batch.Status {
+		case "completed", "failed", "expired", "cancelled":
+		default:
+			select {
+			case <-runCtx.Done():
+				return runCtx.Err()
+			case <-time.After(batchPollInterval):
+			}
+			continue
+		}
+		break
+	}
+
+	if batch.OutputFileID != nil {
+		n, err := cacheBatchOutput(client, *batch.OutputFileID, b)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(msgOut, "Batch %s: %d names ready\n", b.ID, n)
+	}
+	if f := batch.RequestCounts.Failed; f > 0 || batch.Status != "completed" {
+		log.Printf("Batch %s ended %s with %d failed requests; those files are named directly instead", b.ID, batch.Status, f)
+	}
+	// The files are no use once the answers are cached.
+	ctx := context.WithoutCancel(runCtx)
+	for _, id := range []*string{&batch.InputFileID, batch.OutputFileID, batch.ErrorFileID} {
+		if id != nil && *id != "" {
+			client.DeleteFile(ctx, *id)
+		}
+	}
+	return removeState(batchStateFile)
+}
+
+// batchOutputLine is one line of a batch's output file.
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                           `json:"status_code"`
+		Body       openai.ChatCompletionResponse `json:"body"`
+	} `json:"response"`
+}
+
+// cacheBatchOutput stores the answers in the output file under the keys
+// askChatGPT looks them up by, returning how many there were.
+func cacheBatchOutput(client *openai.Client, fileID string, b pendingBatch) (int, error) {
+	content, err := client.GetFileContent(runCtx, fileID)
+	if err != nil {
+		return 0, fmt.Errorf("downloading the batch's answers: %v", err)
+	}
+	defer content.Close()
+	n := 0
+	sc := bufio.NewScanner(content)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for sc.Scan() {
+		var line batchOutputLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			return n, fmt.Errorf("reading the batch's answers: %v", err)
+		}
+		prompt, ok := b.Prompts[line.CustomID]
+		if !ok || line.Response == nil || line.Response.StatusCode != 200 || len(line.Response.Body.Choices) == 0 {
+			continue
+		}
+		body := line.Response.Body
+		reply := strings.TrimSpace(body.Choices[0].Message.Content)
+		if reply == "" {
+			continue
+		}
+		cachePut(cacheKey("openai", b.Model, prompt), cachedReply{Model: body.Model, Reply: reply})
+		recordBatchUsage(reply, body.Model, body.Usage.PromptTokens, body.Usage.CompletionTokens)
+		n++
+	}
+	return n, sc.Err()
+}
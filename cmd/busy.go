@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// settleTime is how long a file must go unmodified before we trust that
+	// whoever wrote it is done.
+	settleTime = 2 * time.Second
+	// probeWindow is how recent an mtime has to be for the size probe to be
+	// worth the wait.
+	probeWindow   = 10 * time.Second
+	probeInterval = 500 * time.Millisecond
+	busyRetries   = 5
+)
+
+// fileBusy reports whether path looks like it is still being written or is
+// locked by another process.
+func fileBusy(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false // let the caller run into the real error
+	}
+	age := time.Since(info.ModTime())
+	if age < settleTime {
+		return true
+	}
+	if age < probeWindow {
+		time.Sleep(probeInterval)
+		again, err := os.Stat(path)
+		if err == nil && (again.Size() != info.Size() || !again.ModTime().Equal(info.ModTime())) {
+			return true
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return isLockedErr(err)
+	}
+	f.Close()
+	return false
+}
+
+// waitUntilReady retries a busy file with exponential backoff and reports
+// whether it became ready.
+func waitUntilReady(path string) bool {
+	delay := time.Second
+	for i := 0; i < busyRetries; i++ {
+		if !fileBusy(path) {
+			return true
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return !fileBusy(path)
+}
+
+// renameRetrying is os.Rename that rides out short-lived locks, e.g. a
+// sync client or antivirus holding the file open on Windows.
+func renameRetrying(oldPath, newPath string) error {
+	delay := 200 * time.Millisecond
+	for i := 0; ; i++ {
+		err := os.Rename(oldPath, newPath)
+		if err == nil || !isLockedErr(err) || i == busyRetries {
+			return err
+		}
+		log.Printf("%s is locked, retrying in %v", oldPath, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
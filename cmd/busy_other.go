@@ -0,0 +1,14 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isLockedErr reports whether err means the file is in use. Unix has no
+// mandatory locks, so this only catches the odd ETXTBSY.
+func isLockedErr(err error) bool {
+	return errors.Is(err, syscall.ETXTBSY)
+}
@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// isLockedErr reports whether err is Windows refusing access because
+// another process has the file open.
+func isLockedErr(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheDir holds one file per answer rather than one big state file, so a
+// cache of many thousands of descriptions costs nothing to add to.
+const cacheDir = "cache"
+
+var noCache bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "ask the APIs again even for files they have already seen")
+	purgers[cacheDir] = purgeCache
+}
+
+// cachedReply is a stored model answer. Content is the SHA-256 of the file
+// it was about, empty for text-only prompts.
+type cachedReply struct {
+	Content string    `json:"content,omitempty"`
+	Model   string    `json:"model"`
+	Reply   string    `json:"reply"`
+	Time    time.Time `json:"time"`
+}
+
+// cacheKey combines everything an answer depends on: the file's content
+// hash, the model, the prompt and the flags that change what is uploaded.
+func cacheKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// uploadVariant is the part of the cache key for flags that change the
+// rendition of an image that gets sent.
+func uploadVariant() string {
+	return string(detail) + "/" + maxUploadSize.String() + "/" + strconv.Itoa(maxUploadEdge)
+}
+
+func cachePath(key string) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, cacheDir, key[:2], key), nil
+}
+
+func cacheGet(key string) (cachedReply, bool) {
+	var c cachedReply
+	if noCache {
+		return c, false
+	}
+	path, err := cachePath(key)
+	if err != nil {
+		return c, false
+	}
+	c, err = readCacheEntry(path)
+	return c, err == nil
+}
+
+func readCacheEntry(path string) (cachedReply, error) {
+	var c cachedReply
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if data, err = openState(data); err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+func cachePut(key string, c cachedReply) {
+	if err := writeCacheEntry(key, c); err != nil {
+		log.Printf("Error caching reply: %v", err)
+	}
+}
+
+func writeCacheEntry(key string, c cachedReply) error {
+	path, err := cachePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	c.Time = time.Now()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if data, err = sealState(data); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cachedAnswer returns the stored reply for key, or asks and stores it.
+// content is the hash of the file the question is about.
+func cachedAnswer(key, content, prompt string, ask func() (reply, model string, err error)) (string, error) {
+	if c, ok := cacheGet(key); ok {
+		rememberReply(c.Reply, c.Model, prompt)
+		return c.Reply, nil
+	}
+	reply, model, err := ask()
+	if err != nil {
+		return "", err
+	}
+	if reply != "" {
+		cachePut(key, cachedReply{Content: content, Model: model, Reply: reply})
+	}
+	return reply, nil
+}
+
+// purgeCache removes the cached answers about the target's content. By
+// path, that is the content of the file there now.
+func purgeCache(t purgeTarget) (int, error) {
+	hash := t.hash
+	if t.path != "" {
+		h, err := fileSHA256(t.path)
+		if err != nil {
+			return 0, nil
+		}
+		hash = h
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	err = filepath.WalkDir(filepath.Join(dir, cacheDir), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		c, err := readCacheEntry(path)
+		if err != nil || c.Content != hash {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxCandidates caps --candidates; past five the names only get worse.
+const maxCandidates = 5
+
+// candidates is --candidates: how many names are offered per file.
+var candidates = candidateCount(1)
+
+func init() {
+	rootCmd.PersistentFlags().Var(&candidates, "candidates", "offer this many names per file (up to 5) and pick one by number")
+}
+
+type candidateCount int
+
+func (c *candidateCount) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxCandidates {
+		return fmt.Errorf("--candidates wants a number from 1 to %d", maxCandidates)
+	}
+	*c = candidateCount(n)
+	return nil
+}
+
+func (c *candidateCount) String() string { return strconv.Itoa(int(*c)) }
+
+func (c *candidateCount) Type() string { return "n" }
+
+// alternativesPrompt asks for n more names for what description describes,
+// unlike name, in one request rather than n whole new suggestions.
+func alternativesPrompt(description, name string, n int) string {
+	return fmt.Sprintf(`Here is a description of a file:
+%s
+
+It has been given the file name '%s'. Suggest %d other short, descriptive, and human-friendly file names for it (without file extension), each under 40 characters and worded differently from that name and from each other.
+
+Reply with one name per line and nothing else:`, description, name, n)
+}
+
+// listMarker is the numbering or bullet models put in front of list items.
+var listMarker = regexp.MustCompile(`^\s*(\d+[.)]|[-*•])\s*`)
+
+// alternativeNames asks for n names for path besides name, returning
+// them with the trace of the request they came from.
+func alternativeNames(path, description, name string, n int) ([]string, namingTrace, error) {
+	reply, err := askChatGPT(alternativesPrompt(description, name, n))
+	if err != nil {
+		return nil, namingTrace{}, err
+	}
+	chargeFile(path, reply)
+	t, _ := replyTraces.Load(reply)
+	trace, _ := t.(namingTrace)
+	var names []string
+	seen := map[string]bool{sanitizeFileName(name): true}
+	for _, line := range strings.Split(reply, "\n") {
+		alt := strings.Trim(strings.TrimSpace(listMarker.ReplaceAllString(line, "")), "`'\"")
+		if alt == "" || seen[sanitizeFileName(alt)] {
+			continue
+		}
+		seen[sanitizeFileName(alt)] = true
+		names = append(names, alt)
+		if len(names) == n {
+			break
+		}
+	}
+	return names, trace, nil
+}
+
+// addAlternatives fills in s.alternatives under --candidates, asking with
+// raw, the name before the template was applied. Failing to get them only
+// costs the choice, so it is logged rather than returned.
+func addAlternatives(s *suggestion, raw string) {
+	if candidates <= 1 {
+		return
+	}
+	description := s.description
+	if description == "" {
+		description = raw
+	}
+	alts, trace, err := alternativeNames(s.path, description, raw, int(candidates)-1)
+	if err != nil {
+		log.Printf("Error getting more names for %s: %v", s.path, err)
+		return
+	}
+	// Whichever name is picked is traced to the request it came from.
+	replyTraces.Store(s.name, namingTraceFor(s.path))
+	for _, alt := range alts {
+		name, err := finalName(s.path, alt)
+		if err != nil {
+			log.Printf("Error applying the template to %q: %v", alt, err)
+			continue
+		}
+		replyTraces.Store(name, trace)
+		s.alternatives = append(s.alternatives, name)
+	}
+}
+
+// names is the suggested name followed by the alternatives.
+func (s suggestion) names() []string {
+	return append([]string{s.name}, s.alternatives...)
+}
+
+// pick makes the i-th of s.names() the name, keeping the others.
+func (s *suggestion) pick(i int) {
+	names := s.names()
+	s.name = names[i]
+	s.alternatives = append(names[:i:i], names[i+1:]...)
+	traceNaming(s.path, s.name)
+}
+
+// cycle moves on to the next name, putting the current one last.
+func (s *suggestion) cycle() {
+	names := s.names()
+	s.name = names[1]
+	s.alternatives = append(names[2:], names[0])
+	traceNaming(s.path, s.name)
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+var timezoneName string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&timezoneName, "timezone", "Local", "IANA time zone for capture times that carry none (EXIF, file names) and for {{.Date}}")
+}
+
+// captureZone returns the --timezone location.
+func captureZone() (*time.Location, error) {
+	loc, err := time.LoadLocation(timezoneName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --timezone: %v", err)
+	}
+	return loc, nil
+}
+
+// captureTime works out when path was captured, trying in order the EXIF
+// DateTimeOriginal, a QuickTime/MP4 creation date, a timestamp in the file
+// name and finally the modification time. The result is in --timezone and
+// source says which one was used.
+func captureTime(path string) (t time.Time, source string) {
+	loc, err := captureZone()
+	if err != nil {
+		loc = time.Local
+	}
+	if t, ok := exifCaptureTime(path, loc); ok {
+		return t, "exif"
+	}
+	if t, ok := quickTimeCreation(path); ok {
+		return t.In(loc), "quicktime"
+	}
+	if t, ok := screenshotTime(filepath.Base(path), loc); ok {
+		return t, "filename"
+	}
+	if t, ok := chatMediaTime(filepath.Base(path), loc); ok {
+		return t, "filename"
+	}
+	if t, ok := filenameTime(filepath.Base(path), loc); ok {
+		return t, "filename"
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime().In(loc), "mtime"
+	}
+	return time.Time{}, ""
+}
+
+func exifCaptureTime(path string, loc *time.Location) (time.Time, bool) {
+	x, err := readEXIF(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, field := range []exif.FieldName{exif.DateTimeOriginal, exif.DateTimeDigitized, exif.DateTime} {
+		tag, err := x.Get(field)
+		if err != nil || tag.Format() != tiff.StringVal {
+			continue
+		}
+		value := strings.TrimRight(string(tag.Val), "\x00 ")
+		// EXIF times are wall-clock times with no zone of their own.
+		if t, err := time.ParseInLocation("2006:01:02 15:04:05", value, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// quickTimeEpoch is where mvhd creation times count from.
+var quickTimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// quickTimeCreation reads the creation time from the movie header (moov/
+// mvhd) of a QuickTime or MP4 file. It is stored in UTC.
+func quickTimeCreation(path string) (time.Time, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mov", ".mp4", ".m4v", ".m4a", ".3gp":
+	default:
+		return time.Time{}, false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	moov, ok := findAtom(f, 0, info.Size(), "moov")
+	if !ok {
+		return time.Time{}, false
+	}
+	mvhd, ok := findAtom(f, moov.start, moov.end, "mvhd")
+	if !ok {
+		return time.Time{}, false
+	}
+	head := make([]byte, 12)
+	if _, err := f.ReadAt(head, mvhd.start); err != nil {
+		return time.Time{}, false
+	}
+	var secs uint64
+	if head[0] == 1 { // version 1 uses 64-bit times
+		secs = binary.BigEndian.Uint64(head[4:12])
+	} else {
+		secs = uint64(binary.BigEndian.Uint32(head[4:8]))
+	}
+	if secs == 0 {
+		return time.Time{}, false
+	}
+	return quickTimeEpoch.Add(time.Duration(secs) * time.Second), true
+}
+
+type atom struct{ start, end int64 }
+
+// findAtom scans the boxes between start and end for one of the given
+// type and returns the extent of its payload.
+func findAtom(r io.ReaderAt, start, end int64, typ string) (atom, bool) {
+	header := make([]byte, 16)
+	for off := start; off+8 <= end; {
+		if _, err := r.ReadAt(header[:8], off); err != nil {
+			return atom{}, false
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerLen := int64(8)
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			if _, err := r.ReadAt(header[8:16], off+8); err != nil {
+				return atom{}, false
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerLen = 16
+		}
+		if size < headerLen {
+			return atom{}, false
+		}
+		if string(header[4:8]) == typ {
+			return atom{off + headerLen, off + size}, true
+		}
+		off += size
+	}
+	return atom{}, false
+}
+
+// filenameTimePattern matches the timestamps phones, cameras and OSes put
+// in file names: "Screenshot 2024-05-03 at 10.11.12", "IMG_20240314_101112",
+// "PXL_20240314_101112345", "2024-03-14 10.11.12" and plain dates.
+var filenameTimePattern = regexp.MustCompile(`((?:19|20)\d{2})[-_.]?(0[1-9]|1[0-2])[-_.]?(0[1-9]|[12]\d|3[01])(?:(?:[ _T-]|\sat\s)+([01]\d|2[0-3])[.:_-]?([0-5]\d)[.:_-]?([0-5]\d))?`)
+
+func filenameTime(name string, loc *time.Location) (time.Time, bool) {
+	m := filenameTimePattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	value := m[1] + m[2] + m[3]
+	layout := "20060102"
+	if m[4] != "" {
+		value += m[4] + m[5] + m[6]
+		layout += "150405"
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	return t, err == nil
+}
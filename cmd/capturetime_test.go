@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// box is an MP4 box of type typ around payload.
+func box(typ string, payload ...byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	return append(append(b, typ...), payload...)
+}
+
+func TestFindAtom(t *testing.T) {
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	// A box whose size is in the 64-bit field after the type.
+	large := append(append(binary.BigEndian.AppendUint32(nil, 1), "moov"...), binary.BigEndian.AppendUint64(nil, 20)...)
+	large = append(large, 1, 2, 3, 4)
+	tests := []struct {
+		name  string
+		data  []byte
+		start int64
+		typ   string
+		want  atom
+		found bool
+	}{
+		{"first box", join(box("ftyp", 0, 0, 0, 0), box("moov")), 0, "ftyp", atom{8, 12}, true},
+		{"later box", join(box("ftyp", 0, 0, 0, 0), box("moov", 9, 9)), 0, "moov", atom{20, 22}, true},
+		{"inside another", join(box("ftyp"), box("moov", box("mvhd", 7)...)), 16, "mvhd", atom{24, 25}, true},
+		{"missing", join(box("ftyp"), box("mdat", 1)), 0, "moov", atom{}, false},
+		{"size 0 runs to the end", append(binary.BigEndian.AppendUint32(nil, 0), append([]byte("moov"), 5, 6, 7)...), 0, "moov", atom{8, 11}, true},
+		{"64-bit size", join(large, box("free")), 0, "moov", atom{16, 20}, true},
+		{"box after a 64-bit one", join(large, box("free", 1)), 0, "free", atom{28, 29}, true},
+		{"size smaller than its header", append(binary.BigEndian.AppendUint32(nil, 4), "moov"...), 0, "moov", atom{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := findAtom(bytes.NewReader(tt.data), tt.start, int64(len(tt.data)), tt.typ)
+			if got != tt.want || found != tt.found {
+				t.Errorf("findAtom() = %v, %v, want %v, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestFilenameTime(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		name  string
+		want  time.Time
+		found bool
+	}{
+		{"Screenshot 2024-05-03 at 10.11.12.png", at("2024-05-03 10:11:12"), true},
+		{"IMG_20240314_101112.jpg", at("2024-03-14 10:11:12"), true},
+		{"PXL_20240314_101112345.jpg", at("2024-03-14 10:11:12"), true},
+		{"2024-03-14 10.11.12.png", at("2024-03-14 10:11:12"), true},
+		{"VID-20231231-WA0001.mp4", at("2023-12-31 00:00:00"), true},
+		{"scan_2021.06.30.pdf", at("2021-06-30 00:00:00"), true},
+		{"invoice 2024-13-01.pdf", time.Time{}, false},
+		{"report-1899-01-01.pdf", time.Time{}, false},
+		{"cat_on_sofa.png", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := filenameTime(tt.name, time.UTC)
+			if !got.Equal(tt.want) || found != tt.found {
+				t.Errorf("filenameTime(%q) = %v, %v, want %v, %v", tt.name, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
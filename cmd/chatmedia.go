@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// chatPresetTemplate is the default --template with --preset: chat media
+// names carry nothing worth keeping but the date.
+const chatPresetTemplate = "{{.Date}}_{{.Name}}"
+
+var preset string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&preset, "preset", "", "rename chat app media: whatsapp or telegram (names them "+chatPresetTemplate+" unless --template is given)")
+}
+
+// chatMediaPatterns match the media names chat apps save or export:
+//
+//	IMG-20240314-WA0012.jpg, PTT-20240314-WA0001.opus   WhatsApp
+//	photo_12@14-03-2024_10-11-12.jpg                    Telegram Desktop export
+var chatMediaPatterns = map[string]*regexp.Regexp{
+	"whatsapp": regexp.MustCompile(`(?i)^(?:img|vid|ptt|aud|doc|stk)-(\d{4})(\d{2})(\d{2})-wa\d+`),
+	"telegram": regexp.MustCompile(`(?i)^(?:photo|video|file|audio|voice|sticker|round_video)_\d+@(\d{2})-(\d{2})-(\d{4})_(\d{2})-(\d{2})-(\d{2})`),
+}
+
+// applyPreset checks --preset and, unless --template was given, switches to
+// the chat media template.
+func applyPreset(cmd *cobra.Command) error {
+	if preset == "" {
+		return nil
+	}
+	if chatMediaPatterns[preset] == nil {
+		return fmt.Errorf("unknown --preset %q (want whatsapp or telegram)", preset)
+	}
+	if !cmd.Flags().Changed("template") {
+		nameTemplateText = chatPresetTemplate
+	}
+	return nil
+}
+
+// isChatMedia reports whether name is media from the --preset chat app.
+func isChatMedia(name string) bool {
+	p := chatMediaPatterns[preset]
+	return p != nil && p.MatchString(name)
+}
+
+// chatMediaTime parses the date WhatsApp or Telegram put in a media file's
+// name. WhatsApp only records the day.
+func chatMediaTime(name string, loc *time.Location) (time.Time, bool) {
+	if m := chatMediaPatterns["whatsapp"].FindStringSubmatch(name); m != nil {
+		t, err := time.ParseInLocation("20060102", m[1]+m[2]+m[3], loc)
+		return t, err == nil
+	}
+	if m := chatMediaPatterns["telegram"].FindStringSubmatch(name); m != nil {
+		t, err := time.ParseInLocation("02012006150405", m[1]+m[2]+m[3]+m[4]+m[5]+m[6], loc)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// chatMediaPrompt is added to the description prompt with --preset.
+func chatMediaPrompt() string {
+	if preset == "" {
+		return ""
+	}
+	return `
+
+This image was sent in a chat. Say first what kind it is: a meme or joke (quote its text), a forwarded screenshot (say of what), a photo of a document, receipt, ticket or notice (give its key details), a sticker, or a personal photo.`
+}
+
+// chatMediaNameHint is added to the naming prompt with --preset.
+func chatMediaNameHint() string {
+	if preset == "" {
+		return ""
+	}
+	return `
+The image was shared in a chat. Start the name with what kind it is when that helps find it again, e.g. 'meme_monday_coffee', 'receipt_ikea_desk', 'ticket_berlin_train', 'screenshot_flight_delay'.`
+}
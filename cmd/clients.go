@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/api/option"
+)
+
+// The API clients are made once per key and shared by every file and
+// worker for the rest of the run, so their connections are reused
+// instead of being set up again for each request.
+var (
+	clientsMu     sync.Mutex
+	geminiClients = map[string]*genai.Client{}
+	openaiClients = map[string]*openai.Client{}
+)
+
+func geminiClient(apiKey string) (*genai.Client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c := geminiClients[apiKey]; c != nil {
+		return c, nil
+	}
+	// Not runCtx: the client outlives any one request, and deleting
+	// uploads still needs it once the run is interrupted.
+	c, err := genai.NewClient(context.Background(), option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("creating Gemini client: %v", err)
+	}
+	geminiClients[apiKey] = c
+	return c, nil
+}
+
+func openaiClient(apiKey string) *openai.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	c := openaiClients[apiKey]
+	if c == nil {
+		c = openai.NewClient(apiKey)
+		openaiClients[apiKey] = c
+	}
+	return c
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// onConflict is --on-conflict: what renameFile does when the new name is
+// already taken.
+var onConflict = conflictPolicy("suffix")
+
+// errNameTaken is returned by renameFile when the name is taken and the
+// policy, or the answer to the prompt, is to leave the file alone.
+var errNameTaken = errors.New("name already taken")
+
+func init() {
+	rootCmd.PersistentFlags().Var(&onConflict, "on-conflict", "when the new name is taken: suffix (add _2, _3, ...), skip, prompt or overwrite")
+}
+
+type conflictPolicy string
+
+func (c *conflictPolicy) Set(s string) error {
+	switch s {
+	case "suffix", "skip", "prompt", "overwrite":
+		*c = conflictPolicy(s)
+		return nil
+	}
+	return fmt.Errorf("unknown conflict policy %q (want suffix, skip, prompt or overwrite)", s)
+}
+
+func (c *conflictPolicy) String() string { return string(*c) }
+
+func (c *conflictPolicy) Type() string { return "policy" }
+
+// nameTaken reports whether renaming path to target would replace another
+// file. A target that is path itself under another case, as on macOS and
+// Windows, is not taken.
+func nameTaken(path, target string) bool {
+	info, err := os.Stat(target)
+	if err != nil {
+		return false
+	}
+	own, err := os.Stat(path)
+	return err != nil || !os.SameFile(own, info)
+}
+
+// resolveConflict returns where a file should go given that target is
+// taken, and whether to replace what is there.
+func resolveConflict(target string) (string, bool, error) {
+	policy := onConflict
+	if policy == "prompt" {
+		policy = askConflict(target)
+	}
+	switch policy {
+	case "overwrite":
+		return target, true, nil
+	case "skip":
+		return "", false, fmt.Errorf("%s: %w", target, errNameTaken)
+	}
+	return freeName(target), false, nil
+}
+
+// askConflict asks what to do about target, taking the safe suffix under
+// --yes or --no-input.
+func askConflict(target string) conflictPolicy {
+	fmt.Fprintf(msgOut, "%s already exists. Overwrite it, add a number, or skip? (o/a/s): ", target)
+	if assumeYes || noInput {
+		fmt.Fprintln(msgOut, "a")
+		return "suffix"
+	}
+	var input string
+	fmt.Scanln(&input)
+	switch strings.ToLower(input) {
+	case "o":
+		return "overwrite"
+	case "s":
+		return "skip"
+	}
+	return "suffix"
+}
+
+// freeName adds _2, _3, ... to target until the name is free.
+func freeName(target string) string {
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+	}
+}
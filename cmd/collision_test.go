@@ -0,0 +1,34 @@
+package cmd
+
+import "testing"
+
+func TestConflictPolicySet(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"suffix", false},
+		{"skip", false},
+		{"prompt", false},
+		{"overwrite", false},
+		{"", true},
+		{"Skip", true},
+		{"rename", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			p := conflictPolicy("suffix")
+			err := p.Set(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			want := tt.in
+			if tt.wantErr {
+				want = "suffix" // left as it was
+			}
+			if p.String() != want {
+				t.Errorf("after Set(%q) the policy is %q, want %q", tt.in, p, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is config.yaml. Flags given on the command line win over it,
+// and it wins over environment variables.
+type fileConfig struct {
+	// Keys are API keys by provider (openai, gemini), one or a list.
+	Keys map[string]stringList `yaml:"keys"`
+	// Models override the model names by provider.
+	Models map[string]string `yaml:"models"`
+	// Prompts replace the built-in describe and name prompts.
+	Prompts struct {
+		Describe string `yaml:"describe"`
+		Name     string `yaml:"name"`
+	} `yaml:"prompts"`
+	// Patterns are extra regular expressions for file names to handle.
+	Patterns []string `yaml:"patterns"`
+	// Flags are defaults for any command-line flag, by its long name.
+	Flags map[string]yaml.Node `yaml:"flags"`
+}
+
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = strings.Split(n.Value, ",")
+		return nil
+	}
+	return n.Decode((*[]string)(l))
+}
+
+var (
+	configPath string
+	config     fileConfig
+
+	extraPatterns []*regexp.Regexp
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "config file (default $XDG_CONFIG_HOME/tell-me-more/config.yaml)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		return applyModelFlag()
+	}
+}
+
+func defaultConfigPath() string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "tell-me-more", "config.yaml")
+}
+
+// loadConfig reads the config file and applies it to cmd's flags that were
+// not given on the command line.
+func loadConfig(cmd *cobra.Command) error {
+	path := configPath
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && configPath == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("reading %s: %v", path, err)
+	}
+
+	for name, node := range config.Flags {
+		f := cmd.Flags().Lookup(name)
+		if f == nil {
+			if rootCmd.Flags().Lookup(name) == nil && !anySubcommandHasFlag(name) {
+				return fmt.Errorf("%s: unknown flag %q", path, name)
+			}
+			continue // belongs to another command
+		}
+		if f.Changed {
+			continue
+		}
+		value := node.Value
+		if node.Kind == yaml.SequenceNode {
+			var items []string
+			if err := node.Decode(&items); err != nil {
+				return fmt.Errorf("%s: flag %q: %v", path, name, err)
+			}
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				if err := sv.Replace(items); err != nil {
+					return fmt.Errorf("%s: flag %q: %v", path, name, err)
+				}
+				continue
+			}
+			value = strings.Join(items, ",")
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("%s: flag %q: %v", path, name, err)
+		}
+	}
+
+	for provider, keys := range config.Keys {
+		switch provider {
+		case "openai":
+			openaiKeys.configured = keys
+		case "gemini":
+			geminiKeys.configured = keys
+		case "anthropic":
+			anthropicKeys.configured = keys
+		default:
+			return fmt.Errorf("%s: keys for unknown provider %q", path, provider)
+		}
+	}
+	for provider, model := range config.Models {
+		switch provider {
+		case "openai":
+			openaiModel = model
+		case "openai-vision":
+			visionModel = model
+		case "gemini":
+			geminiModel = model
+		case "anthropic":
+			anthropicModel = model
+		case "ollama":
+			ollamaModel = model
+		case "openai-embedding":
+			openaiEmbeddingModel = model
+		case "gemini-embedding":
+			geminiEmbeddingModel = model
+		case "ollama-embedding":
+			ollamaEmbeddingModel = model
+		default:
+			return fmt.Errorf("%s: model for unknown provider %q", path, provider)
+		}
+	}
+	for _, p := range config.Patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return fmt.Errorf("%s: pattern %q: %v", path, p, err)
+		}
+		extraPatterns = append(extraPatterns, re)
+	}
+	return nil
+}
+
+func anySubcommandHasFlag(name string) bool {
+	for _, c := range rootCmd.Commands() {
+		if c.Flags().Lookup(name) != nil {
+			return true
+		}
+		for _, sub := range c.Commands() {
+			if sub.Flags().Lookup(name) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesExtraPattern(filename string) bool {
+	for _, re := range extraPatterns {
+		if re.MatchString(filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameFromConfigPrompt fills the configured name prompt with description:
+// at {description} if it has that placeholder, after it otherwise.
+func nameFromConfigPrompt(description string) string {
+	prompt := config.Prompts.Name
+	if strings.Contains(prompt, "{description}") {
+		return strings.ReplaceAll(prompt, "{description}", description)
+	}
+	return prompt + "\n\n" + description
+}
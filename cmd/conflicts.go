@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var reportConflicts bool
+
+func init() {
+	rootCmd.Flags().BoolVar(&reportConflicts, "report-conflicts", false, "list sync-conflict copies next to their originals after the walk")
+}
+
+// syncConflictPatterns match the conflict copies sync clients leave next to
+// the original; the first group, together with the extension, is the
+// original's name:
+//
+//	photo.sync-conflict-20240503-101112-ABCDEFG.jpg   Syncthing
+//	photo (Alex's conflicted copy 2024-05-03).jpg     Dropbox
+//	photo (conflicted copy 2024-05-03 101112).jpg     Nextcloud
+var syncConflictPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(.*)\.sync-conflict-\d{8}-\d{6}(-[A-Z0-9]+)?$`),
+	regexp.MustCompile(`(?i)^(.*?) \([^()]*conflicted copy[^()]*\)$`),
+}
+
+// conflictOriginal returns the name of the file a sync-conflict copy
+// belongs to, or "" when name isn't a conflict copy.
+func conflictOriginal(name string) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for _, p := range syncConflictPatterns {
+		if m := p.FindStringSubmatch(stem); m != nil {
+			return m[1] + ext
+		}
+	}
+	return ""
+}
+
+func isSyncConflict(name string) bool {
+	return conflictOriginal(name) != ""
+}
+
+// syncConflicts finds the conflict copies in a directory and remembers the
+// directories that had any, for --report-conflicts.
+type syncConflicts struct {
+	dirs map[string]map[string][]string
+}
+
+// inDir returns the conflict copies in dir by the name of their original.
+// The directory is read in full so the answer doesn't depend on the order
+// of the walk.
+func (c *syncConflicts) inDir(dir string) map[string][]string {
+	byOriginal := map[string][]string{}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if original := conflictOriginal(e.Name()); original != "" {
+			byOriginal[original] = append(byOriginal[original], e.Name())
+		}
+	}
+	if len(byOriginal) > 0 {
+		if c.dirs == nil {
+			c.dirs = map[string]map[string][]string{}
+		}
+		c.dirs[dir] = byOriginal
+	}
+	return byOriginal
+}
+
+// report prints every original with its conflict copies, for the
+// directories that were looked at.
+func (c *syncConflicts) report() {
+	var lines []string
+	for dir, byOriginal := range c.dirs {
+		for original, copies := range byOriginal {
+			sort.Strings(copies)
+			line := filepath.Join(dir, original)
+			if _, err := os.Stat(line); err != nil {
+				line += " (missing)"
+			}
+			for _, name := range copies {
+				line += "\n    " + name
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sort.Strings(lines)
+	fmt.Fprintln(msgOut, "Sync conflicts to resolve:")
+	for _, line := range lines {
+		fmt.Fprintf(msgOut, "  %s\n", line)
+	}
+}
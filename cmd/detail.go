@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// detail is --detail: how much of an image's resolution is sent.
+var detail = detailLevel("high")
+
+func init() {
+	rootCmd.PersistentFlags().Var(&detail, "detail", "image detail sent for description: low, high or auto (low for simple screenshots, high for dense ones)")
+}
+
+// lowDetailSize is the longest side of a low-detail image, the size a
+// 768×768 vision tile covers.
+const lowDetailSize = 768
+
+type detailLevel string
+
+func (d *detailLevel) Set(s string) error {
+	switch s {
+	case "low", "high", "auto":
+		*d = detailLevel(s)
+		return nil
+	}
+	return fmt.Errorf("unknown detail %q (want low, high or auto)", s)
+}
+
+func (d *detailLevel) String() string { return string(*d) }
+
+func (d *detailLevel) Type() string { return "level" }
+
+// lowDetail reports whether img should be sent at low detail.
+func lowDetail(img image.Image) bool {
+	switch detail {
+	case "low":
+		return true
+	case "auto":
+		return busyness(img) < simpleImageBusyness
+	}
+	return false
+}
+
+// simpleImageBusyness separates screenshots that are mostly empty space or
+// large type, which read fine at low detail, from dashboards, spreadsheets
+// and dense code, whose small text gets lost when scaled down.
+const simpleImageBusyness = 0.03
+
+// busyness estimates how much small, sharp detail such as text img has:
+// the share of pixels on a grey copy at most 1024 pixels wide that differ
+// sharply from their right or lower neighbour. Smooth photos and flat UI
+// stay near 0; a screen full of small text is well above 0.05.
+func busyness(img image.Image) float64 {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return 0
+	}
+	w := min(1024, b.Dx())
+	h := max(1, b.Dy()*w/b.Dx())
+	small := resizeImage(img, w, h)
+	gray := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray[y*w+x] = int(color.GrayModel.Convert(small.At(x, y)).(color.Gray).Y)
+		}
+	}
+	sharp := 0
+	for y := 0; y < h-1; y++ {
+		for x := 0; x < w-1; x++ {
+			g := gray[y*w+x]
+			if abs(g-gray[y*w+x+1]) > 64 || abs(g-gray[(y+1)*w+x]) > 64 {
+				sharp++
+			}
+		}
+	}
+	return float64(sharp) / float64(w*h)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+var dryRun bool
+
+// errDryRun is returned by moveFile under --dry-run, with the path the
+// file would have been renamed to.
+var errDryRun = errors.New("not renamed with --dry-run")
+
+// plannedRename is one row of the --dry-run summary.
+type plannedRename struct {
+	old, new string
+	// note flags a plan that would not go through as it stands.
+	note string
+}
+
+var (
+	planMu  sync.Mutex
+	planned []plannedRename
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show the suggested names without asking or renaming anything, then print a summary")
+}
+
+// planRename adds old → new to the summary, noting when new is already
+// taken on disk or by an earlier row.
+func planRename(old, new string) {
+	planMu.Lock()
+	defer planMu.Unlock()
+	p := plannedRename{old: old, new: new}
+	if _, err := os.Stat(new); err == nil {
+		p.note = "exists"
+	}
+	for _, q := range planned {
+		if q.new == new {
+			p.note = "clashes with " + filepath.Base(q.old)
+		}
+	}
+	planned = append(planned, p)
+}
+
+// printPlan prints the --dry-run summary as a two-column table.
+func printPlan() {
+	if !dryRun {
+		return
+	}
+	planMu.Lock()
+	defer planMu.Unlock()
+	if len(planned) == 0 {
+		fmt.Fprintln(msgOut, "\nNo renames proposed")
+		return
+	}
+	width := 0
+	for _, p := range planned {
+		width = max(width, utf8.RuneCountInString(p.old))
+	}
+	fmt.Fprintf(msgOut, "\n%d proposed renames:\n", len(planned))
+	for _, p := range planned {
+		shown := filepath.Base(p.new)
+		// organize moves files into a folder next to them.
+		if rel, err := filepath.Rel(filepath.Dir(p.old), p.new); err == nil && filepath.Dir(p.new) != filepath.Dir(p.old) {
+			shown = rel
+		}
+		fmt.Fprintf(msgOut, "  %-*s → %s", width, p.old, shown)
+		if p.note != "" {
+			fmt.Fprintf(msgOut, "  (%s)", p.note)
+		}
+		fmt.Fprintln(msgOut)
+	}
+}
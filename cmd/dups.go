@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	reportDups  bool
+	dedupe      bool
+	dupDistance int
+)
+
+func init() {
+	rootCmd.Flags().BoolVar(&reportDups, "report-dups", false, "list identical and near-identical images after the walk")
+	rootCmd.Flags().BoolVar(&dedupe, "dedupe", false, "like --report-dups, then ask for each group whether to delete the extras, or for identical files hardlink them to the one kept")
+	rootCmd.Flags().IntVar(&dupDistance, "dup-distance", 6, "how many of the 64 bits of their difference hashes two images may differ in and still count as near-duplicates")
+}
+
+// dupImage is an image seen during the walk, for --report-dups.
+type dupImage struct {
+	path   string
+	hash   uint64
+	pixels int
+	size   int64
+	// flat is set for images of nearly one colour, whose hashes are all
+	// alike whatever they show.
+	flat bool
+}
+
+// dupImages are the images seen so far, keyed by where they are now.
+var (
+	dupImagesMu sync.Mutex
+	dupImages   = map[string]dupImage{}
+)
+
+// noteForDups hashes the image at path for the duplicate report. Files
+// that aren't images, or that Go can't decode, are left out.
+func noteForDups(path string) {
+	if !(reportDups || dedupe) || !isImageFile(path) {
+		return
+	}
+	img, err := differenceHash(path)
+	if err != nil {
+		return
+	}
+	dupImagesMu.Lock()
+	dupImages[path] = img
+	dupImagesMu.Unlock()
+}
+
+// movedForDups follows an image that was renamed after it was hashed.
+func movedForDups(oldPath, newPath string) {
+	dupImagesMu.Lock()
+	defer dupImagesMu.Unlock()
+	if img, ok := dupImages[oldPath]; ok {
+		delete(dupImages, oldPath)
+		img.path = newPath
+		dupImages[newPath] = img
+	}
+}
+
+// differenceHash is the 64-bit dHash of the image at path: shrunk to 9x8
+// in grey, each bit says whether a pixel is brighter than the one to its
+// right. Resizing, recompressing and small edits change few of the bits.
+func differenceHash(path string) (dupImage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return dupImage{}, err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return dupImage{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return dupImage{}, err
+	}
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.BiLinear.Scale(small, small.Bounds(), src, src.Bounds(), draw.Src, nil)
+	lo, hi := uint8(255), uint8(0)
+	for _, v := range small.Pix {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	b := src.Bounds()
+	return dupImage{path: path, hash: hash, pixels: b.Dx() * b.Dy(), size: info.Size(), flat: hi-lo < dupFlatContrast}, nil
+}
+
+// dupFlatContrast is the difference between the darkest and brightest
+// spots of the shrunk image below which it counts as flat.
+const dupFlatContrast = 12
+
+// dupGroup is a set of images that look the same. The first is the one to
+// keep: the largest, then the biggest file.
+type dupGroup struct {
+	images    []dupImage
+	identical bool
+	distance  int
+}
+
+// findDuplicates groups the images whose hashes are within --dup-distance
+// of the one kept. Each group is built around the image most worth
+// keeping, and the others are compared with it alone, so a chain of small
+// differences never joins images that don't look alike. Flat images only
+// group with identical files: their hashes say nothing about what they
+// show.
+func findDuplicates() []dupGroup {
+	dupImagesMu.Lock()
+	var images []dupImage
+	for _, img := range dupImages {
+		if _, err := os.Stat(img.path); err == nil {
+			images = append(images, img)
+		}
+	}
+	dupImagesMu.Unlock()
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].pixels != images[j].pixels {
+			return images[i].pixels > images[j].pixels
+		}
+		if images[i].size != images[j].size {
+			return images[i].size > images[j].size
+		}
+		return images[i].path < images[j].path
+	})
+
+	sums := map[string]string{}
+	sum := func(path string) string {
+		if _, ok := sums[path]; !ok {
+			sums[path], _ = fileSHA256(path)
+		}
+		return sums[path]
+	}
+	same := func(a, b dupImage) bool {
+		return a.size == b.size && sum(a.path) != "" && sum(a.path) == sum(b.path)
+	}
+
+	grouped := make([]bool, len(images))
+	var groups []dupGroup
+	for i, kept := range images {
+		if grouped[i] {
+			continue
+		}
+		g := dupGroup{images: []dupImage{kept}, identical: true}
+		for j := i + 1; j < len(images); j++ {
+			img := images[j]
+			if grouped[j] {
+				continue
+			}
+			distance := bits.OnesCount64(kept.hash ^ img.hash)
+			identical := same(kept, img)
+			if !identical && (kept.flat || img.flat || distance > dupDistance) {
+				continue
+			}
+			grouped[j] = true
+			g.images = append(g.images, img)
+			g.distance = max(g.distance, distance)
+			g.identical = g.identical && identical
+		}
+		if len(g.images) > 1 {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].images[0].path < groups[j].images[0].path })
+	return groups
+}
+
+// reportDuplicates prints the groups of duplicates found during the walk
+// and, with --dedupe, offers to clear out the extras of each.
+func reportDuplicates() {
+	if !(reportDups || dedupe) {
+		return
+	}
+	groups := findDuplicates()
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintln(msgOut, "Duplicate images:")
+	for _, g := range groups {
+		kind := "identical"
+		if !g.identical {
+			kind = "near-identical, up to " + strconv.Itoa(g.distance) + " bits apart"
+		}
+		fmt.Fprintf(msgOut, "  %s (%s)\n", g.images[0].path, kind)
+		for _, img := range g.images[1:] {
+			fmt.Fprintf(msgOut, "    %s\n", img.path)
+		}
+	}
+	if !dedupe {
+		return
+	}
+	if dryRun {
+		fmt.Fprintln(msgOut, "Not removing duplicates: this is a dry run")
+		return
+	}
+	for _, g := range groups {
+		switch askDedupe(g) {
+		case "d":
+			for _, img := range g.images[1:] {
+				if err := os.Remove(img.path); err != nil {
+					log.Printf("Error deleting %s: %v", img.path, err)
+					continue
+				}
+				audit("delete", absPath(img.path), "", "")
+				fmt.Fprintf(msgOut, "Deleted %s\n", img.path)
+			}
+		case "h":
+			for _, img := range g.images[1:] {
+				if err := hardlinkOver(g.images[0].path, img.path); err != nil {
+					log.Printf("Error linking %s: %v", img.path, err)
+					continue
+				}
+				audit("hardlink", absPath(img.path), absPath(g.images[0].path), "")
+				fmt.Fprintf(msgOut, "Linked %s to %s\n", img.path, g.images[0].path)
+			}
+		}
+	}
+}
+
+// askDedupe asks what to do with the extras in g. Only identical files
+// can share one copy; near-identical ones can just be deleted.
+func askDedupe(g dupGroup) string {
+	n := len(g.images) - 1
+	what := "the other " + strconv.Itoa(n)
+	if n == 1 {
+		what = "the other one"
+	}
+	keep := filepath.Base(g.images[0].path)
+	if g.identical {
+		fmt.Fprintf(msgOut, "Keep %s and delete %s, hardlink %s to it, or skip? (d/h/s): ", keep, what, what)
+	} else {
+		fmt.Fprintf(msgOut, "Keep %s and delete %s, or skip? (d/s): ", keep, what)
+	}
+	if assumeYes || noInput {
+		fmt.Fprintln(msgOut, "s")
+		return "s"
+	}
+	var input string
+	fmt.Scanln(&input)
+	switch input = strings.ToLower(input); {
+	case input == "d", input == "h" && g.identical:
+		return input
+	}
+	return "s"
+}
+
+// hardlinkOver replaces path with a hard link to keep. The link is made
+// beside path and renamed over it, so path is never missing.
+func hardlinkOver(keep, path string) error {
+	tmp := path + ".tmm-link"
+	if err := os.Link(keep, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
+)
+
+// editName lets the user change name before it is used, returning false if
+// they cancel or leave nothing that makes a file name. On a terminal the
+// suggestion is pre-filled in a line editor; otherwise a whole new name is
+// read from stdin.
+func editName(name string) (string, bool) {
+	var edited string
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		m := lineEditor{input: textinput.New()}
+		m.input.Prompt = "New name: "
+		m.input.SetValue(name)
+		m.input.CursorEnd()
+		m.input.Focus()
+		final, err := tea.NewProgram(m, tea.WithOutput(msgOut)).Run()
+		if err != nil || !final.(lineEditor).done {
+			return "", false
+		}
+		edited = final.(lineEditor).input.Value()
+	} else {
+		fmt.Fprint(msgOut, "New name: ")
+		edited = readLine()
+	}
+	edited = sanitizeFileName(strings.TrimSpace(edited))
+	return edited, edited != ""
+}
+
+// readLine reads up to the end of the line a byte at a time, so nothing
+// after it is taken from the answers fmt.Scanln reads next.
+func readLine() string {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if n, err := os.Stdin.Read(b); n == 0 || err != nil || b[0] == '\n' {
+			return strings.TrimSuffix(string(line), "\r")
+		}
+		line = append(line, b[0])
+	}
+}
+
+// lineEditor is a one-line tea program around a text input.
+type lineEditor struct {
+	input textinput.Model
+	done  bool
+	quit  bool
+}
+
+func (m lineEditor) Init() tea.Cmd { return textinput.Blink }
+
+func (m lineEditor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "enter":
+			m.done, m.quit = true, true
+			return m, tea.Quit
+		case "esc", "ctrl+c":
+			m.quit = true
+			return m, tea.Quit
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m lineEditor) View() string {
+	if m.quit {
+		// Leave the final line without a cursor on it.
+		return m.input.Prompt + m.input.Value() + "\n"
+	}
+	return m.input.View()
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	emailOutDir  string
+	emailMinSize byteSize = 10 << 10
+)
+
+var ingestEmailCmd = &cobra.Command{
+	Use:   "email <file.eml or maildir>...",
+	Short: "Save the image and PDF attachments of emails under descriptive names",
+	Long: `Extracts image and PDF attachments from .eml files or maildirs (a directory
+with cur/ and new/; any other directory is searched for .eml files). Each
+attachment is described, named from its content together with the email's
+subject and sender, and saved into --out-dir. The saved file's modification time
+is the email's date, so {{.Date}} in --template is when it was sent.
+
+Attachments smaller than --min-size, usually logos and signature images, are
+left out.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupOutput(); err != nil {
+			return err
+		}
+		if err := loadTemplate(); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(emailOutDir, 0o755); err != nil {
+			return err
+		}
+		for _, arg := range args {
+			messages, err := emailFiles(arg)
+			if err != nil {
+				return err
+			}
+			for _, path := range messages {
+				if err := ingestMessage(path); err != nil {
+					log.Printf("Error reading %s: %v", path, err)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	ingestEmailCmd.Flags().StringVar(&emailOutDir, "out-dir", ".", "directory to save attachments into")
+	ingestEmailCmd.Flags().Var(&emailMinSize, "min-size", "skip attachments smaller than this")
+	ingestCmd.AddCommand(ingestEmailCmd)
+}
+
+// emailFiles expands a file, maildir or directory of .eml files into the
+// message files to read.
+func emailFiles(arg string) ([]string, error) {
+	info, err := os.Stat(arg)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{arg}, nil
+	}
+	var files []string
+	if _, err := os.Stat(filepath.Join(arg, "cur")); err == nil {
+		for _, sub := range []string{"cur", "new"} {
+			entries, _ := os.ReadDir(filepath.Join(arg, sub))
+			for _, e := range entries {
+				if !e.IsDir() {
+					files = append(files, filepath.Join(arg, sub, e.Name()))
+				}
+			}
+		}
+		return files, nil
+	}
+	err = filepath.WalkDir(arg, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && skipDir(arg, path) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".eml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// emailAttachment is one attachment worth naming.
+type emailAttachment struct {
+	filename string
+	data     []byte
+}
+
+// emailContext is what the email says about its attachments.
+type emailContext struct {
+	subject, from string
+	date          time.Time
+}
+
+var headerDecoder = new(mime.WordDecoder)
+
+func ingestMessage(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		return err
+	}
+
+	var ctx emailContext
+	if ctx.subject, err = headerDecoder.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		ctx.subject = msg.Header.Get("Subject")
+	}
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		ctx.from = from.Name
+		if ctx.from == "" {
+			ctx.from = from.Address
+		}
+	}
+	ctx.date, _ = msg.Header.Date()
+
+	var attachments []emailAttachment
+	err = collectAttachments(mailHeader(msg.Header), msg.Body, &attachments)
+	if err != nil {
+		return err
+	}
+	for i, a := range attachments {
+		saveAttachment(path, i+1, a, ctx)
+	}
+	return nil
+}
+
+// mailHeader adapts mail.Header to the lookups shared with multipart parts.
+func mailHeader(h mail.Header) func(string) string {
+	return func(key string) string { return h.Get(key) }
+}
+
+// collectAttachments walks a MIME entity, descending into multipart
+// bodies, and gathers image and PDF parts large enough to matter.
+func collectAttachments(header func(string) string, body io.Reader, out *[]emailAttachment) error {
+	mediaType, params, err := mime.ParseMediaType(header("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := collectAttachments(part.Header.Get, part, out); err != nil {
+				return err
+			}
+		}
+	}
+
+	filename := params["name"]
+	if _, dparams, err := mime.ParseMediaType(header("Content-Disposition")); err == nil && dparams["filename"] != "" {
+		filename = dparams["filename"]
+	}
+	if decoded, err := headerDecoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+	filename = filepath.Base(filename)
+	if !strings.HasPrefix(mediaType, "image/") && mediaType != "application/pdf" && !isImageFile(filename) && !strings.EqualFold(filepath.Ext(filename), ".pdf") {
+		return nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(header("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body) // line breaks are skipped
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) < int64(emailMinSize) {
+		return nil
+	}
+	if filepath.Ext(filename) == "" || filename == "." || filename == "/" {
+		ext := ".pdf"
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 && mediaType != "application/pdf" {
+			ext = exts[0]
+		}
+		if mediaType == "image/jpeg" {
+			ext = ".jpg"
+		}
+		filename = "attachment" + ext
+	}
+	*out = append(*out, emailAttachment{filename: filename, data: data})
+	return nil
+}
+
+// saveAttachment names an attachment and saves it into emailOutDir. It is
+// written under its own name in a scratch directory first so templates see
+// the original name and the final rename stays on one filesystem.
+func saveAttachment(message string, n int, a emailAttachment, ctx emailContext) {
+	source := fmt.Sprintf("%s#%d (%s)", message, n, a.filename)
+	rec := fileRecord{Path: source, Action: actionSkipped}
+	defer func() { emitRecord(rec) }()
+	fail := func(err error) {
+		log.Printf("Error saving %s: %v", source, err)
+		rec.Action, rec.Error = actionError, err.Error()
+	}
+
+	scratch, err := os.MkdirTemp(emailOutDir, ".tell-me-more-")
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer os.RemoveAll(scratch)
+	tmp := filepath.Join(scratch, a.filename)
+	if err := os.WriteFile(tmp, a.data, 0o644); err != nil {
+		fail(err)
+		return
+	}
+	if !ctx.date.IsZero() {
+		os.Chtimes(tmp, ctx.date, ctx.date)
+	}
+
+	description, err := describeFile(tmp)
+	if err != nil {
+		log.Printf("Error describing %s: %v", source, err)
+		description = strings.TrimSuffix(a.filename, filepath.Ext(a.filename))
+	} else {
+		rec.Description = description
+	}
+	description += fmt.Sprintf("\n\nIt was attached to an email with the subject %q", ctx.subject)
+	if ctx.from != "" {
+		description += fmt.Sprintf(" from %s", ctx.from)
+	}
+	description += ". Use the subject and sender where they say what the attachment is, e.g. 'invoice_acme_march'."
+
+	name, err := suggestName(tmp, description)
+	if err == nil {
+		name, err = finalName(tmp, name)
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	rec.Name = sanitizeFileName(name)
+	if rec.Name == "" {
+		fail(fmt.Errorf("suggested name %q is empty after sanitizing", name))
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(a.filename))
+	target := filepath.Join(emailOutDir, rec.Name+ext)
+	// Mail threads repeat attachments; number them rather than overwrite.
+	for i := 2; ; i++ {
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			break
+		}
+		target = filepath.Join(emailOutDir, fmt.Sprintf("%s_%d%s", rec.Name, i, ext))
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		fail(err)
+		return
+	}
+	rec.Action, rec.NewPath = actionRenamed, target
+	fmt.Fprintf(msgOut, "Saved %s as %s\n", source, target)
+	audit("save-attachment", source, target, tmp)
+}
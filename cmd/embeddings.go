@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// The models the search index embeds text with, one per backend. They
+// can be changed in the config file under models: openai-embedding,
+// gemini-embedding and ollama-embedding.
+var (
+	openaiEmbeddingModel = string(openai.SmallEmbedding3)
+	geminiEmbeddingModel = "text-embedding-004"
+	ollamaEmbeddingModel = "nomic-embed-text"
+)
+
+// embeddingModel names the backend and model text is embedded with:
+// Ollama's with --provider ollama, Gemini's with --pipeline gemini, so
+// neither needs an OpenAI key, and OpenAI's otherwise.
+func embeddingModel() string {
+	switch {
+	case providerName == "ollama":
+		return "ollama/" + ollamaEmbeddingModel
+	case pipeline == "gemini":
+		return "gemini/" + geminiEmbeddingModel
+	}
+	return "openai/" + openaiEmbeddingModel
+}
+
+// embedText returns the embedding of text. query says whether text is a
+// search rather than something to find, which Gemini embeds differently.
+func embedText(text string, query bool) ([]float32, error) {
+	backend, model, _ := strings.Cut(embeddingModel(), "/")
+	switch backend {
+	case "ollama":
+		return retrying("ollama", false, func() ([]float32, error) {
+			return embedWithOllama(model, text)
+		})
+	case "gemini":
+		return withKey(geminiKeys, func(apiKey string) ([]float32, error) {
+			return embedWithGemini(apiKey, model, text, query)
+		})
+	}
+	return withKey(openaiKeys, func(apiKey string) ([]float32, error) {
+		return embedWithOpenAI(apiKey, model, text)
+	})
+}
+
+func embedWithOpenAI(apiKey, model, text string) ([]float32, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not set")
+	}
+	resp, err := openaiClient(apiKey).CreateEmbeddings(runCtx, openai.EmbeddingRequest{
+		Input: []string{text},
+		Model: openai.EmbeddingModel(model),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI embeddings API error: %v", err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no embedding in the OpenAI response")
+	}
+	recordUsage(text, model, resp.Usage.PromptTokens, 0)
+	return resp.Data[0].Embedding, nil
+}
+
+func embedWithGemini(apiKey, model, text string, query bool) ([]float32, error) {
+	client, err := geminiClient(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	em := client.EmbeddingModel(model)
+	em.TaskType = genai.TaskTypeRetrievalDocument
+	if query {
+		em.TaskType = genai.TaskTypeRetrievalQuery
+	}
+	resp, err := em.EmbedContent(runCtx, genai.Text(text))
+	if err != nil {
+		return nil, fmt.Errorf("Gemini embeddings API error: %v", err)
+	}
+	if resp.Embedding == nil || len(resp.Embedding.Values) == 0 {
+		return nil, fmt.Errorf("no embedding in the Gemini response")
+	}
+	return resp.Embedding.Values, nil
+}
+
+func embedWithOllama(model, text string) ([]float32, error) {
+	body, err := json.Marshal(map[string]string{"model": model, "prompt": text})
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimSuffix(ollamaURL(), "/generate") + "/embeddings"
+	req, err := http.NewRequestWithContext(runCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ollamaClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama API error (is `ollama serve` running?): %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Ollama API error: %s: %s (pull the model with `ollama pull %s`)", resp.Status, bytes.TrimSpace(msg), model)
+	}
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding Ollama response: %v", err)
+	}
+	if len(result.Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding in the Ollama response")
+	}
+	return result.Embedding, nil
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type epubMetadata struct {
+	Title  string
+	Author string
+	// spine lists the content documents in reading order.
+	spine []string
+}
+
+func isEPUBFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".epub")
+}
+
+// readEPUBMetadata reads the title, first author and spine from the
+// package document that META-INF/container.xml points at.
+func readEPUBMetadata(epubPath string) (epubMetadata, error) {
+	var meta epubMetadata
+	r, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return meta, err
+	}
+	defer r.Close()
+	return parseEPUB(&r.Reader)
+}
+
+func parseEPUB(r *zip.Reader) (epubMetadata, error) {
+	var meta epubMetadata
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := decodeZipXML(r, "META-INF/container.xml", &container); err != nil {
+		return meta, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return meta, fmt.Errorf("no package document in container.xml")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	var opf struct {
+		Titles   []string `xml:"metadata>title"`
+		Creators []string `xml:"metadata>creator"`
+		Manifest []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := decodeZipXML(r, opfPath, &opf); err != nil {
+		return meta, err
+	}
+	if len(opf.Titles) > 0 {
+		meta.Title = strings.TrimSpace(opf.Titles[0])
+	}
+	if len(opf.Creators) > 0 {
+		meta.Author = strings.TrimSpace(opf.Creators[0])
+	}
+	hrefs := map[string]string{}
+	for _, item := range opf.Manifest {
+		hrefs[item.ID] = path.Join(path.Dir(opfPath), item.Href)
+	}
+	for _, ref := range opf.Spine {
+		if href, ok := hrefs[ref.IDRef]; ok {
+			meta.spine = append(meta.spine, href)
+		}
+	}
+	return meta, nil
+}
+
+func decodeZipXML(r *zip.Reader, name string, v any) error {
+	f, err := r.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}
+
+// describeEPUB reports the metadata, and when the title is missing the
+// opening text of the book so the model can work out what it is.
+func describeEPUB(epubPath string) (string, error) {
+	r, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	meta, err := parseEPUB(&r.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("An EPUB ebook.\n")
+	if meta.Title != "" {
+		fmt.Fprintf(&b, "Title: %s\n", meta.Title)
+	}
+	if meta.Author != "" {
+		fmt.Fprintf(&b, "Author: %s\n", meta.Author)
+	}
+	if meta.Title == "" {
+		b.WriteString("Opening text:\n")
+		for _, doc := range meta.spine {
+			b.WriteString(htmlText(&r.Reader, doc))
+			if b.Len() > officeTextLimit {
+				break
+			}
+		}
+	}
+	text := b.String()
+	if len(text) > officeTextLimit {
+		text = strings.ToValidUTF8(text[:officeTextLimit], "") + "\n..."
+	}
+	return text, nil
+}
+
+// htmlText returns the text content of an XHTML document in the archive.
+func htmlText(r *zip.Reader, name string) string {
+	f, err := r.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	dec := xml.NewDecoder(f)
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var b strings.Builder
+	skip := 0
+	for b.Len() < officeTextLimit {
+		tok, err := dec.Token()
+		if err == io.EOF || err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "script" || t.Name.Local == "style" || t.Name.Local == "head" {
+				skip++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "script", "style", "head":
+				skip--
+			case "p", "div", "h1", "h2", "h3", "br", "li":
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if skip == 0 {
+				b.Write(t)
+			}
+		}
+	}
+	return collapseBlankLines(b.String()) + "\n"
+}
+
+// getNameForEPUB uses author_title straight from the metadata and only asks
+// the model when the book has no title.
+func getNameForEPUB(description string) (string, error) {
+	var title, author string
+	for _, line := range strings.Split(description, "\n") {
+		if v, ok := strings.CutPrefix(line, "Title: "); ok {
+			title = v
+		} else if v, ok := strings.CutPrefix(line, "Author: "); ok {
+			author = v
+		}
+	}
+	if title != "" {
+		if author != "" {
+			return author + "_" + title, nil
+		}
+		return title, nil
+	}
+
+	prompt := fmt.Sprintf(`You are a librarian naming ebook files.
+
+%s
+Work out the book's author and title and reply with just 'author_title', for example 'ursula_le_guin_the_dispossessed'. If you cannot tell the author, reply with just the title.
+
+Make sure the name suggestion is under 60 characters:`, description)
+	return askChatGPT(prompt)
+}
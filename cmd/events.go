@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	eventGap     time.Duration
+	eventSamples int
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events <dir>...",
+	Short: "Group photos taken close together into events and give each event one shared name",
+	Long: `Sorts the images by capture time and starts a new event wherever more than
+--gap passes between two of them. A few images from each event are described,
+the model names the event, and every member is renamed eventname_01,
+eventname_02, ... once you confirm.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if eventSamples < 1 {
+			return fmt.Errorf("--samples must be at least 1")
+		}
+		if err := loadTemplate(); err != nil {
+			return err
+		}
+		for _, dir := range args {
+			images, err := folderImages(dir)
+			if err != nil {
+				log.Printf("Error walking %s: %v", dir, err)
+				continue
+			}
+			for _, event := range clusterByTime(images, eventGap) {
+				if err := nameEvent(event); err != nil {
+					log.Printf("Error naming event starting at %s: %v", event.files[0], err)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	eventsCmd.Flags().DurationVar(&eventGap, "gap", 2*time.Hour, "time between photos that starts a new event")
+	eventsCmd.Flags().IntVar(&eventSamples, "samples", 4, "number of images to describe per event")
+	rootCmd.AddCommand(eventsCmd)
+}
+
+// timeCluster is a run of files whose capture times are within the gap of
+// each other, in capture order.
+type timeCluster struct {
+	files      []string
+	start, end time.Time
+}
+
+func clusterByTime(paths []string, gap time.Duration) []timeCluster {
+	type timed struct {
+		path string
+		t    time.Time
+	}
+	all := make([]timed, len(paths))
+	for i, path := range paths {
+		t, _ := captureTime(path)
+		all[i] = timed{path, t}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].t.Before(all[j].t) })
+
+	var clusters []timeCluster
+	for _, f := range all {
+		n := len(clusters)
+		if n == 0 || f.t.Sub(clusters[n-1].end) > gap {
+			clusters = append(clusters, timeCluster{start: f.t})
+			n++
+		}
+		clusters[n-1].files = append(clusters[n-1].files, f.path)
+		clusters[n-1].end = f.t
+	}
+	return clusters
+}
+
+func (c timeCluster) span() string {
+	if c.start.Format("2006-01-02") == c.end.Format("2006-01-02") {
+		return c.start.Format("January 2 2006")
+	}
+	return c.start.Format("January 2 2006") + " to " + c.end.Format("January 2 2006")
+}
+
+func nameEvent(event timeCluster) error {
+	fmt.Printf("Event of %d photos, %s\n", len(event.files), event.span())
+
+	var descriptions []string
+	for _, path := range sampleEvenly(event.files, eventSamples) {
+		description, err := getImageSentiment(path)
+		if err != nil {
+			log.Printf("Error getting labels from image %s: %v", path, err)
+			continue
+		}
+		descriptions = append(descriptions, description)
+	}
+	if len(descriptions) == 0 {
+		return fmt.Errorf("none of the sampled images could be described")
+	}
+	name, err := getEventName(descriptions, event.span())
+	if err != nil {
+		return err
+	}
+	name = sanitizeFileName(name)
+
+	width := len(fmt.Sprint(len(event.files)))
+	if width < 2 {
+		width = 2
+	}
+	names := make([]string, len(event.files))
+	for i, path := range event.files {
+		if names[i], err = finalName(path, fmt.Sprintf("%s_%0*d", name, width, i+1)); err != nil {
+			return err
+		}
+		fmt.Printf("  %s -> %s\n", path, names[i])
+	}
+	if !confirm("Do you want to rename these files?") {
+		return nil
+	}
+
+	for i, path := range event.files {
+		newPath, err := renameFile(path, names[i])
+		if err != nil {
+			log.Printf("Failed to rename %s: %v", path, err)
+			continue
+		}
+		fmt.Printf("Renamed %s to %s\n", path, newPath)
+	}
+	return nil
+}
+
+func getEventName(descriptions []string, when string) (string, error) {
+	var b strings.Builder
+	for i, d := range descriptions {
+		fmt.Fprintf(&b, "Photo %d:\n%s\n\n", i+1, d)
+	}
+	prompt := fmt.Sprintf(`You are a creative assistant that names events in a photo library.
+
+These are descriptions of a sample of photos all taken during one event, on %s:
+
+%s
+Work out what the event was and suggest a short, human-friendly name for it that every photo can share, for example 'sams_7th_birthday_party' or 'beach_day_at_brighton'.
+
+Make sure the name suggestion is under 30 characters, the fewer words the better:`, when, b.String())
+	return askChatGPT(prompt)
+}
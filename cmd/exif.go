@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// readEXIF decodes the EXIF block of a JPEG/TIFF/HEIC-style file.
+func readEXIF(path string) (*exif.Exif, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return exif.Decode(f)
+}
+
+// photoLocation returns the GPS position recorded in path's EXIF data.
+func photoLocation(path string) (lat, lon float64, ok bool) {
+	x, err := readEXIF(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	lat, lon, err = x.LatLong()
+	if err != nil || (lat == 0 && lon == 0) {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+const facesFile = "faces.json"
+
+var (
+	faceCmd       string
+	faceThreshold float64
+
+	facesOnce  sync.Once
+	facesStore faceStore
+	facesErr   error
+)
+
+// faceStore is everything known about faces. Embeddings are produced by
+// --face-cmd on this machine and are only ever stored here.
+type faceStore struct {
+	Faces []face `json:"faces"`
+	// Clusters maps a cluster ID to its running centroid and label.
+	Clusters map[int]*faceCluster `json:"clusters"`
+	NextID   int                  `json:"next_id"`
+}
+
+type face struct {
+	Path      string    `json:"path"`
+	Embedding []float64 `json:"embedding"`
+	Cluster   int       `json:"cluster"`
+}
+
+type faceCluster struct {
+	Label    string    `json:"label,omitempty"`
+	Centroid []float64 `json:"centroid"`
+	Count    int       `json:"count"`
+}
+
+var facesCmd = &cobra.Command{
+	Use:   "faces",
+	Short: "Cluster faces locally and label them for {{.People}}",
+	Long: `Faces are found by --face-cmd, a local program that is run with an image path
+and prints a JSON array of face embeddings, e.g. [[0.12, -0.03, ...], ...].
+Nothing is sent anywhere; embeddings and labels stay in the state directory.
+
+  faces scan <dir>   find faces and group them into clusters
+  faces label        name each unlabelled cluster once ("Mum", "Alex")
+  faces list         show the clusters and their labels`,
+}
+
+var facesScanCmd = &cobra.Command{
+	Use:   "scan <dir>...",
+	Short: "Find and cluster the faces in the images under dir",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadFaces()
+		if err != nil {
+			return err
+		}
+		scanned := map[string]bool{}
+		for _, f := range store.Faces {
+			scanned[f.Path] = true
+		}
+		for _, dir := range args {
+			images, err := folderImages(dir)
+			if err != nil {
+				log.Printf("Error walking %s: %v", dir, err)
+				continue
+			}
+			for _, path := range images {
+				abs, _ := filepath.Abs(path)
+				if scanned[abs] {
+					continue
+				}
+				embeddings, err := faceEmbeddings(abs)
+				if err != nil {
+					log.Printf("Error finding faces in %s: %v", path, err)
+					continue
+				}
+				for _, e := range embeddings {
+					store.add(abs, e)
+				}
+				fmt.Printf("%s: %d faces\n", path, len(embeddings))
+			}
+		}
+		return saveState(facesFile, store)
+	},
+}
+
+var facesLabelCmd = &cobra.Command{
+	Use:   "label",
+	Short: "Give each unlabelled face cluster a name",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadFaces()
+		if err != nil {
+			return err
+		}
+		if assumeYes || noInput {
+			return fmt.Errorf("labelling needs answers; run it without --yes or --no-input")
+		}
+		in := bufio.NewScanner(os.Stdin)
+		for _, id := range store.clusterIDs() {
+			c := store.Clusters[id]
+			if c.Label != "" {
+				continue
+			}
+			fmt.Printf("Cluster %d (%d faces), for example:\n", id, c.Count)
+			for _, path := range store.examples(id, 3) {
+				fmt.Printf("  %s\n", path)
+			}
+			fmt.Print("Who is this? (empty to skip): ")
+			if !in.Scan() {
+				break
+			}
+			c.Label = strings.TrimSpace(in.Text())
+		}
+		return saveState(facesFile, store)
+	},
+}
+
+var facesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the face clusters",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadFaces()
+		if err != nil {
+			return err
+		}
+		for _, id := range store.clusterIDs() {
+			c := store.Clusters[id]
+			label := c.Label
+			if label == "" {
+				label = "(unlabelled)"
+			}
+			fmt.Printf("%d\t%s\t%d faces\n", id, label, c.Count)
+		}
+		return nil
+	},
+}
+
+func init() {
+	facesCmd.PersistentFlags().Float64Var(&faceThreshold, "face-threshold", 0.6, "largest embedding distance still counted as the same person")
+	rootCmd.PersistentFlags().StringVar(&faceCmd, "face-cmd", "", "local command that prints face embeddings for an image as JSON")
+	facesCmd.AddCommand(facesScanCmd, facesLabelCmd, facesListCmd)
+	rootCmd.AddCommand(facesCmd)
+	purgers[facesFile] = purgeFaces
+}
+
+// purgeFaces drops the matching faces and rebuilds the centroids of the
+// clusters they were in from the faces that are left, so nothing derived
+// from a purged image stays behind.
+func purgeFaces(t purgeTarget) (int, error) {
+	var store faceStore
+	if err := loadState(facesFile, &store); err != nil || len(store.Faces) == 0 {
+		return 0, err
+	}
+	kept := store.Faces[:0]
+	touched := map[int]bool{}
+	for _, f := range store.Faces {
+		if t.matches(f.Path) {
+			touched[f.Cluster] = true
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	n := len(store.Faces) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	store.Faces = kept
+	for id := range touched {
+		c := store.Clusters[id]
+		if c == nil {
+			continue
+		}
+		c.Count = 0
+		for i := range c.Centroid {
+			c.Centroid[i] = 0
+		}
+		for _, f := range kept {
+			if f.Cluster != id {
+				continue
+			}
+			c.Count++
+			for i := range c.Centroid {
+				c.Centroid[i] += (f.Embedding[i] - c.Centroid[i]) / float64(c.Count)
+			}
+		}
+		if c.Count == 0 {
+			delete(store.Clusters, id)
+		}
+	}
+	return n, saveState(facesFile, store)
+}
+
+func loadFaces() (*faceStore, error) {
+	facesOnce.Do(func() {
+		facesErr = loadState(facesFile, &facesStore)
+		if facesStore.Clusters == nil {
+			facesStore.Clusters = map[int]*faceCluster{}
+		}
+	})
+	return &facesStore, facesErr
+}
+
+// faceEmbeddings runs --face-cmd on path.
+func faceEmbeddings(path string) ([][]float64, error) {
+	args := strings.Fields(faceCmd)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("--face-cmd is not set")
+	}
+	out, err := exec.Command(args[0], append(args[1:], path)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %v", args[0], err)
+	}
+	var embeddings [][]float64
+	if err := json.Unmarshal(out, &embeddings); err != nil {
+		return nil, fmt.Errorf("parsing %s output: %v", args[0], err)
+	}
+	return embeddings, nil
+}
+
+// add files an embedding under the nearest cluster, or a new one when no
+// cluster is within faceThreshold.
+func (s *faceStore) add(path string, e []float64) {
+	id, ok := s.nearest(e)
+	if !ok {
+		id = s.NextID
+		s.NextID++
+		s.Clusters[id] = &faceCluster{Centroid: append([]float64(nil), e...)}
+	}
+	c := s.Clusters[id]
+	c.Count++
+	for i := range c.Centroid {
+		c.Centroid[i] += (e[i] - c.Centroid[i]) / float64(c.Count)
+	}
+	s.Faces = append(s.Faces, face{Path: path, Embedding: e, Cluster: id})
+}
+
+func (s *faceStore) nearest(e []float64) (int, bool) {
+	best, bestDist := 0, math.Inf(1)
+	for id, c := range s.Clusters {
+		if d := euclidean(e, c.Centroid); d < bestDist {
+			best, bestDist = id, d
+		}
+	}
+	return best, bestDist <= faceThreshold
+}
+
+func (s *faceStore) clusterIDs() []int {
+	ids := make([]int, 0, len(s.Clusters))
+	for id := range s.Clusters {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *faceStore) examples(id, n int) []string {
+	var paths []string
+	for _, f := range s.Faces {
+		if f.Cluster == id && len(paths) < n {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+// photoPeople returns the labels of the people in path, joined for use in
+// a name ("mum_and_alex"). Files that were not scanned are run through
+// --face-cmd when it is set and matched against the existing clusters.
+func photoPeople(path string) string {
+	store, err := loadFaces()
+	if err != nil {
+		log.Printf("Error loading faces: %v", err)
+		return ""
+	}
+	abs, _ := filepath.Abs(path)
+
+	var ids []int
+	for _, f := range store.Faces {
+		if f.Path == abs {
+			ids = append(ids, f.Cluster)
+		}
+	}
+	if len(ids) == 0 && faceCmd != "" {
+		embeddings, err := faceEmbeddings(abs)
+		if err != nil {
+			log.Printf("Error finding faces in %s: %v", path, err)
+		}
+		for _, e := range embeddings {
+			if id, ok := store.nearest(e); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	var people []string
+	for _, id := range ids {
+		if c := store.Clusters[id]; c != nil && c.Label != "" && !seen[c.Label] {
+			seen[c.Label] = true
+			people = append(people, strings.ToLower(c.Label))
+		}
+	}
+	return joinPeople(people)
+}
+
+func joinPeople(people []string) string {
+	switch len(people) {
+	case 0:
+		return ""
+	case 1:
+		return people[0]
+	}
+	return strings.Join(people[:len(people)-1], "_") + "_and_" + people[len(people)-1]
+}
+
+func euclidean(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return math.Inf(1)
+	}
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
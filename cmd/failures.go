@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// errFilesFailed ends a run in which some files could not be handled,
+// after the summary has said which.
+var errFilesFailed = errors.New("some files failed")
+
+var (
+	failuresMu sync.Mutex
+	failures   []fileRecord
+)
+
+// recordFailure keeps rec for the summary at the end of the run.
+func recordFailure(rec fileRecord) {
+	failuresMu.Lock()
+	failures = append(failures, rec)
+	failuresMu.Unlock()
+}
+
+// reportFailures lists the files that failed, each with its error, and
+// returns errFilesFailed if there were any.
+func reportFailures() error {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	if len(failures) == 0 {
+		return nil
+	}
+	if len(failures) == 1 {
+		fmt.Fprintln(msgOut, "\n1 file failed:")
+	} else {
+		fmt.Fprintf(msgOut, "\n%d files failed:\n", len(failures))
+	}
+	for _, rec := range failures {
+		fmt.Fprintf(msgOut, "  %s: %s\n", rec.Path, rec.Error)
+	}
+	return errFilesFailed
+}
+
+// runResult ends cmd's run with the failure summary, keeping cobra from
+// adding the usage text to it.
+func runResult(cmd *cobra.Command) error {
+	err := reportFailures()
+	if err != nil {
+		cmd.SilenceUsage, cmd.SilenceErrors = true, true
+	}
+	return err
+}
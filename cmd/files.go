@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// filesFrom is --files-from: a file, or - for stdin, listing paths to
+// handle one per line or NUL-separated.
+var filesFrom string
+
+func init() {
+	rootCmd.Flags().StringVar(&filesFrom, "files-from", "", "also handle the files listed in this file, or - for stdin, one per line or NUL-separated (find -print0)")
+}
+
+// readFileList returns the paths listed by --files-from. After reading a
+// list from stdin, prompts are read from the terminal instead.
+func readFileList() ([]string, error) {
+	if filesFrom == "" {
+		return nil, nil
+	}
+	var data []byte
+	var err error
+	if filesFrom == "-" {
+		data, err = io.ReadAll(os.Stdin)
+		reopenTerminal()
+	} else {
+		data, err = os.ReadFile(filesFrom)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading --files-from: %v", err)
+	}
+	sep := []byte("\n")
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = []byte{0}
+	}
+	var paths []string
+	for _, p := range bytes.Split(data, sep) {
+		if path := strings.TrimSuffix(string(p), "\r"); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// reopenTerminal points stdin at the controlling terminal, if there is
+// one, so questions can still be answered once stdin has been used up.
+func reopenTerminal() {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	if tty, err := os.Open(name); err == nil {
+		os.Stdin = tty
+	}
+}
+
+// forEachTarget hands handle the suggestions for every local argument: the
+// target files under each directory, and files named directly, which are
+// handled whatever their names.
+func forEachTarget(args []string, handle func(suggestion)) {
+	var files []string
+	flush := func() {
+		if len(files) > 0 {
+			suggestFiles(files, handle)
+			files = nil
+		}
+	}
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		flush()
+		walkSuggestions(arg, handle)
+	}
+	flush()
+}
+
+// suggestFiles is walkSuggestions for a list of files.
+func suggestFiles(files []string, handle func(suggestion)) {
+	depth := tuneForFilesystem(filepath.Dir(files[0]))
+	if concurrency > 0 {
+		depth = concurrency - 1
+	}
+	paths := make(chan string)
+	go func() {
+		defer close(paths)
+		for _, path := range files {
+			if fileBusy(path) && !waitUntilReady(path) {
+				log.Printf("Skipping %s: still being written or locked", path)
+				continue
+			}
+			paths <- path
+		}
+	}()
+	for s := range prefetch(paths, depth) {
+		handle(s)
+	}
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// The extended attributes Finder and Spotlight read tags and comments from.
+const (
+	finderTagsAttr    = "com.apple.metadata:_kMDItemUserTags"
+	finderCommentAttr = "com.apple.metadata:kMDItemFinderComment"
+)
+
+// finderTags is --finder-tags: after renaming, add the tags as Finder tags
+// and the description as the Finder comment.
+var finderTags bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&finderTags, "finder-tags", false, "after renaming, add the tags as Finder tags and the description as the Finder comment, for Spotlight (macOS)")
+}
+
+// writeFinderTags adds s's tags to the Finder tags of the file now at
+// path, keeping those it already has, and makes its description the
+// Finder comment.
+func writeFinderTags(path string, s suggestion) error {
+	if !finderTags {
+		return nil
+	}
+	if len(s.tags) > 0 {
+		var existing []string
+		if data, err := getXattr(path, finderTagsAttr); err != nil {
+			return err
+		} else if data != nil {
+			if existing, err = decodeStringsPlist(data); err != nil {
+				return fmt.Errorf("reading the Finder tags: %v", err)
+			}
+		}
+		if tags := mergeFinderTags(existing, s.tags); len(tags) > len(existing) {
+			if err := setXattr(path, finderTagsAttr, encodePlist(tags)); err != nil {
+				return err
+			}
+		}
+	}
+	if s.description != "" {
+		return setXattr(path, finderCommentAttr, encodePlist(s.description))
+	}
+	return nil
+}
+
+// mergeFinderTags appends the tags that are not in existing yet. Finder
+// keeps a tag's colour after a newline in its name, as in "Red\n6".
+func mergeFinderTags(existing, tags []string) []string {
+	have := map[string]bool{}
+	for _, t := range existing {
+		name, _, _ := strings.Cut(t, "\n")
+		have[strings.ToLower(name)] = true
+	}
+	merged := existing
+	for _, t := range tags {
+		if !have[strings.ToLower(t)] {
+			have[strings.ToLower(t)] = true
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// encodePlist writes v, a string or a list of them, as the binary
+// property list the metadata attributes hold.
+func encodePlist(v any) []byte {
+	var objects [][]byte
+	switch v := v.(type) {
+	case string:
+		objects = append(objects, plistString(v))
+	case []string:
+		array := plistHeader(0xA, len(v))
+		for i := range v {
+			array = binary.BigEndian.AppendUint16(array, uint16(i+1))
+		}
+		objects = append(objects, array)
+		for _, s := range v {
+			objects = append(objects, plistString(s))
+		}
+	}
+	out := []byte("bplist00")
+	var offsets []uint64
+	for _, o := range objects {
+		offsets = append(offsets, uint64(len(out)))
+		out = append(out, o...)
+	}
+	table := uint64(len(out))
+	for _, off := range offsets {
+		out = binary.BigEndian.AppendUint64(out, off)
+	}
+	// Trailer: unused bytes, offset size, object reference size, object
+	// count, top object and where the offset table starts.
+	out = append(out, 0, 0, 0, 0, 0, 0, 8, 2)
+	out = binary.BigEndian.AppendUint64(out, uint64(len(objects)))
+	out = binary.BigEndian.AppendUint64(out, 0)
+	return binary.BigEndian.AppendUint64(out, table)
+}
+
+func plistString(s string) []byte {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return append(plistHeader(0x5, len(s)), s...)
+	}
+	units := utf16.Encode([]rune(s))
+	b := plistHeader(0x6, len(units))
+	for _, u := range units {
+		b = binary.BigEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// plistHeader is the marker byte of an object of kind holding n items,
+// followed by n itself when it doesn't fit in the marker.
+func plistHeader(kind byte, n int) []byte {
+	if n < 15 {
+		return []byte{kind<<4 | byte(n)}
+	}
+	b := []byte{kind<<4 | 0xF, 0x12}
+	return binary.BigEndian.AppendUint32(b, uint32(n))
+}
+
+// decodeStringsPlist reads a binary property list holding a list of
+// strings, which is all the tags attribute ever holds.
+func decodeStringsPlist(b []byte) ([]string, error) {
+	if len(b) < 8+32 || string(b[:8]) != "bplist00" {
+		return nil, fmt.Errorf("not a binary property list")
+	}
+	trailer := b[len(b)-32:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	count := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	table := binary.BigEndian.Uint64(trailer[24:])
+	if offsetSize == 0 || refSize == 0 || table+count*uint64(offsetSize) > uint64(len(b)) {
+		return nil, fmt.Errorf("bad property list trailer")
+	}
+	offset := func(ref uint64) (int, error) {
+		if ref >= count {
+			return 0, fmt.Errorf("bad object reference")
+		}
+		at := int(table) + int(ref)*offsetSize
+		off := plistUint(b[at : at+offsetSize])
+		if off >= uint64(len(b)) {
+			return 0, fmt.Errorf("bad object offset")
+		}
+		return int(off), nil
+	}
+	at, err := offset(top)
+	if err != nil {
+		return nil, err
+	}
+	kind, n, at, err := plistObject(b, at)
+	if err != nil {
+		return nil, err
+	}
+	if kind != 0xA || at+n*refSize > len(b) {
+		return nil, fmt.Errorf("not a list")
+	}
+	var out []string
+	for i := 0; i < n; i++ {
+		ref := plistUint(b[at+i*refSize : at+(i+1)*refSize])
+		sat, err := offset(ref)
+		if err != nil {
+			return nil, err
+		}
+		kind, n, sat, err := plistObject(b, sat)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case kind == 0x5 && sat+n <= len(b):
+			out = append(out, string(b[sat:sat+n]))
+		case kind == 0x6 && sat+2*n <= len(b):
+			units := make([]uint16, n)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(b[sat+2*j:])
+			}
+			out = append(out, string(utf16.Decode(units)))
+		default:
+			return nil, fmt.Errorf("not a list of strings")
+		}
+	}
+	return out, nil
+}
+
+// plistObject reads the marker at b[at], returning the object's kind, its
+// length and where its content starts.
+func plistObject(b []byte, at int) (kind byte, n, start int, err error) {
+	kind, n = b[at]>>4, int(b[at]&0xF)
+	at++
+	if n == 0xF {
+		if at >= len(b) || b[at]>>4 != 0x1 {
+			return 0, 0, 0, fmt.Errorf("bad object length")
+		}
+		size := 1 << (b[at] & 0xF)
+		at++
+		if at+size > len(b) {
+			return 0, 0, 0, fmt.Errorf("bad object length")
+		}
+		n = int(plistUint(b[at : at+size]))
+		at += size
+	}
+	return kind, n, at, nil
+}
+
+func plistUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
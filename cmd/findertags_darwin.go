@@ -0,0 +1,5 @@
+package cmd
+
+func validateFinderTags() error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !darwin
+
+package cmd
+
+import "errors"
+
+func validateFinderTags() error {
+	if finderTags {
+		return errors.New("--finder-tags only works on macOS")
+	}
+	return nil
+}
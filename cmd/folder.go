@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var folderSamples int
+
+var nameFolderCmd = &cobra.Command{
+	Use:   "name-folder <dir>...",
+	Short: "Describe a sample of the images in a folder and rename the folder itself",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if folderSamples < 1 {
+			return fmt.Errorf("--samples must be at least 1")
+		}
+		for _, dir := range args {
+			if err := nameFolder(dir); err != nil {
+				log.Printf("Error naming %s: %v", dir, err)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	nameFolderCmd.Flags().IntVar(&folderSamples, "samples", 5, "number of images to describe per folder")
+	rootCmd.AddCommand(nameFolderCmd)
+}
+
+func nameFolder(dir string) error {
+	images, err := folderImages(dir)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("no images found")
+	}
+	fmt.Printf("Found %d images in %s\n", len(images), dir)
+
+	var descriptions []string
+	for _, path := range sampleEvenly(images, folderSamples) {
+		description, err := getImageSentiment(path)
+		if err != nil {
+			log.Printf("Error getting labels from image %s: %v", path, err)
+			continue
+		}
+		descriptions = append(descriptions, description)
+	}
+	if len(descriptions) == 0 {
+		return fmt.Errorf("none of the sampled images could be described")
+	}
+
+	name, err := getFolderName(descriptions, dateSpan(images))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Suggested folder name: %s\n", name)
+	if !confirm("Do you want to rename the folder?") {
+		return nil
+	}
+
+	newPath, err := renameDir(dir, name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Renamed %s to %s\n", dir, newPath)
+	return nil
+}
+
+// folderImages lists the images anywhere under dir, sorted by path.
+func folderImages(dir string) ([]string, error) {
+	var images []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && skipDir(dir, path) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && isImageFile(path) {
+			images = append(images, path)
+		}
+		return nil
+	})
+	sort.Strings(images)
+	return images, err
+}
+
+// sampleEvenly picks n items spread across paths, so a folder of one trip
+// isn't summarised from its first five photos alone.
+func sampleEvenly(paths []string, n int) []string {
+	if len(paths) <= n {
+		return paths
+	}
+	sample := make([]string, n)
+	for i := range sample {
+		sample[i] = paths[i*len(paths)/n]
+	}
+	return sample
+}
+
+// dateSpan describes when the files were last modified, as a hint for
+// names like rome_trip_june_2023.
+func dateSpan(paths []string) string {
+	var first, last time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		t := info.ModTime()
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	if first.IsZero() {
+		return ""
+	}
+	if first.Format("2006-01-02") == last.Format("2006-01-02") {
+		return first.Format("January 2 2006")
+	}
+	return first.Format("January 2 2006") + " to " + last.Format("January 2 2006")
+}
+
+func getFolderName(descriptions []string, dates string) (string, error) {
+	var b strings.Builder
+	for i, d := range descriptions {
+		fmt.Fprintf(&b, "Image %d:\n%s\n\n", i+1, d)
+	}
+	when := ""
+	if dates != "" {
+		when = fmt.Sprintf("\nThe files date from %s.\n", dates)
+	}
+	prompt := fmt.Sprintf(`You are a creative assistant that generates human-like names for folders of images.
+
+Here are descriptions of a sample of the images in one folder:
+
+%s%s
+Work out what ties these images together (an event, a trip, a project) and suggest a short, human-friendly folder name for the whole collection, for example 'rome_trip_june_2023'. Mention the date only if it helps.
+
+Make sure the name suggestion is under 40 characters, the fewer words the better:`, b.String(), when)
+	return askChatGPT(prompt)
+}
+
+// renameDir renames dir to the sanitized name within the same parent.
+func renameDir(dir, name string) (string, error) {
+	clean := sanitizeFileName(name)
+	if clean == "" {
+		return "", fmt.Errorf("suggested name %q is empty after sanitizing", name)
+	}
+	newPath := filepath.Join(filepath.Dir(filepath.Clean(dir)), clean)
+	if _, err := os.Stat(newPath); err == nil {
+		return "", fmt.Errorf("%s already exists", newPath)
+	}
+	if err := renameRetrying(dir, newPath); err != nil {
+		return "", err
+	}
+	audit("rename-folder", absPath(dir), absPath(newPath), "")
+	journal(dir, newPath, "", nil)
+	return newPath, nil
+}
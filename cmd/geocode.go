@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultGeocoder = "https://nominatim.openstreetmap.org/reverse?format=jsonv2&zoom=10&lat={lat}&lon={lon}"
+	placeCacheFile  = "places.json"
+	// geonamesRadiusKm is how far the nearest offline place may be before
+	// we'd rather have no place at all.
+	geonamesRadiusKm = 50
+	// nominatimInterval is the gap OpenStreetMap's usage policy asks for
+	// between requests to its public Nominatim.
+	nominatimInterval = time.Second
+)
+
+var (
+	geocoderURL  string
+	geonamesFile string
+
+	placeCacheMu sync.Mutex
+	placeCache   map[string]string
+	// placeLookups has workers that need the same place wait for one
+	// lookup rather than each making their own.
+	placeLookups singleflight.Group
+
+	geonamesOnce   sync.Once
+	geonamesPlaces []geoPlace
+
+	// nominatimMu spaces out the requests to the public Nominatim across
+	// workers.
+	nominatimMu   sync.Mutex
+	lastNominatim time.Time
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&geocoderURL, "geocoder", defaultGeocoder, "Nominatim-compatible reverse geocoding URL with {lat} and {lon} placeholders")
+	rootCmd.PersistentFlags().StringVar(&geonamesFile, "geonames", "", "offline GeoNames dump (e.g. cities500.txt) to use instead of --geocoder")
+}
+
+type geoPlace struct {
+	name     string
+	lat, lon float64
+}
+
+// photoPlace resolves the photo's GPS position to a city-level place name,
+// or "" when it has no position or nothing could be found.
+func photoPlace(path string) string {
+	lat, lon, ok := photoLocation(path)
+	if !ok {
+		return ""
+	}
+	key := fmt.Sprintf("%.3f,%.3f", lat, lon)
+	if place, ok := cachedPlace(key); ok {
+		return place
+	}
+
+	// The cache is not locked during the lookup, so other workers carry on.
+	place, err, _ := placeLookups.Do(key, func() (any, error) {
+		if place, ok := cachedPlace(key); ok {
+			return place, nil // a lookup that had just finished
+		}
+		var place string
+		var err error
+		if geonamesFile != "" {
+			place, err = nearestGeoName(lat, lon)
+		} else {
+			place, err = reverseGeocode(lat, lon)
+		}
+		if err != nil {
+			return "", err
+		}
+		cachePlace(key, place)
+		return place, nil
+	})
+	if err != nil {
+		log.Printf("Error finding the place for %s: %v", path, err)
+		return ""
+	}
+	return place.(string)
+}
+
+// cachedPlace looks key up in the place cache, loading it on first use.
+func cachedPlace(key string) (string, bool) {
+	placeCacheMu.Lock()
+	defer placeCacheMu.Unlock()
+	if placeCache == nil {
+		placeCache = map[string]string{}
+		if err := loadState(placeCacheFile, &placeCache); err != nil {
+			log.Printf("Error loading place cache: %v", err)
+		}
+	}
+	place, ok := placeCache[key]
+	return place, ok
+}
+
+func cachePlace(key, place string) {
+	placeCacheMu.Lock()
+	defer placeCacheMu.Unlock()
+	placeCache[key] = place
+	if err := saveState(placeCacheFile, placeCache); err != nil {
+		log.Printf("Error saving place cache: %v", err)
+	}
+}
+
+func reverseGeocode(lat, lon float64) (string, error) {
+	url := strings.NewReplacer(
+		"{lat}", strconv.FormatFloat(lat, 'f', 6, 64),
+		"{lon}", strconv.FormatFloat(lon, 'f', 6, 64),
+	).Replace(geocoderURL)
+	if strings.HasPrefix(url, "https://nominatim.openstreetmap.org/") {
+		nominatimMu.Lock()
+		defer nominatimMu.Unlock()
+		select {
+		case <-time.After(time.Until(lastNominatim.Add(nominatimInterval))):
+		case <-runCtx.Done():
+			return "", runCtx.Err()
+		}
+		defer func() { lastNominatim = time.Now() }()
+	}
+	req, err := http.NewRequestWithContext(runCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	// Nominatim's usage policy requires an identifying User-Agent.
+	req.Header.Set("User-Agent", "tell-me-more (https://github.com/coldfrey/tell-me-more)")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("geocoder returned %s", resp.Status)
+	}
+
+	var result struct {
+		Name    string            `json:"name"`
+		Address map[string]string `json:"address"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	for _, key := range []string{"city", "town", "village", "municipality", "county", "state"} {
+		if v := result.Address[key]; v != "" {
+			return v, nil
+		}
+	}
+	return result.Name, nil
+}
+
+// nearestGeoName finds the closest populated place in the --geonames dump.
+func nearestGeoName(lat, lon float64) (string, error) {
+	var loadErr error
+	geonamesOnce.Do(func() { geonamesPlaces, loadErr = loadGeoNames(geonamesFile) })
+	if loadErr != nil {
+		return "", loadErr
+	}
+
+	best, bestDist := "", math.Inf(1)
+	for _, p := range geonamesPlaces {
+		if d := haversineKm(lat, lon, p.lat, p.lon); d < bestDist {
+			best, bestDist = p.name, d
+		}
+	}
+	if bestDist > geonamesRadiusKm {
+		return "", nil
+	}
+	return best, nil
+}
+
+// loadGeoNames reads the tab-separated GeoNames format: the ASCII name is
+// column 3 and latitude/longitude are columns 5 and 6.
+func loadGeoNames(path string) ([]geoPlace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var places []geoPlace
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		cols := strings.Split(scanner.Text(), "\t")
+		if len(cols) < 6 {
+			continue
+		}
+		lat, err1 := strconv.ParseFloat(cols[4], 64)
+		lon, err2 := strconv.ParseFloat(cols[5], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		places = append(places, geoPlace{name: cols[2], lat: lat, lon: lon})
+	}
+	return places, scanner.Err()
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// gifFrames is how many frames of an animated GIF are looked at: the
+// first, the middle and the last.
+const gifFrames = 3
+
+// gifFrameCounts keeps how many frames each GIF has, since deciding how
+// to name one asks more than once.
+var gifFrameCounts sync.Map
+
+// isAnimatedGIF reports whether path is a GIF with more than one frame.
+func isAnimatedGIF(path string) bool {
+	if !strings.EqualFold(filepath.Ext(path), ".gif") {
+		return false
+	}
+	if n, ok := gifFrameCounts.Load(path); ok {
+		return n.(int) > 1
+	}
+	n := 0
+	if g, err := decodeGIF(path); err == nil {
+		n = len(g.Image)
+	}
+	gifFrameCounts.Store(path, n)
+	return n > 1
+}
+
+func decodeGIF(path string) (*gif.GIF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return gif.DecodeAll(f)
+}
+
+// describeGIF describes an animated GIF from its first, middle and last
+// frames on one contact sheet, so the model sees what the animation does
+// rather than whichever frame a decoder stops at.
+func describeGIF(path string) (string, error) {
+	g, err := decodeGIF(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %v", path, err)
+	}
+	frames := gifSample(g, gifFrames)
+	sheet, err := contactSheet(frames)
+	if err != nil {
+		return "", err
+	}
+	delay := 0
+	for _, d := range g.Delay {
+		delay += d
+	}
+	length := ""
+	if delay > 0 {
+		length = fmt.Sprintf(", %.1f-second", float64(delay)/100)
+	}
+	prompt := fmt.Sprintf("These are %d frames spread from the first to the last of the %d frames of an animated GIF%s, in order from left to right and top to bottom. Describe what the animation shows and what moves or changes in it, in as much detail as possible, including any text that matters. Describe the animation, not the grid of frames.", len(frames), len(g.Image), length) + langPrompt()
+	return askGeminiAboutImage(sheet, "image/jpeg", prompt)
+}
+
+// gifSample plays g on a canvas, honouring each frame's disposal, and
+// returns n frames spread from the first to the last as they are seen.
+// A GIF's frames are often only the part that changed.
+func gifSample(g *gif.GIF, n int) []image.Image {
+	last := len(g.Image) - 1
+	want := map[int]bool{}
+	for i := 0; i < n; i++ {
+		want[last*i/max(1, n-1)] = true
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+	var frames []image.Image
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if want[i] {
+			// On white, since the sheet is a JPEG.
+			snapshot := image.NewRGBA(bounds)
+			draw.Draw(snapshot, bounds, image.White, image.Point{}, draw.Src)
+			draw.Draw(snapshot, bounds, canvas, bounds.Min, draw.Over)
+			frames = append(frames, snapshot)
+		}
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// HEIC files start with an ftyp box naming one of these brands.
+var heicBrands = []string{"heic", "heix", "hevc", "hevx", "mif1", "msf1"}
+
+func init() {
+	for _, brand := range heicBrands {
+		image.RegisterFormat("heic", "????ftyp"+brand, decodeHEIC, decodeHEICConfig)
+	}
+}
+
+func isHEICFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".heic", ".heif":
+		return true
+	}
+	return false
+}
+
+// heicConverters are the tools HEIC images are converted to JPEG with,
+// in the order they are tried: macOS's own, libheif's, then ImageMagick.
+var heicConverters = []struct {
+	tool string
+	args func(in, out string) []string
+}{
+	{"sips", func(in, out string) []string { return []string{"-s", "format", "jpeg", in, "--out", out} }},
+	{"heif-convert", func(in, out string) []string { return []string{"-q", "92", in, out} }},
+	{"magick", func(in, out string) []string { return []string{in, out} }},
+	{"convert", func(in, out string) []string { return []string{in, out} }},
+}
+
+// heicCache keeps the last few conversions, since the same photo is
+// decoded by several steps before it is uploaded.
+var (
+	heicCacheMu sync.Mutex
+	heicCache   []heicConversion
+)
+
+const heicCacheSize = 4
+
+type heicConversion struct {
+	sum  [sha256.Size]byte
+	jpeg []byte
+}
+
+// heicToJPEG converts a HEIC image to a JPEG with the first converter on
+// the PATH. Go has no HEVC decoder of its own.
+func heicToJPEG(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	heicCacheMu.Lock()
+	for _, c := range heicCache {
+		if c.sum == sum {
+			heicCacheMu.Unlock()
+			return c.jpeg, nil
+		}
+	}
+	heicCacheMu.Unlock()
+
+	dir, err := os.MkdirTemp("", "tell-me-more-heic-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "in.heic"), filepath.Join(dir, "out.jpg")
+	if err := os.WriteFile(in, data, 0o600); err != nil {
+		return nil, err
+	}
+	for _, c := range heicConverters {
+		if _, err := exec.LookPath(c.tool); err != nil {
+			continue
+		}
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(runCtx, c.tool, c.args(in, out)...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("converting HEIC with %s: %v: %s", c.tool, err, strings.TrimSpace(stderr.String()))
+		}
+		converted, err := os.ReadFile(out)
+		if err != nil {
+			return nil, err
+		}
+		heicCacheMu.Lock()
+		heicCache = append(heicCache, heicConversion{sum, converted})
+		if len(heicCache) > heicCacheSize {
+			heicCache = heicCache[1:]
+		}
+		heicCacheMu.Unlock()
+		return converted, nil
+	}
+	return nil, fmt.Errorf("reading HEIC images needs sips (macOS), heif-convert (libheif) or ImageMagick on the PATH")
+}
+
+func decodeHEIC(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := heicToJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	return jpeg.Decode(bytes.NewReader(converted))
+}
+
+// heicHeaderLimit is how much of a HEIC file is searched for its image
+// sizes, which sit in the metadata at the start.
+const heicHeaderLimit = 256 << 10
+
+// decodeHEICConfig reads a HEIC image's size from its ispe boxes without
+// converting it. A HEIC holds the sizes of its tiles and thumbnail too;
+// the largest is the whole image.
+func decodeHEICConfig(r io.Reader) (image.Config, error) {
+	head, err := io.ReadAll(io.LimitReader(r, heicHeaderLimit))
+	if err != nil {
+		return image.Config{}, err
+	}
+	var w, h int
+	for at := 0; ; {
+		i := bytes.Index(head[at:], []byte("ispe"))
+		if i < 0 {
+			break
+		}
+		at += i + 4
+		// version and flags, then width and height
+		if at+12 > len(head) {
+			break
+		}
+		bw, bh := int(binary.BigEndian.Uint32(head[at+4:])), int(binary.BigEndian.Uint32(head[at+8:]))
+		if bw*bh > w*h {
+			w, h = bw, bh
+		}
+	}
+	if w == 0 || h == 0 {
+		return image.Config{}, fmt.Errorf("no image size in the HEIC header")
+	}
+	return image.Config{ColorModel: nil, Width: w, Height: h}, nil
+}
@@ -0,0 +1,376 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// historyFile is the undo journal. Like the audit log it is append-only:
+// each rename adds a record, and so does undoing one, so a crash or a
+// second run writing at the same time can't lose earlier entries. Each
+// record is a line of JSON, sealed and base64-encoded when state is
+// encrypted. Only purge rewrites it.
+const historyFile = "history.log"
+
+// legacyHistoryFile is the JSON array the history used to be rewritten
+// in. It is read along with the log and folded into it on the next write.
+const legacyHistoryFile = "history.json"
+
+// historyEntry is one rename or move made by a run.
+type historyEntry struct {
+	Run   string    `json:"run"`
+	Time  time.Time `json:"time"`
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+	Model string    `json:"model,omitempty"`
+	// Refs are the sources whose references were updated with the rename.
+	Refs []string `json:"refs,omitempty"`
+	// Op is "undo" for a record saying the rename with the same run, old
+	// and new names was reverted; loadHistory folds those into Undone.
+	Op     string `json:"op,omitempty"`
+	Undone bool   `json:"undone,omitempty"`
+}
+
+var (
+	// runID groups the entries made by one invocation.
+	runID = time.Now().Format("2006-01-02T15:04:05") + fmt.Sprintf("-%d", os.Getpid())
+
+	historyMu sync.Mutex
+
+	undoList bool
+	undoRun  string
+)
+
+// journal records old → new for `tell-me-more undo`, attributing it to the
+// model that named namedPath.
+func journal(old, new, namedPath string, refs []string) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	renamedPaths.Store(absPath(new), true)
+	err := appendHistory(historyEntry{
+		Run:   runID,
+		Time:  time.Now(),
+		Old:   absPath(old),
+		New:   absPath(new),
+		Model: namingTraceFor(namedPath).Model,
+		Refs:  refs,
+	})
+	if err != nil {
+		log.Printf("Error saving rename history: %v", err)
+	}
+}
+
+// undone is the record saying e was reverted.
+func undone(e historyEntry) historyEntry {
+	return historyEntry{Run: e.Run, Time: time.Now(), Old: e.Old, New: e.New, Op: "undo"}
+}
+
+// loadHistory returns the renames in the history, oldest first, with the
+// undone ones marked. The caller holds historyMu.
+func loadHistory() ([]historyEntry, error) {
+	records, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	var entries []historyEntry
+	for _, r := range records {
+		if r.Op != "undo" {
+			entries = append(entries, r)
+			continue
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := &entries[i]
+			if !e.Undone && e.Run == r.Run && e.Old == r.Old && e.New == r.New {
+				e.Undone = true
+				break
+			}
+		}
+	}
+	return entries, nil
+}
+
+// readHistory returns every record, the legacy file's first.
+func readHistory() ([]historyEntry, error) {
+	var records []historyEntry
+	if err := loadState(legacyHistoryFile, &records); err != nil {
+		return nil, err
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, historyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '{' {
+			sealed, err := base64.StdEncoding.DecodeString(string(line))
+			if err == nil {
+				line, err = openState(sealed)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: record %d: %v", historyFile, n, err)
+			}
+		}
+		var r historyEntry
+		if err := json.Unmarshal(line, &r); err != nil {
+			// An interrupted write can leave a partial last line.
+			log.Printf("Skipping unreadable record %d of %s: %v", n, historyFile, err)
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// appendHistory adds records to the end of the history, first moving any
+// legacy entries into it. The caller holds historyMu.
+func appendHistory(records ...historyEntry) error {
+	var legacy []historyEntry
+	if err := loadState(legacyHistoryFile, &legacy); err != nil {
+		return err
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	var out []byte
+	for _, r := range append(legacy, records...) {
+		line, err := historyLine(r)
+		if err != nil {
+			return err
+		}
+		out = append(out, line...)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, historyFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	// One write, so a record is never split between two runs' appends.
+	if _, err := f.Write(out); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if len(legacy) > 0 {
+		return removeState(legacyHistoryFile)
+	}
+	return nil
+}
+
+// historyLine encodes r as one line of the history.
+func historyLine(r historyEntry) ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	key, err := stateKey()
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		sealed, err := sealState(data)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(base64.StdEncoding.EncodeToString(sealed))
+	}
+	return append(data, '\n'), nil
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [renamed file...]",
+	Short: "Revert the renames of the last run, of --run, or of the given files",
+	Long: `Without arguments, undo reverts every rename made by the most recent run that
+still has any left to undo. With file arguments (their new names) only those
+renames are reverted. References rewritten along with a rename are changed back
+as well.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		historyMu.Lock()
+		defer historyMu.Unlock()
+		entries, err := loadHistory()
+		if err != nil {
+			return err
+		}
+		if undoList {
+			listHistory(entries)
+			return nil
+		}
+
+		picked := pickUndo(entries, args)
+		if len(picked) == 0 {
+			fmt.Println("Nothing to undo")
+			return nil
+		}
+		for _, i := range picked {
+			fmt.Printf("  %s -> %s\n", entries[i].New, entries[i].Old)
+		}
+		if !confirm("Do you want to undo these renames?") {
+			return nil
+		}
+		for _, i := range picked {
+			e := entries[i]
+			if err := revert(e); err != nil {
+				log.Printf("Cannot undo %s: %v", e.New, err)
+				continue
+			}
+			if err := appendHistory(undone(e)); err != nil {
+				return fmt.Errorf("recording the undo of %s: %v", e.New, err)
+			}
+			fmt.Printf("Renamed %s back to %s\n", e.New, e.Old)
+		}
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().BoolVar(&undoList, "list", false, "list the runs and renames that can be undone")
+	undoCmd.Flags().StringVar(&undoRun, "run", "", "undo this run (see --list) instead of the most recent one")
+	rootCmd.AddCommand(undoCmd)
+	purgers[historyFile] = purgeHistory
+}
+
+// pickUndo returns the indexes of the entries to revert, newest first so
+// chained renames unwind in order.
+func pickUndo(entries []historyEntry, files []string) []int {
+	var picked []int
+	if len(files) > 0 {
+		for _, f := range files {
+			path := absPath(f)
+			for i := len(entries) - 1; i >= 0; i-- {
+				if !entries[i].Undone && entries[i].New == path {
+					picked = append(picked, i)
+					break
+				}
+			}
+		}
+		return picked
+	}
+
+	run := undoRun
+	if run == "" {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if !entries[i].Undone {
+				run = entries[i].Run
+				break
+			}
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Run == run && !entries[i].Undone {
+			picked = append(picked, i)
+		}
+	}
+	return picked
+}
+
+// revert moves e.New back to e.Old, refusing to overwrite anything that
+// has taken the old name since.
+func revert(e historyEntry) error {
+	if _, err := os.Stat(e.New); err != nil {
+		return err
+	}
+	if _, err := os.Stat(e.Old); err == nil {
+		return fmt.Errorf("%s exists again", e.Old)
+	}
+	if err := renameRetrying(e.New, e.Old); err != nil {
+		return err
+	}
+	audit("undo", e.New, e.Old, "")
+	forgetOriginalName(e.Old)
+	restoreReferences(e.Refs, e.New, e.Old)
+	return nil
+}
+
+func listHistory(entries []historyEntry) {
+	run := ""
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Run != run {
+			run = e.Run
+			fmt.Printf("Run %s\n", run)
+		}
+		status := ""
+		if e.Undone {
+			status = " (undone)"
+		}
+		fmt.Printf("  %s -> %s%s\n", e.Old, e.New, status)
+	}
+}
+
+// purgeHistory forgets renames involving the target under either name,
+// along with the records of undoing them. It is the one thing that
+// rewrites the history.
+func purgeHistory(t purgeTarget) (int, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	records, err := readHistory()
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+	var kept []byte
+	n := 0
+	for _, r := range records {
+		if t.matches(r.Old) || t.matches(r.New) {
+			if r.Op != "undo" {
+				n++
+			}
+			continue
+		}
+		line, err := historyLine(r)
+		if err != nil {
+			return 0, err
+		}
+		kept = append(kept, line...)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, historyFile+".*")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tmp.Write(kept); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, historyFile)); err != nil {
+		return 0, err
+	}
+	return n, removeState(legacyHistoryFile)
+}
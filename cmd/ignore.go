@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile is read from every directory the walk enters, and from the
+// directories above the one it starts in, like .gitignore.
+const ignoreFile = ".tmmignore"
+
+// excludePatterns are --exclude, in .tmmignore syntax, relative to the
+// directory being walked.
+var excludePatterns []string
+
+func init() {
+	rootCmd.PersistentFlags().StringArrayVar(&excludePatterns, "exclude", nil, "skip files and directories matching this gitignore-style pattern, e.g. '*.bak' or 'Photos Library.photoslibrary/' (repeatable)")
+}
+
+// ignoreRule is one line of a .tmmignore or one --exclude. Paths are
+// matched relative to base, the directory the rule came from.
+type ignoreRule struct {
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// parseIgnoreRule reads a gitignore line: # comments, ! to re-include,
+// a trailing / for directories only, and a leading or inner / to anchor
+// the pattern to base instead of matching at any depth. ** matches any
+// number of directories.
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate, line = true, line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	r.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return r, true
+}
+
+func (r ignoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return matchSegments(r.segments, strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ignored reports whether rules exclude p; the last rule that matches
+// decides, so a later ! brings a path back.
+func ignored(rules []ignoreRule, p string, isDir bool) bool {
+	out := false
+	for _, r := range rules {
+		if r.matches(p, isDir) {
+			out = !r.negate
+		}
+	}
+	return out
+}
+
+// readIgnoreFile adds the rules of dir's .tmmignore, if it has one, to
+// rules without changing the slice the caller passed in.
+func readIgnoreFile(dir string, rules []ignoreRule) []ignoreRule {
+	f, err := os.Open(filepath.Join(dir, ignoreFile))
+	if err != nil {
+		return rules
+	}
+	defer f.Close()
+	rules = rules[:len(rules):len(rules)]
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreRule(dir, sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// rootIgnoreRules are the rules in force for the walk of root: the
+// .tmmignore files above it, outermost first, then --exclude. root's own
+// .tmmignore is read with the rest of the walk.
+func rootIgnoreRules(root string) []ignoreRule {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+	var parents []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		parents = append(parents, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	var rules []ignoreRule
+	for i := len(parents) - 1; i >= 0; i-- {
+		rules = readIgnoreFile(parents[i], rules)
+	}
+	// Rules from files above root are relative to where they live, so
+	// the walk compares against absolute paths.
+	for _, p := range excludePatterns {
+		if r, ok := parseIgnoreRule(abs, p); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
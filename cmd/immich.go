@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	immichURL     string
+	immichKey     string
+	immichLimit   int
+	immichNoTags  bool
+	immichRename  bool
+	immichPathMap string
+)
+
+// immichAsset is the part of an Immich asset we read.
+type immichAsset struct {
+	ID               string `json:"id"`
+	OriginalFileName string `json:"originalFileName"`
+	OriginalPath     string `json:"originalPath"`
+	ExifInfo         struct {
+		Description string `json:"description"`
+	} `json:"exifInfo"`
+}
+
+type immichTag struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+var immichCmd = &cobra.Command{
+	Use:   "immich",
+	Short: "Write descriptions and tags to photos on an Immich server",
+	Long: `Fetches the images on an Immich server that have no description yet, describes
+each one from its preview and writes the description back, along with tags.
+
+The server and API key come from --server and --api-key, or IMMICH_URL and
+IMMICH_API_KEY. Immich owns the files it stores, so --rename-originals only
+works for external libraries mounted on this machine: --path-map maps the
+library path Immich reports to the local one, e.g.
+--path-map /mnt/photos=/Volumes/photos. Rescan the library afterwards.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if immichURL == "" {
+			immichURL = os.Getenv("IMMICH_URL")
+		}
+		if immichKey == "" {
+			immichKey = os.Getenv("IMMICH_API_KEY")
+		}
+		if immichURL == "" || immichKey == "" {
+			return fmt.Errorf("need an Immich server and API key (--server/--api-key or IMMICH_URL/IMMICH_API_KEY)")
+		}
+		immichURL = strings.TrimSuffix(strings.TrimSuffix(immichURL, "/"), "/api")
+		remotePrefix, localPrefix, _ := strings.Cut(immichPathMap, "=")
+		if immichRename && remotePrefix == "" {
+			return fmt.Errorf("--rename-originals needs --path-map")
+		}
+		if err := setupOutput(); err != nil {
+			return err
+		}
+		if err := loadTemplate(); err != nil {
+			return err
+		}
+
+		done := 0
+		err := immichUndescribed(immichLimit, func(asset immichAsset) {
+			var local string
+			if immichRename && strings.HasPrefix(asset.OriginalPath, remotePrefix) {
+				local = localPrefix + strings.TrimPrefix(asset.OriginalPath, remotePrefix)
+			}
+			processImmichAsset(asset, local)
+			done++
+		})
+		fmt.Fprintf(msgOut, "%d assets without a description processed\n", done)
+		printPlan()
+		return err
+	},
+}
+
+func init() {
+	immichCmd.Flags().StringVar(&immichURL, "server", "", "Immich server URL, e.g. http://immich.local:2283")
+	immichCmd.Flags().StringVar(&immichKey, "api-key", "", "Immich API key")
+	immichCmd.Flags().IntVar(&immichLimit, "limit", 0, "process at most this many assets (0 for all)")
+	immichCmd.Flags().BoolVar(&immichNoTags, "no-tags", false, "only write descriptions, no tags")
+	immichCmd.Flags().BoolVar(&immichRename, "rename-originals", false, "also rename the original files of an external library")
+	immichCmd.Flags().StringVar(&immichPathMap, "path-map", "", "immich-path=local-path prefix mapping for --rename-originals")
+	rootCmd.AddCommand(immichCmd)
+}
+
+func processImmichAsset(asset immichAsset, local string) {
+	rec := fileRecord{Path: asset.OriginalFileName, Action: actionSkipped}
+	defer func() { emitRecord(rec) }()
+	fail := func(err error) {
+		log.Printf("Error processing %s: %v", asset.OriginalFileName, err)
+		rec.Action, rec.Error = actionError, err.Error()
+	}
+
+	data, mimeType, err := immichThumbnail(asset.ID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	description, err := describeImageBytes(data, mimeType)
+	if err != nil {
+		fail(err)
+		return
+	}
+	rec.Description = description
+	caption, tags, err := getCaptionAndTags(description)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := immichRequest(http.MethodPut, "/api/assets/"+asset.ID, map[string]string{"description": caption}, nil); err != nil {
+		fail(err)
+		return
+	}
+	fmt.Fprintf(msgOut, "%s: %s\n", asset.OriginalFileName, caption)
+	if !immichNoTags && len(tags) > 0 {
+		if err := immichTagAsset(asset.ID, tags); err != nil {
+			fail(err)
+			return
+		}
+		fmt.Fprintf(msgOut, "  tags: %s\n", strings.Join(tags, ", "))
+	}
+	if local == "" {
+		return
+	}
+
+	name, err := suggestName(local, description)
+	if err == nil {
+		name, err = finalName(local, name)
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	rec.Path, rec.Name = local, sanitizeFileName(name)
+	newPath, err := renameFile(local, name)
+	if errors.Is(err, errDryRun) {
+		rec.Action, rec.NewPath = actionPlanned, newPath
+		return
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	rec.Action, rec.NewPath = actionRenamed, newPath
+	fmt.Fprintf(msgOut, "Renamed %s to %s\n", local, newPath)
+}
+
+// immichUndescribed pages through the server's images and calls fn for
+// each one without a description, one page at a time so a library of any
+// size is never held in memory.
+func immichUndescribed(limit int, fn func(immichAsset)) error {
+	seen := 0
+	for page := 1; page > 0; {
+		var resp struct {
+			Assets struct {
+				Items    []immichAsset `json:"items"`
+				NextPage *string       `json:"nextPage"`
+			} `json:"assets"`
+		}
+		body := map[string]any{"type": "IMAGE", "withExif": true, "page": page, "size": 250}
+		if err := immichRequest(http.MethodPost, "/api/search/metadata", body, &resp); err != nil {
+			return err
+		}
+		for _, a := range resp.Assets.Items {
+			if strings.TrimSpace(a.ExifInfo.Description) == "" {
+				fn(a)
+				if seen++; limit > 0 && seen == limit {
+					return nil
+				}
+			}
+		}
+		page = 0
+		if resp.Assets.NextPage != nil {
+			fmt.Sscan(*resp.Assets.NextPage, &page)
+		}
+	}
+	return nil
+}
+
+// immichThumbnail downloads the preview-sized rendition of an asset, which
+// is plenty for describing it and much smaller than the original.
+func immichThumbnail(id string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, immichURL+"/api/assets/"+id+"/thumbnail?size=preview", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("x-api-key", immichKey)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching preview: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, resp.Header.Get("Content-Type"), err
+}
+
+// immichTagAsset creates any missing tags and attaches all of them to the
+// asset.
+func immichTagAsset(id string, tags []string) error {
+	var created []immichTag
+	if err := immichRequest(http.MethodPut, "/api/tags", map[string][]string{"tags": tags}, &created); err != nil {
+		return fmt.Errorf("creating tags: %v", err)
+	}
+	for _, tag := range created {
+		if err := immichRequest(http.MethodPut, "/api/tags/"+tag.ID+"/assets", map[string][]string{"ids": {id}}, nil); err != nil {
+			return fmt.Errorf("tagging with %s: %v", tag.Value, err)
+		}
+	}
+	return nil
+}
+
+func immichRequest(method, path string, body, out any) error {
+	return jsonRequest(method, immichURL+path, http.Header{"X-Api-Key": {immichKey}}, body, out)
+}
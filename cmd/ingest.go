@@ -0,0 +1,12 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var ingestCmd = &cobra.Command{
+	Use:   "ingest",
+	Short: "Pull files out of other sources and save them under descriptive names",
+}
+
+func init() {
+	rootCmd.AddCommand(ingestCmd)
+}
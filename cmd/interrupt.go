@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// checkpointFile holds a checkpoint for each interrupted run, so one of
+// plan doesn't take the place of one of the root command or for other
+// directories.
+const checkpointFile = "checkpoints.json"
+
+// uploadCleanupTimeout is how long an interrupt waits for uploaded files
+// to be deleted from the Gemini Files API before giving up on them.
+const uploadCleanupTimeout = 10 * time.Second
+
+var (
+	// runCtx is cancelled by the first Ctrl+C or SIGTERM, which makes the
+	// API calls in flight give up.
+	runCtx, cancelRun = context.WithCancel(context.Background())
+
+	// uploadsInFlight counts files uploaded to Gemini and not deleted yet.
+	uploadsInFlight atomic.Int32
+	// renameMu is held while a file is renamed and journaled.
+	renameMu sync.Mutex
+
+	resume     bool
+	progressMu sync.Mutex
+	progress   = checkpoint{Done: map[string]bool{}}
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&resume, "resume", false, "continue the last interrupted run, skipping the files it already dealt with")
+}
+
+// checkpoint is what an interrupted run leaves behind for --resume: the
+// command and what it was asked to do, and the files it was done with.
+type checkpoint struct {
+	Command string          `json:"command"`
+	Args    []string        `json:"args"`
+	Done    map[string]bool `json:"done"`
+}
+
+// is reports whether c is the checkpoint of command run on args.
+func (c checkpoint) is(command string, args []string) bool {
+	return c.Command == command && slices.Equal(c.Args, args)
+}
+
+// startRun sets up the checkpoint for command run on args, or with
+// --resume loads the interrupted run's and returns its arguments, and
+// starts catching interrupts. Without arguments --resume continues the
+// command's only interrupted run.
+func startRun(command string, args []string) ([]string, error) {
+	abs := make([]string, len(args))
+	for i, arg := range args {
+		abs[i] = arg
+		if !isRemoteURL(arg) {
+			abs[i] = absPath(arg)
+		}
+	}
+	if resume {
+		var saved []checkpoint
+		if err := loadState(checkpointFile, &saved); err != nil {
+			return nil, err
+		}
+		var runs []checkpoint
+		for _, c := range saved {
+			if c.Command == command && (len(args) == 0 || slices.Equal(c.Args, abs)) {
+				runs = append(runs, c)
+			}
+		}
+		switch {
+		case len(runs) == 0 && len(args) > 0:
+			return nil, fmt.Errorf("there is no interrupted %s run for %s to resume", command, strings.Join(abs, " "))
+		case len(runs) == 0:
+			return nil, fmt.Errorf("there is no interrupted %s run to resume", command)
+		case len(runs) > 1:
+			var which []string
+			for _, c := range runs {
+				which = append(which, strings.Join(c.Args, " "))
+			}
+			return nil, fmt.Errorf("there are %d interrupted %s runs; give the arguments of the one to resume: %s", len(runs), command, strings.Join(which, "; "))
+		}
+		progress = runs[0]
+		if progress.Done == nil {
+			progress.Done = map[string]bool{}
+		}
+		args, abs = progress.Args, progress.Args
+		fmt.Fprintf(msgOut, "Resuming: %d files already dealt with\n", len(progress.Done))
+	}
+	progress.Command, progress.Args = command, abs
+	catchInterrupts()
+	return args, nil
+}
+
+// finishRun drops the checkpoint of the run just completed, which an
+// earlier interrupted run of the same command on the same arguments may
+// have left.
+func finishRun() {
+	if err := updateCheckpoints(func(saved []checkpoint) []checkpoint {
+		return slices.DeleteFunc(saved, func(c checkpoint) bool { return c.is(progress.Command, progress.Args) })
+	}); err != nil {
+		log.Printf("Error removing checkpoint: %v", err)
+	}
+}
+
+// updateCheckpoints rewrites the saved checkpoints with change, removing
+// the file once none are left.
+func updateCheckpoints(change func([]checkpoint) []checkpoint) error {
+	var saved []checkpoint
+	if err := loadState(checkpointFile, &saved); err != nil {
+		return err
+	}
+	if saved = change(saved); len(saved) == 0 {
+		return removeState(checkpointFile)
+	}
+	return saveState(checkpointFile, saved)
+}
+
+// markDone records that path needs nothing more from a resumed run.
+func markDone(path string) {
+	progressMu.Lock()
+	progress.Done[absPath(path)] = true
+	progressMu.Unlock()
+}
+
+// alreadyDone reports whether the run being resumed dealt with path.
+func alreadyDone(path string) bool {
+	if !resume {
+		return false
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return progress.Done[absPath(path)]
+}
+
+// catchInterrupts turns the first Ctrl+C or SIGTERM into a clean stop.
+// A second one quits at once.
+func catchInterrupts() {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		fmt.Fprintln(os.Stderr, "\nStopping; press Ctrl+C again to quit at once")
+		go func() {
+			<-sigs
+			os.Exit(130)
+		}()
+		stopRun(130)
+	}()
+}
+
+var stopOnce sync.Once
+
+// stopRun ends the run early with code: calls in flight are cancelled,
+// uploads deleted, a rename under way is finished and the checkpoint
+// saved. It never returns, so it must not be called from anything an
+// upload or rename in flight is waiting on.
+func stopRun(code int) {
+	stopOnce.Do(func() {
+		cancelRun()
+		deadline := time.Now().Add(uploadCleanupTimeout)
+		for uploadsInFlight.Load() > 0 && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if n := uploadsInFlight.Load(); n > 0 {
+			log.Printf("Gave up deleting %d uploaded files; Gemini removes them after 48 hours", n)
+		}
+		renameMu.Lock()
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		if len(progress.Args) > 0 {
+			err := updateCheckpoints(func(saved []checkpoint) []checkpoint {
+				saved = slices.DeleteFunc(saved, func(c checkpoint) bool { return c.is(progress.Command, progress.Args) })
+				return append(saved, progress)
+			})
+			if err != nil {
+				log.Printf("Error saving checkpoint: %v", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Run `%s --resume` to pick up where this left off\n", progress.Command)
+			}
+		}
+		os.Exit(code)
+	})
+	select {} // another caller is already exiting
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheckpoints(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("TELL_ME_MORE_STATE_KEY", strings.Repeat("ab", 32))
+	defer func(r bool, p checkpoint) { resume, progress = r, p }(resume, progress)
+
+	photos, screenshots := absPath("photos"), absPath("screenshots")
+	err := updateCheckpoints(func([]checkpoint) []checkpoint {
+		return []checkpoint{
+			{Command: "tell-me-more", Args: []string{photos}, Done: map[string]bool{"a": true}},
+			{Command: "tell-me-more plan", Args: []string{photos}},
+			{Command: "tell-me-more", Args: []string{screenshots}},
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resume = true
+	if _, err := startRun("tell-me-more", nil); err == nil || !strings.Contains(err.Error(), "2 interrupted") {
+		t.Errorf("resuming one of two runs without arguments: error = %v, want it to name both", err)
+	}
+	args, err := startRun("tell-me-more plan", nil)
+	if err != nil || !slices.Equal(args, []string{photos}) {
+		t.Errorf("resuming plan = %v, %v, want %v", args, err, []string{photos})
+	}
+	args, err = startRun("tell-me-more", []string{"photos"})
+	if err != nil || !slices.Equal(args, []string{photos}) || !progress.Done["a"] {
+		t.Errorf("resuming photos = %v, %v with %v done, want %v with a done", args, err, progress.Done, []string{photos})
+	}
+
+	// A run that finishes clears its own checkpoint, resumed or not.
+	resume, progress = false, checkpoint{Done: map[string]bool{}}
+	if _, err := startRun("tell-me-more", []string{"screenshots"}); err != nil {
+		t.Fatal(err)
+	}
+	finishRun()
+	var saved []checkpoint
+	if err := loadState(checkpointFile, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || slices.ContainsFunc(saved, func(c checkpoint) bool { return c.is("tell-me-more", []string{screenshots}) }) {
+		t.Errorf("after finishing the screenshots run the checkpoints are %+v, want the other two", saved)
+	}
+	resume = true
+	if _, err := startRun("tell-me-more", []string{"screenshots"}); err == nil {
+		t.Error("resuming a run that finished: no error")
+	}
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// junkPolicy is --junk: what happens to captures that look accidental,
+// which are found locally before anything is sent to a model. It is off
+// unless asked for: a blank document or a dark slide is a real capture as
+// often as not.
+var junkPolicy = junkFlag("off")
+
+func init() {
+	rootCmd.PersistentFlags().Var(&junkPolicy, "junk", "what to do with blank, black, tiny or double-pressed captures, found before any API call: ask, skip, delete, or off to name them like any other file")
+}
+
+type junkFlag string
+
+func (f *junkFlag) Set(s string) error {
+	switch s {
+	case "ask", "skip", "delete", "off":
+		*f = junkFlag(s)
+		return nil
+	}
+	return fmt.Errorf("unknown junk policy %q (want ask, skip, delete or off)", s)
+}
+
+func (f *junkFlag) String() string { return string(*f) }
+
+func (f *junkFlag) Type() string { return "policy" }
+
+const (
+	// junkMinSide is the smallest width or height a capture meant to be
+	// taken has; anything narrower is a click that dragged a little.
+	junkMinSide = 16
+	// junkTolerance is how far a pixel's channels may be from the image's
+	// average colour and still count as the same colour, out of 255.
+	junkTolerance = 16
+	// junkSamples is about how many pixels are looked at on each side.
+	junkSamples = 256
+)
+
+// junkReason says why the image at path looks like a capture nobody meant
+// to take, or "" when it doesn't.
+func junkReason(path string) string {
+	if junkPolicy == "off" || !isImageFile(path) {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return ""
+	}
+	if cfg.Width < junkMinSide || cfg.Height < junkMinSide {
+		return fmt.Sprintf("only %dx%d pixels", cfg.Width, cfg.Height)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return ""
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return ""
+	}
+	if reason := uniformImage(img); reason != "" {
+		return reason
+	}
+	return doublePress(path, cfg)
+}
+
+// uniformImage returns "all black", "all white" or "a single colour" when
+// nearly every pixel of img is the same colour. Up to one in a thousand may
+// differ, for a cursor or a stray pixel.
+func uniformImage(img image.Image) string {
+	b := img.Bounds()
+	stepX, stepY := max(1, b.Dx()/junkSamples), max(1, b.Dy()/junkSamples)
+	type rgb struct{ r, g, bl int }
+	var samples []rgb
+	var sum rgb
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			c := rgb{int(r >> 8), int(g >> 8), int(bl >> 8)}
+			samples = append(samples, c)
+			sum.r, sum.g, sum.bl = sum.r+c.r, sum.g+c.g, sum.bl+c.bl
+		}
+	}
+	n := len(samples)
+	mean := rgb{sum.r / n, sum.g / n, sum.bl / n}
+	off := 0
+	for _, c := range samples {
+		if abs(c.r-mean.r) > junkTolerance || abs(c.g-mean.g) > junkTolerance || abs(c.bl-mean.bl) > junkTolerance {
+			off++
+		}
+	}
+	if off*1000 > n {
+		return ""
+	}
+	switch luma := (299*mean.r + 587*mean.g + 114*mean.bl) / 1000; {
+	case luma < 16:
+		return "all black"
+	case luma > 239:
+		return "all white"
+	}
+	return "a single colour"
+}
+
+// junkDirs caches the modification times of the images in each folder
+// doublePress has looked at.
+var (
+	junkDirsMu sync.Mutex
+	junkDirs   = map[string]map[string]time.Time{}
+)
+
+// doublePress returns why path looks like a second press of the capture
+// keys: taken less than a second after another image in its folder of the
+// same size that shows the same thing.
+func doublePress(path string, cfg image.Config) string {
+	dir, name := filepath.Split(path)
+	junkDirsMu.Lock()
+	times, ok := junkDirs[dir]
+	if !ok {
+		times = map[string]time.Time{}
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && info.Mode().IsRegular() && isImageFile(e.Name()) {
+				times[e.Name()] = info.ModTime()
+			}
+		}
+		junkDirs[dir] = times
+	}
+	junkDirsMu.Unlock()
+
+	t, ok := times[name]
+	if !ok {
+		return ""
+	}
+	var self *dupImage
+	for other, ot := range times {
+		gap := t.Sub(ot)
+		if other == name || gap < 0 || gap >= time.Second || (gap == 0 && other > name) {
+			continue
+		}
+		otherPath := filepath.Join(dir, other)
+		if c, err := imageConfig(otherPath); err != nil || c.Width != cfg.Width || c.Height != cfg.Height {
+			continue
+		}
+		if self == nil {
+			img, err := differenceHash(path)
+			if err != nil {
+				return ""
+			}
+			self = &img
+		}
+		if img, err := differenceHash(otherPath); err == nil && bits.OnesCount64(img.hash^self.hash) <= dupDistance {
+			return fmt.Sprintf("taken %v after %s", gap.Round(time.Millisecond), other)
+		}
+	}
+	return ""
+}
+
+func imageConfig(path string) (image.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err
+}
+
+// handleJunk decides about s, a capture that looks accidental, by
+// --junk. It reports whether s was dealt with; if not, s is described and
+// named like any other file.
+func handleJunk(s *suggestion, rec *fileRecord) bool {
+	rec.Junk = s.junk
+	fmt.Fprintf(msgOut, "Looks like an accidental capture: %s\n", s.junk)
+	if dryRun {
+		return true
+	}
+	decision := string(junkPolicy)
+	if decision == "ask" {
+		decision = askJunk()
+	}
+	switch decision {
+	case "delete":
+		deleteJunk(s.path, rec)
+	case "name":
+		*s = describeAndSuggest(s.path)
+		rec.Description = s.description
+		return false
+	default:
+		fmt.Fprintf(msgOut, "Skipped %s\n", s.path)
+	}
+	return true
+}
+
+// deleteJunk deletes an accidental capture, recording it in rec and the
+// audit log.
+func deleteJunk(path string, rec *fileRecord) {
+	if err := os.Remove(path); err != nil {
+		log.Printf("Error deleting %s: %v", path, err)
+		rec.Action, rec.Error = actionError, err.Error()
+		return
+	}
+	audit("delete", absPath(path), "", "")
+	rec.Action = actionDeleted
+	fmt.Fprintf(msgOut, "Deleted %s\n", path)
+}
+
+// askJunk asks whether to skip an accidental capture, delete it or name it
+// anyway. --yes and --no-input skip it: neither says to spend a request on
+// it.
+func askJunk() string {
+	fmt.Fprint(msgOut, "Skip it, delete it, or name it anyway? (s/x/n): ")
+	if assumeYes || noInput {
+		fmt.Fprintln(msgOut, "s")
+		return "skip"
+	}
+	var input string
+	fmt.Scanln(&input)
+	switch strings.ToLower(input) {
+	case "x":
+		return "delete"
+	case "n":
+		return "name"
+	}
+	return "skip"
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import "testing"
+
+func TestJunkFlagSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"ask", false},
+		{"skip", false},
+		{"delete", false},
+		{"off", false},
+		{"", true},
+		{"name", true},
+		{"OFF", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			f := junkFlag("off")
+			err := f.Set(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			want := tt.in
+			if tt.wantErr {
+				want = "off" // left as it was
+			}
+			if f.String() != want {
+				t.Errorf("after Set(%q) the policy is %q, want %q", tt.in, f, want)
+			}
+		})
+	}
+}
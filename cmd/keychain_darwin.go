@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainKey reads the state key from the login keychain, creating it on
+// first use.
+func keychainKey() ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w").Output()
+	if err == nil {
+		return hex.DecodeString(string(bytes.TrimSpace(out)))
+	}
+	key, err := newStateKey()
+	if err != nil {
+		return nil, err
+	}
+	// The key goes in on stdin, through security's interactive mode, so it
+	// never shows up in the process list the way an argument would. That
+	// mode doesn't fail when a command does, so the key is read back.
+	store := exec.Command("security", "-i")
+	store.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -s %s -a %s -w %s\n", keychainService, keychainAccount, hex.EncodeToString(key)))
+	out, err = store.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	if err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount).Run(); err != nil {
+		return nil, fmt.Errorf("storing the state key in the keychain: %s", bytes.TrimSpace(out))
+	}
+	return key, nil
+}
+
+func deleteKeychainKey() error {
+	return exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", keychainAccount).Run()
+}
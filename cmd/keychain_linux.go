@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os/exec"
+	"strings"
+)
+
+// keychainKey reads the state key from the Secret Service (GNOME Keyring,
+// KWallet) through secret-tool, creating it on first use.
+func keychainKey() ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount).Output()
+	if err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return hex.DecodeString(string(bytes.TrimSpace(out)))
+	}
+	if _, lookErr := exec.LookPath("secret-tool"); lookErr != nil {
+		return nil, lookErr
+	}
+	key, err := newStateKey()
+	if err != nil {
+		return nil, err
+	}
+	store := exec.Command("secret-tool", "store", "--label=tell-me-more state key", "service", keychainService, "account", keychainAccount)
+	store.Stdin = strings.NewReader(hex.EncodeToString(key))
+	if err := store.Run(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func deleteKeychainKey() error {
+	return exec.Command("secret-tool", "clear", "service", keychainService, "account", keychainAccount).Run()
+}
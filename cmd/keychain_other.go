@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package cmd
+
+import "errors"
+
+func keychainKey() ([]byte, error) {
+	return nil, errors.New("no keychain support on this platform")
+}
+
+func deleteKeychainKey() error {
+	return nil
+}
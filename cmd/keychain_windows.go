@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// keychainKey keeps the state key in a file protected with DPAPI, so only
+// the current Windows user can unwrap it.
+func keychainKey() ([]byte, error) {
+	path, err := protectedKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := os.ReadFile(path)
+	if err == nil {
+		return dpapi(blob, false)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key, err := newStateKey()
+	if err != nil {
+		return nil, err
+	}
+	if blob, err = dpapi(key, true); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, blob, 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func deleteKeychainKey() error {
+	path, err := protectedKeyPath()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func protectedKeyPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.key"), nil
+}
+
+func dpapi(data []byte, protect bool) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("the protected state key is empty")
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	var err error
+	if protect {
+		err = windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/googleapi"
+)
+
+const keyUsageFile = "keyusage.json"
+
+// keyCooldown is how long a key that hit its quota is left alone before
+// it is tried again.
+const keyCooldown = time.Minute
+
+var keyRotation = rotationPolicy("on-429")
+
+// keyPool is the set of API keys configured for one provider: a
+// comma-separated list in its environment variable.
+type keyPool struct {
+	provider string
+	env      string
+
+	// configured are the keys from the config file, which take the place
+	// of the environment variable.
+	configured []string
+
+	once    sync.Once
+	mu      sync.Mutex
+	keys    []string
+	next    int
+	resting map[string]time.Time
+}
+
+var (
+	geminiKeys = &keyPool{provider: "gemini", env: "GEMINI_API_KEY"}
+	openaiKeys = &keyPool{provider: "openai", env: "OPENAI_API_KEY"}
+
+	keyUsageMu sync.Mutex
+)
+
+// keyUsage is the running tally for one key, stored under its provider and
+// last four characters so the key itself never lands on disk.
+type keyUsage struct {
+	Requests    int       `json:"requests"`
+	RateLimited int       `json:"rate_limited"`
+	Errors      int       `json:"errors"`
+	LastUsed    time.Time `json:"last_used"`
+}
+
+func init() {
+	rootCmd.PersistentFlags().Var(&keyRotation, "key-rotation", "with several comma-separated keys per provider: round-robin or on-429 (move on when a key hits its quota)")
+	rootCmd.AddCommand(usageCmd)
+}
+
+type rotationPolicy string
+
+func (r *rotationPolicy) Set(s string) error {
+	switch s {
+	case "round-robin", "on-429":
+		*r = rotationPolicy(s)
+		return nil
+	}
+	return fmt.Errorf("unknown rotation %q (want round-robin or on-429)", s)
+}
+
+func (r *rotationPolicy) String() string { return string(*r) }
+
+func (r *rotationPolicy) Type() string { return "policy" }
+
+func (p *keyPool) load() {
+	p.once.Do(func() {
+		keys := p.configured
+		if len(keys) == 0 {
+			keys = strings.Split(os.Getenv(p.env), ",")
+		}
+		for _, k := range keys {
+			if k = strings.TrimSpace(k); k != "" {
+				p.keys = append(p.keys, k)
+			}
+		}
+		p.resting = map[string]time.Time{}
+	})
+}
+
+// pick returns the key to use next, skipping keys that are resting after a
+// 429, and false once all of them are.
+func (p *keyPool) pick() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for range p.keys {
+		i := p.next % len(p.keys)
+		if keyRotation == "round-robin" {
+			p.next++
+		}
+		key := p.keys[i]
+		if time.Now().After(p.resting[key]) {
+			return key, true
+		}
+		if keyRotation != "round-robin" {
+			p.next++
+		}
+	}
+	return "", false
+}
+
+func (p *keyPool) rest(key string) {
+	p.mu.Lock()
+	p.resting[key] = time.Now().Add(keyCooldown)
+	p.mu.Unlock()
+}
+
+// withKey runs fn with one of the provider's keys, moving on to the next
+// key whenever one is rate limited and retrying other transient errors
+// with the same key. Without any keys fn gets "", so it reports the
+// missing key the way it always has.
+func withKey[T any](p *keyPool, fn func(key string) (T, error)) (T, error) {
+	p.load()
+	if len(p.keys) == 0 {
+		return retrying(p.provider, false, func() (T, error) { return fn("") })
+	}
+	var last error
+	for {
+		key, ok := p.pick()
+		if !ok {
+			var zero T
+			if last == nil {
+				last = fmt.Errorf("every %s key is rate limited", p.provider)
+			}
+			return zero, last
+		}
+		result, err := retrying(p.provider, len(p.keys) > 1, func() (T, error) {
+			result, err := fn(key)
+			recordKeyUsage(p.provider, key, err, isRateLimited(err))
+			return result, err
+		})
+		limited := isRateLimited(err)
+		if !limited {
+			return result, err
+		}
+		if len(p.keys) > 1 {
+			log.Printf("%s key %s is rate limited, trying the next one", p.provider, keyLabel(key))
+		}
+		p.rest(key)
+		last = err
+	}
+}
+
+func isRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+	var oerr *openai.APIError
+	if errors.As(err, &oerr) && oerr.HTTPStatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusTooManyRequests {
+		return true
+	}
+	// Most callers wrap with %v, so fall back to the message.
+	return rateLimitPattern.MatchString(err.Error())
+}
+
+var rateLimitPattern = regexp.MustCompile(`Error 429\b|status code: 429\b|429 Too Many Requests|RESOURCE_EXHAUSTED`)
+
+func keyLabel(key string) string {
+	if len(key) <= 4 {
+		return "…"
+	}
+	return "…" + key[len(key)-4:]
+}
+
+func recordKeyUsage(provider, key string, err error, limited bool) {
+	keyUsageMu.Lock()
+	defer keyUsageMu.Unlock()
+	usage := map[string]*keyUsage{}
+	if err := loadState(keyUsageFile, &usage); err != nil {
+		log.Printf("Error reading key usage: %v", err)
+		return
+	}
+	id := provider + " " + keyLabel(key)
+	u := usage[id]
+	if u == nil {
+		u = &keyUsage{}
+		usage[id] = u
+	}
+	u.Requests++
+	u.LastUsed = time.Now()
+	switch {
+	case limited:
+		u.RateLimited++
+	case err != nil:
+		u.Errors++
+	}
+	if err := saveState(keyUsageFile, usage); err != nil {
+		log.Printf("Error saving key usage: %v", err)
+	}
+}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show how much each API key has been used",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		usage := map[string]*keyUsage{}
+		if err := loadState(keyUsageFile, &usage); err != nil {
+			return err
+		}
+		if len(usage) == 0 {
+			fmt.Println("No API calls recorded yet")
+			return nil
+		}
+		ids := make([]string, 0, len(usage))
+		for id := range usage {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		fmt.Printf("%-14s %9s %13s %7s  %s\n", "KEY", "REQUESTS", "RATE LIMITED", "ERRORS", "LAST USED")
+		for _, id := range ids {
+			u := usage[id]
+			fmt.Printf("%-14s %9d %13d %7d  %s\n", id, u.Requests, u.RateLimited, u.Errors, u.LastUsed.Format("2006-01-02 15:04"))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textLangs holds the --lang values. Both ISO 639-1 codes ("de") and the
+// three-letter codes Tesseract language packs use ("deu") are accepted.
+var textLangs []string
+
+func init() {
+	rootCmd.PersistentFlags().StringSliceVar(&textLangs, "lang", nil, "languages of the text in the images, e.g. de,ja or deu,jpn")
+}
+
+var langNames = map[string]string{
+	"ar": "Arabic", "ara": "Arabic",
+	"zh": "Chinese", "chi_sim": "Simplified Chinese", "chi_tra": "Traditional Chinese",
+	"cs": "Czech", "ces": "Czech",
+	"da": "Danish", "dan": "Danish",
+	"nl": "Dutch", "nld": "Dutch",
+	"en": "English", "eng": "English",
+	"fi": "Finnish", "fin": "Finnish",
+	"fr": "French", "fra": "French",
+	"de": "German", "deu": "German",
+	"el": "Greek", "ell": "Greek",
+	"he": "Hebrew", "heb": "Hebrew",
+	"hi": "Hindi", "hin": "Hindi",
+	"it": "Italian", "ita": "Italian",
+	"ja": "Japanese", "jpn": "Japanese",
+	"ko": "Korean", "kor": "Korean",
+	"no": "Norwegian", "nor": "Norwegian",
+	"pl": "Polish", "pol": "Polish",
+	"pt": "Portuguese", "por": "Portuguese",
+	"ru": "Russian", "rus": "Russian",
+	"es": "Spanish", "spa": "Spanish",
+	"sv": "Swedish", "swe": "Swedish",
+	"th": "Thai", "tha": "Thai",
+	"tr": "Turkish", "tur": "Turkish",
+	"uk": "Ukrainian", "ukr": "Ukrainian",
+	"vi": "Vietnamese", "vie": "Vietnamese",
+}
+
+// tesseractCodes are the Tesseract language packs for the --lang codes
+// given in ISO 639-1.
+var tesseractCodes = map[string]string{
+	"ar": "ara", "zh": "chi_sim", "cs": "ces", "da": "dan", "nl": "nld",
+	"en": "eng", "fi": "fin", "fr": "fra", "de": "deu", "el": "ell",
+	"he": "heb", "hi": "hin", "it": "ita", "ja": "jpn", "ko": "kor",
+	"no": "nor", "pl": "pol", "pt": "por", "ru": "rus", "es": "spa",
+	"sv": "swe", "th": "tha", "tr": "tur", "uk": "ukr", "vi": "vie",
+}
+
+// tesseractLangs is --lang as Tesseract's -l takes it, such as deu+jpn, or
+// English when --lang is not set.
+func tesseractLangs() string {
+	if len(textLangs) == 0 {
+		return "eng"
+	}
+	codes := make([]string, len(textLangs))
+	for i, code := range textLangs {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if t, ok := tesseractCodes[code]; ok {
+			code = t
+		}
+		codes[i] = code
+	}
+	return strings.Join(codes, "+")
+}
+
+// langName turns a --lang code into something a model understands; codes
+// we don't know are passed through as given.
+func langName(code string) string {
+	if name, ok := langNames[strings.ToLower(strings.TrimSpace(code))]; ok {
+		return name
+	}
+	return strings.TrimSpace(code)
+}
+
+// langPrompt is appended to the describe prompt when --lang is set.
+func langPrompt() string {
+	if len(textLangs) == 0 {
+		return ""
+	}
+	names := make([]string, len(textLangs))
+	for i, code := range textLangs {
+		names[i] = langName(code)
+	}
+	return fmt.Sprintf(`
+
+The text in this image is likely in %s. Transcribe the important visible text (window titles, headings, buttons) exactly as written in its original script, and give an English translation of each next to it.`, strings.Join(names, " or "))
+}
+
+// langNameHint asks the naming model for a name from the English
+// translation rather than a transliteration or the original text, whose
+// script sanitizeFileName strips.
+func langNameHint() string {
+	if len(textLangs) == 0 {
+		return ""
+	}
+	return `
+
+Some text in the description is not in English. Base the name on the English translation of that text, not a transliteration.`
+}
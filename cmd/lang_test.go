@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLangPrompt(t *testing.T) {
+	defer func(langs []string) { textLangs = langs }(textLangs)
+	tests := []struct {
+		langs []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"de"}, "likely in German."},
+		{[]string{"deu", "JA"}, "likely in German or Japanese."},
+		{[]string{" pt "}, "likely in Portuguese."},
+		{[]string{"tlh"}, "likely in tlh."},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.langs, ","), func(t *testing.T) {
+			textLangs = tt.langs
+			got := langPrompt()
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("langPrompt() = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLangNameHint(t *testing.T) {
+	defer func(langs []string) { textLangs = langs }(textLangs)
+	textLangs = nil
+	if got := langNameHint(); got != "" {
+		t.Errorf("langNameHint() without --lang = %q, want none", got)
+	}
+	textLangs = []string{"ja"}
+	if got := langNameHint(); !strings.Contains(got, "English translation") {
+		t.Errorf("langNameHint() with --lang = %q, want it to ask for the English translation", got)
+	}
+}
+
+func TestTesseractLangs(t *testing.T) {
+	defer func(langs []string) { textLangs = langs }(textLangs)
+	tests := []struct {
+		langs []string
+		want  string
+	}{
+		{nil, "eng"},
+		{[]string{"de"}, "deu"},
+		{[]string{"de", "ja"}, "deu+jpn"},
+		{[]string{"deu", " JPN "}, "deu+jpn"},
+		{[]string{"zh"}, "chi_sim"},
+		{[]string{"chi_tra", "en"}, "chi_tra+eng"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.langs, ","), func(t *testing.T) {
+			textLangs = tt.langs
+			if got := tesseractLangs(); got != tt.want {
+				t.Errorf("tesseractLangs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
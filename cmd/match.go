@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// matchPatterns replace the built-in screenshot, recording and other
+	// name patterns when given.
+	matchPatterns matchList
+
+	// extFilter limits whatever matches to these extensions, lowercased
+	// and without the dot.
+	extFilter []string
+
+	// allImages takes every image, whatever its name.
+	allImages bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().Var(&matchPatterns, "match", "handle files whose name matches this glob, or regular expression after re:, instead of the built-in patterns (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&extFilter, "ext", nil, "only handle files with these extensions, e.g. png,jpg")
+	rootCmd.PersistentFlags().BoolVar(&allImages, "all", false, "handle every image (png, jpg, jpeg, webp, heic, gif), whatever its name")
+	rootCmd.MarkFlagsMutuallyExclusive("all", "match")
+}
+
+// namePattern is one --match: a case-insensitive glob or regex.
+type namePattern struct {
+	text string
+	re   *regexp.Regexp
+}
+
+func (p namePattern) matches(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	ok, _ := filepath.Match(strings.ToLower(p.text), strings.ToLower(name))
+	return ok
+}
+
+func parseNamePattern(s string) (namePattern, error) {
+	if expr, ok := strings.CutPrefix(s, "re:"); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return namePattern{}, fmt.Errorf("invalid --match regex %q: %v", expr, err)
+		}
+		return namePattern{text: s, re: re}, nil
+	}
+	if _, err := filepath.Match(s, ""); err != nil {
+		return namePattern{}, fmt.Errorf("invalid --match glob %q: %v", s, err)
+	}
+	return namePattern{text: s}, nil
+}
+
+// matchList is repeatable without splitting on commas, which regexes use.
+// It is a pflag.SliceValue so a list in the config file arrives whole.
+type matchList []namePattern
+
+func (l *matchList) Set(s string) error {
+	p, err := parseNamePattern(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, p)
+	return nil
+}
+
+func (l *matchList) String() string {
+	return "[" + strings.Join(l.GetSlice(), ",") + "]"
+}
+
+func (l *matchList) Type() string { return "pattern" }
+
+func (l *matchList) Append(s string) error { return l.Set(s) }
+
+func (l *matchList) Replace(items []string) error {
+	var patterns matchList
+	for _, s := range items {
+		if err := patterns.Set(s); err != nil {
+			return err
+		}
+	}
+	*l = patterns
+	return nil
+}
+
+func (l *matchList) GetSlice() []string {
+	s := make([]string, len(*l))
+	for i, p := range *l {
+		s[i] = p.text
+	}
+	return s
+}
+
+// matchesUserPatterns reports whether --match, --all and --ext let
+// filename through, and whether they decided it rather than the built-in
+// patterns.
+func matchesUserPatterns(filename string) (ok, decided bool) {
+	if len(extFilter) > 0 && !hasExt(filename, extFilter) {
+		return false, true
+	}
+	if allImages {
+		// Dot files such as macOS's ._ resource forks are not images.
+		return isImageFile(filename) && !strings.HasPrefix(filepath.Base(filename), "."), true
+	}
+	if len(matchPatterns) == 0 {
+		return true, false
+	}
+	for _, p := range matchPatterns {
+		if p.matches(filename) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func hasExt(filename string, exts []string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	for _, e := range exts {
+		if strings.TrimPrefix(strings.ToLower(e), ".") == ext {
+			return true
+		}
+	}
+	return false
+}
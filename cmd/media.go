@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var imageExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".webp": true,
+	".heic": true,
+	".heif": true,
+	".gif":  true,
+}
+
+func isImageFile(path string) bool {
+	return imageExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// meaninglessNamePattern matches names apps, browsers and OSes hand out,
+// like "Archive.zip", "download (3).zip", "Presentation1.pptx" or a bare
+// hash.
+var meaninglessNamePattern = regexp.MustCompile(`^(archive|download|downloads|files|untitled|new|export|attachments?|compressed|document|doc|presentation|book|workbook|spreadsheet|sheet|draft)?[ _-]*(\(\d+\)|\d+)?$|^[0-9a-f-]{8,}$`)
+
+func isMeaninglessName(name string) bool {
+	stem := strings.ToLower(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)))
+	stem = strings.TrimPrefix(stem, "copy of ")
+	return meaninglessNamePattern.MatchString(strings.TrimSpace(stem))
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// metadataMode is --write-metadata: where the description and tags of a
+// renamed image are written, if anywhere.
+var metadataMode = metadataFlag("")
+
+func init() {
+	rootCmd.PersistentFlags().Var(&metadataMode, "write-metadata", "after renaming an image, write its description and tags into it with exiftool (embed) or into an XMP file next to it (sidecar)")
+}
+
+type metadataFlag string
+
+func (m *metadataFlag) Set(s string) error {
+	switch s {
+	case "", "embed", "sidecar":
+		*m = metadataFlag(s)
+		return nil
+	}
+	return fmt.Errorf("unknown metadata mode %q (want embed or sidecar)", s)
+}
+
+func (m *metadataFlag) String() string { return string(*m) }
+
+func (m *metadataFlag) Type() string { return "mode" }
+
+// validateMetadata checks up front that exiftool is there for embedding,
+// rather than after the first rename.
+func validateMetadata() error {
+	if metadataMode != "embed" {
+		return nil
+	}
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		return fmt.Errorf("--write-metadata embed needs exiftool (https://exiftool.org) on the PATH")
+	}
+	return nil
+}
+
+// writeMetadata records s's description and tags for the image now at
+// path, as --write-metadata says.
+func writeMetadata(path string, s suggestion) error {
+	if metadataMode == "" || !isImageFile(path) || (s.description == "" && len(s.tags) == 0) {
+		return nil
+	}
+	if metadataMode == "sidecar" {
+		return writeXMPSidecar(path, s.description, s.tags)
+	}
+	return embedMetadata(path, s.description, s.tags)
+}
+
+// embedMetadata has exiftool write the description to XMP dc:description,
+// IPTC Caption-Abstract and EXIF ImageDescription, and the tags to XMP
+// dc:subject and IPTC Keywords. Formats without IPTC or EXIF, such as
+// PNG, just get the XMP. Tags already there are not added twice.
+func embedMetadata(path, description string, tags []string) error {
+	args := []string{"-overwrite_original", "-preserve", "-m", "-charset", "iptc=UTF8", "-IPTC:CodedCharacterSet=UTF8"}
+	if description != "" {
+		args = append(args, "-XMP-dc:Description="+description, "-IPTC:Caption-Abstract="+description, "-EXIF:ImageDescription="+description)
+	}
+	for _, tag := range tags {
+		args = append(args, "-XMP-dc:Subject-="+tag, "-XMP-dc:Subject+="+tag, "-IPTC:Keywords-="+tag, "-IPTC:Keywords+="+tag)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("exiftool", append(args, "--", path)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("exiftool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// writeXMPSidecar writes the description and tags to an XMP file named
+// after the image, the way Lightroom and most photo managers look for
+// one. An existing sidecar may hold someone's edits, so it is left alone.
+func writeXMPSidecar(path, description string, tags []string) error {
+	sidecar := strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp"
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n")
+	if description != "" {
+		b.WriteString("   <dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">" + xmlText(description) + "</rdf:li></rdf:Alt></dc:description>\n")
+	}
+	if len(tags) > 0 {
+		b.WriteString("   <dc:subject><rdf:Bag>")
+		for _, tag := range tags {
+			b.WriteString("<rdf:li>" + xmlText(tag) + "</rdf:li>")
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>\n")
+
+	f, err := os.OpenFile(sidecar, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		fmt.Fprintf(msgOut, "Not writing %s: it already exists\n", sidecar)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
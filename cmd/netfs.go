@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+var (
+	networkFSMode = networkFSFlag("auto")
+
+	// copyRenames is set for network shares, where rename semantics are
+	// unreliable enough that copy, verify and delete is the safer move.
+	copyRenames bool
+)
+
+func init() {
+	rootCmd.Flags().Var(&networkFSMode, "network-fs", "treat the directory as a network share: auto, on or off")
+}
+
+type networkFSFlag string
+
+func (f *networkFSFlag) Set(s string) error {
+	switch s {
+	case "auto", "on", "off":
+		*f = networkFSFlag(s)
+		return nil
+	}
+	return fmt.Errorf("unknown --network-fs value %q (want auto, on or off)", s)
+}
+
+func (f *networkFSFlag) String() string { return string(*f) }
+
+func (f *networkFSFlag) Type() string { return "mode" }
+
+// tuneForFilesystem lowers the prefetch depth and switches to copy-based
+// renames when dir lives on an SMB/NFS/WebDAV mount. It returns the depth
+// to use.
+func tuneForFilesystem(dir string) int {
+	var network bool
+	switch networkFSMode {
+	case "on":
+		network = true
+	case "auto":
+		if fstype, ok := networkFS(dir); ok {
+			log.Printf("%s is on a %s network mount, reading fewer files ahead and renaming by copy", dir, fstype)
+			network = true
+		}
+	}
+	copyRenames = network
+	if network {
+		return 0
+	}
+	return prefetchDepth
+}
+
+// copyRename moves oldPath to newPath by copying, checking the copy's
+// SHA-256 against the original and only then removing the original.
+func copyRename(oldPath, newPath string) error {
+	src, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(newPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	srcHash := sha256.New()
+	_, err = io.Copy(dst, io.TeeReader(src, srcHash))
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = verifyCopy(newPath, srcHash.Sum(nil))
+	}
+	if err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	os.Chtimes(newPath, info.ModTime(), info.ModTime())
+
+	src.Close()
+	return os.Remove(oldPath)
+}
+
+func verifyCopy(path string, want []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if string(h.Sum(nil)) != string(want) {
+		return fmt.Errorf("copy of %s does not match the original", path)
+	}
+	return nil
+}
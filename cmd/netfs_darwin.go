@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+var networkFSTypes = map[string]string{
+	"smbfs":  "SMB",
+	"nfs":    "NFS",
+	"webdav": "WebDAV",
+	"afpfs":  "AFP",
+}
+
+func networkFS(path string) (string, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return "", false
+	}
+	fstype := string(bytes.TrimRight(st.Fstypename[:], "\x00"))
+	name, ok := networkFSTypes[fstype]
+	return name, ok
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import "syscall"
+
+// Filesystem magic numbers from statfs(2).
+var networkFSMagic = map[int64]string{
+	0x6969:     "NFS",
+	0x517b:     "SMB",
+	0xff534d42: "CIFS",
+	0xfe534d42: "SMB2",
+	0x73757245: "Coda/davfs",
+	0x5346414f: "AFS",
+	0x01021997: "9P",
+	0x00c36400: "Ceph",
+}
+
+func networkFS(path string) (string, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "", false
+	}
+	// Type is an int32 on 32-bit arches, where CIFS's magic would come out
+	// negative.
+	name, ok := networkFSMagic[int64(uint32(st.Type))]
+	return name, ok
+}
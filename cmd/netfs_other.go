@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !windows
+
+package cmd
+
+func networkFS(path string) (string, bool) {
+	return "", false
+}
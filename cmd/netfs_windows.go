@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+func networkFS(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	volume := filepath.VolumeName(abs)
+	if strings.HasPrefix(volume, `\\`) {
+		return "SMB", true
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return "", false
+	}
+	if windows.GetDriveType(root) == windows.DRIVE_REMOTE {
+		return "mapped network drive", true
+	}
+	return "", false
+}
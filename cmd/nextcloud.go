@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ncURL       string
+	ncUser      string
+	ncPassword  string
+	ncNoTags    bool
+	ncNoComment bool
+
+	// ncTags maps tag names to Nextcloud system tag IDs, filled on first use.
+	ncTags map[string]string
+)
+
+var nextcloudCmd = &cobra.Command{
+	Use:   "nextcloud <remote folder>...",
+	Short: "Rename files on a Nextcloud server over WebDAV and tag them",
+	Long: `Walks folders on a Nextcloud server over WebDAV without a local sync. Each
+matching file is downloaded to a temporary directory, described and named, and
+once you confirm it is renamed on the server. The description and tags are
+stored as a Nextcloud comment and system tags on the file.
+
+Folders are paths in your Nextcloud files, e.g. "Photos/2024". The server, user
+and an app password come from --server, --user and --password, or
+NEXTCLOUD_URL, NEXTCLOUD_USER and NEXTCLOUD_PASSWORD.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for flag, env := range map[*string]string{&ncURL: "NEXTCLOUD_URL", &ncUser: "NEXTCLOUD_USER", &ncPassword: "NEXTCLOUD_PASSWORD"} {
+			if *flag == "" {
+				*flag = os.Getenv(env)
+			}
+		}
+		if ncURL == "" || ncUser == "" || ncPassword == "" {
+			return fmt.Errorf("need a Nextcloud server, user and app password (--server/--user/--password or NEXTCLOUD_URL/NEXTCLOUD_USER/NEXTCLOUD_PASSWORD)")
+		}
+		ncURL = strings.TrimSuffix(ncURL, "/")
+		if err := loadTemplate(); err != nil {
+			return err
+		}
+		tmpDir, err := os.MkdirTemp("", "tell-me-more-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		for _, folder := range args {
+			root := "/remote.php/dav/files/" + url.PathEscape(ncUser) + "/" + escapePath(strings.Trim(folder, "/"))
+			err := ncWalk(root, func(f davEntry) { processNextcloudFile(f, tmpDir) })
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	nextcloudCmd.Flags().StringVar(&ncURL, "server", "", "Nextcloud URL, e.g. https://cloud.example.com")
+	nextcloudCmd.Flags().StringVar(&ncUser, "user", "", "Nextcloud user name")
+	nextcloudCmd.Flags().StringVar(&ncPassword, "password", "", "Nextcloud app password")
+	nextcloudCmd.Flags().BoolVar(&ncNoTags, "no-tags", false, "don't add system tags")
+	nextcloudCmd.Flags().BoolVar(&ncNoComment, "no-comment", false, "don't add the description as a comment")
+	rootCmd.AddCommand(nextcloudCmd)
+}
+
+// davEntry is one file in a WebDAV listing; href is escaped and absolute
+// on the server.
+type davEntry struct {
+	href   string
+	name   string
+	fileID string
+	dir    bool
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				FileID       string `xml:"http://owncloud.org/ns fileid"`
+				ID           string `xml:"http://owncloud.org/ns id"`
+				DisplayName  string `xml:"http://owncloud.org/ns display-name"`
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+const ncListProps = `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">
+  <d:prop><d:resourcetype/><oc:fileid/></d:prop>
+</d:propfind>`
+
+// ncWalk lists dir one level at a time, since many servers refuse
+// "Depth: infinity", and calls fn for each target file below it as it
+// goes.
+func ncWalk(dir string, fn func(davEntry)) error {
+	entries, err := ncList(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		unescaped, _ := url.PathUnescape(e.href)
+		switch {
+		case e.dir && skipDir(dir, unescaped):
+		case e.dir:
+			if err := ncWalk(e.href, fn); err != nil {
+				return err
+			}
+		case isTargetFile(e.name):
+			fn(e)
+		}
+	}
+	return nil
+}
+
+func ncList(dir string) ([]davEntry, error) {
+	resp, err := ncRequest("PROPFIND", dir+"/", http.Header{"Depth": {"1"}, "Content-Type": {"application/xml"}}, strings.NewReader(ncListProps))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("listing %s: %v", dir, err)
+	}
+	var entries []davEntry
+	for _, r := range ms.Responses {
+		if sameHref(r.Href, dir) || len(r.Propstat) == 0 {
+			continue // the folder itself
+		}
+		prop := r.Propstat[0].Prop
+		name, _ := url.PathUnescape(path.Base(strings.TrimSuffix(r.Href, "/")))
+		entries = append(entries, davEntry{
+			href:   strings.TrimSuffix(r.Href, "/"),
+			name:   name,
+			fileID: prop.FileID,
+			dir:    prop.ResourceType.Collection != nil,
+		})
+	}
+	return entries, nil
+}
+
+func processNextcloudFile(f davEntry, tmpDir string) {
+	rec := fileRecord{Path: f.name, Action: actionSkipped}
+	defer func() { emitRecord(rec) }()
+	fail := func(err error) {
+		log.Printf("Error processing %s: %v", f.name, err)
+		rec.Action, rec.Error = actionError, err.Error()
+	}
+
+	// The local copy keeps the remote name so templates see the original.
+	local := filepath.Join(tmpDir, f.name)
+	if err := ncDownload(f.href, local); err != nil {
+		fail(err)
+		return
+	}
+	defer os.Remove(local)
+
+	fmt.Fprintf(msgOut, "Found target file: %s\n", f.name)
+	s := describeAndSuggest(local)
+	if s.err != nil {
+		fail(s.err)
+		return
+	}
+	rec.Description, rec.Name = s.description, sanitizeFileName(s.name)
+	fmt.Fprintf(msgOut, "Suggested description: %s\n", s.name)
+	if !confirm("Do you want to rename the file?") {
+		return
+	}
+
+	newName := rec.Name + path.Ext(f.name)
+	dest := path.Dir(f.href) + "/" + url.PathEscape(newName)
+	resp, err := ncRequest("MOVE", f.href, http.Header{"Destination": {ncURL + dest}, "Overwrite": {"F"}}, nil)
+	if err != nil {
+		fail(err)
+		return
+	}
+	resp.Body.Close()
+	rec.Action, rec.NewPath = actionRenamed, newName
+	fmt.Fprintf(msgOut, "Renamed %s to %s\n", f.name, newName)
+	audit("move", ncURL+f.href, ncURL+dest, local)
+
+	if s.description == "" || (ncNoTags && ncNoComment) || f.fileID == "" {
+		return
+	}
+	caption, tags, err := getCaptionAndTags(s.description)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if !ncNoComment {
+		if err := ncComment(f.fileID, caption); err != nil {
+			fail(err)
+			return
+		}
+	}
+	if !ncNoTags {
+		for _, tag := range tags {
+			if err := ncTag(f.fileID, tag); err != nil {
+				fail(err)
+				return
+			}
+		}
+		fmt.Fprintf(msgOut, "  tags: %s\n", strings.Join(tags, ", "))
+	}
+}
+
+func ncDownload(href, local string) error {
+	resp, err := ncRequest(http.MethodGet, href, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	out, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func ncComment(fileID, message string) error {
+	body, _ := json.Marshal(map[string]string{"actorType": "users", "verb": "comment", "message": message})
+	resp, err := ncRequest(http.MethodPost, "/remote.php/dav/comments/files/"+fileID, http.Header{"Content-Type": {"application/json"}}, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("adding comment: %v", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ncTag assigns the system tag called name to a file, creating the tag if
+// the server doesn't have it yet.
+func ncTag(fileID, name string) error {
+	id, err := ncTagID(name)
+	if err != nil {
+		return fmt.Errorf("finding tag %s: %v", name, err)
+	}
+	resp, err := ncRequest(http.MethodPut, "/remote.php/dav/systemtags-relations/files/"+fileID+"/"+id, nil, nil)
+	if err != nil && !strings.Contains(err.Error(), "409") { // already tagged
+		return fmt.Errorf("adding tag %s: %v", name, err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+const ncTagProps = `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">
+  <d:prop><oc:id/><oc:display-name/></d:prop>
+</d:propfind>`
+
+func ncTagID(name string) (string, error) {
+	if ncTags == nil {
+		resp, err := ncRequest("PROPFIND", "/remote.php/dav/systemtags/", http.Header{"Depth": {"1"}, "Content-Type": {"application/xml"}}, strings.NewReader(ncTagProps))
+		if err != nil {
+			return "", err
+		}
+		var ms davMultistatus
+		err = xml.NewDecoder(resp.Body).Decode(&ms)
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		ncTags = map[string]string{}
+		for _, r := range ms.Responses {
+			if len(r.Propstat) > 0 && r.Propstat[0].Prop.ID != "" {
+				ncTags[strings.ToLower(r.Propstat[0].Prop.DisplayName)] = r.Propstat[0].Prop.ID
+			}
+		}
+	}
+	if id, ok := ncTags[strings.ToLower(name)]; ok {
+		return id, nil
+	}
+
+	body, _ := json.Marshal(map[string]any{"name": name, "userVisible": true, "userAssignable": true, "canAssign": true})
+	resp, err := ncRequest(http.MethodPost, "/remote.php/dav/systemtags/", http.Header{"Content-Type": {"application/json"}}, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	id := path.Base(resp.Header.Get("Content-Location"))
+	if id == "" || id == "." || id == "/" {
+		return "", fmt.Errorf("no tag ID in the response")
+	}
+	ncTags[strings.ToLower(name)] = id
+	return id, nil
+}
+
+// ncRequest sends an authenticated request to href on the server. Any
+// status of 300 or above is returned as an error.
+func ncRequest(method, href string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, ncURL+href, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.SetBasicAuth(ncUser, ncPassword)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, href, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// sameHref compares two hrefs regardless of how each was escaped.
+func sameHref(a, b string) bool {
+	ua, errA := url.PathUnescape(strings.TrimSuffix(a, "/"))
+	ub, errB := url.PathUnescape(strings.TrimSuffix(b, "/"))
+	return errA == nil && errB == nil && ua == ub
+}
+
+// escapePath escapes each element of a slash-separated path.
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var ocrMode bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&ocrMode, "ocr", false, "read the text in images with Tesseract, in the --lang languages, give it to the model with the image, and name text-heavy images from it when the model can't be reached")
+}
+
+const (
+	// ocrPromptLimit is how much of the text read goes into a prompt.
+	ocrPromptLimit = 1500
+	// ocrMinConfidence is the lowest Tesseract confidence, out of 100, a
+	// word is kept at.
+	ocrMinConfidence = 60
+	// ocrMinWords is how many words an image needs to be named from its
+	// text alone.
+	ocrMinWords = 3
+)
+
+// validateOCR checks up front that Tesseract is there for --ocr.
+func validateOCR() error {
+	if !ocrMode {
+		return nil
+	}
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return fmt.Errorf("--ocr needs tesseract (https://tesseract-ocr.github.io) on the PATH")
+	}
+	return nil
+}
+
+// ocrResult is the text Tesseract read in an image.
+type ocrResult struct {
+	// Text is every line read, top to bottom.
+	Text string
+	// Dominant is the line in the biggest type, which on most screenshots
+	// is the title of what they show.
+	Dominant string
+	Words    int
+}
+
+// ocrResults keeps what each image read as, since the prompt and the
+// fallback both ask.
+var ocrResults sync.Map
+
+// readText runs Tesseract on the image at path. Errors are logged and
+// read as no text, which is what naming without OCR works from anyway.
+func readText(path string) ocrResult {
+	if !ocrMode || !isImageFile(path) {
+		return ocrResult{}
+	}
+	if r, ok := ocrResults.Load(path); ok {
+		return r.(ocrResult)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "tesseract", path, "stdout", "-l", tesseractLangs(), "tsv")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var r ocrResult
+	if err != nil {
+		log.Printf("Error reading the text in %s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	} else {
+		r = parseTesseractTSV(out)
+	}
+	ocrResults.Store(path, r)
+	return r
+}
+
+// parseTesseractTSV puts the words of Tesseract's TSV output back into
+// lines, keeping those it is reasonably sure of.
+func parseTesseractTSV(out []byte) ocrResult {
+	type line struct {
+		words  []string
+		height int
+	}
+	var lines []*line
+	byKey := map[string]*line{}
+	for i, row := range strings.Split(string(out), "\n") {
+		// level page block par line word left top width height conf text
+		cols := strings.Split(row, "\t")
+		if i == 0 || len(cols) < 12 || cols[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(cols[11])
+		conf, _ := strconv.ParseFloat(cols[10], 64)
+		if text == "" || conf < ocrMinConfidence {
+			continue
+		}
+		key := strings.Join(cols[1:5], ".")
+		l := byKey[key]
+		if l == nil {
+			l = &line{}
+			byKey[key] = l
+			lines = append(lines, l)
+		}
+		l.words = append(l.words, text)
+		if h, _ := strconv.Atoi(cols[9]); h > l.height {
+			l.height = h
+		}
+	}
+
+	var r ocrResult
+	var text []string
+	dominant := 0
+	for _, l := range lines {
+		s := strings.Join(l.words, " ")
+		text = append(text, s)
+		r.Words += len(l.words)
+		if l.height > dominant && letters(s) >= 3 {
+			r.Dominant, dominant = s, l.height
+		}
+	}
+	r.Text = strings.Join(text, "\n")
+	return r
+}
+
+func letters(s string) int {
+	n := 0
+	for _, c := range s {
+		if unicode.IsLetter(c) {
+			n++
+		}
+	}
+	return n
+}
+
+// ocrHint is the text read in the image at path, to go at the end of a
+// prompt about it, or "" without --ocr.
+func ocrHint(path string) string {
+	r := readText(path)
+	if r.Text == "" {
+		return ""
+	}
+	text := r.Text
+	if len(text) > ocrPromptLimit {
+		text = strings.ToValidUTF8(text[:ocrPromptLimit], "") + "…"
+	}
+	return "\n\nThis is the text OCR read in the image, which may have mistakes:\n" + text
+}
+
+// nameFromText names the image at path after its most prominent line of
+// text, for when the model can't be reached. It returns "" for images
+// with too little text to go by.
+func nameFromText(path string) string {
+	r := readText(path)
+	if r.Words < ocrMinWords || r.Dominant == "" {
+		return ""
+	}
+	name := slugify(r.Dominant, 40)
+	if namingMode != "seo" {
+		name = strings.ReplaceAll(name, "-", "_")
+	}
+	return name
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTesseractTSV(t *testing.T) {
+	header := "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext"
+	word := func(line, height, conf, text string) string {
+		return strings.Join([]string{"5", "1", "1", "1", line, "1", "0", "0", "10", height, conf, text}, "\t")
+	}
+	tests := []struct {
+		name string
+		rows []string
+		want ocrResult
+	}{
+		{
+			name: "empty",
+			want: ocrResult{},
+		},
+		{
+			name: "words join into lines",
+			rows: []string{
+				word("1", "40", "95", "Invoice"),
+				word("1", "38", "91", "2024"),
+				word("2", "12", "88", "Total"),
+			},
+			want: ocrResult{Text: "Invoice 2024\nTotal", Dominant: "Invoice 2024", Words: 3},
+		},
+		{
+			name: "unsure and empty words are dropped",
+			rows: []string{
+				word("1", "12", "30", "g4rb@ge"),
+				word("1", "12", "90", " "),
+				word("1", "12", "90", "Settings"),
+			},
+			want: ocrResult{Text: "Settings", Dominant: "Settings", Words: 1},
+		},
+		{
+			name: "only word rows count",
+			rows: []string{
+				strings.Join([]string{"4", "1", "1", "1", "1", "0", "0", "0", "10", "90", "-1", ""}, "\t"),
+				word("1", "20", "90", "Inbox"),
+			},
+			want: ocrResult{Text: "Inbox", Dominant: "Inbox", Words: 1},
+		},
+		{
+			name: "the dominant line needs letters",
+			rows: []string{
+				word("1", "80", "95", "42"),
+				word("2", "14", "95", "Total due"),
+			},
+			want: ocrResult{Text: "42\nTotal due", Dominant: "Total due", Words: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := strings.Join(append([]string{header}, tt.rows...), "\n") + "\n"
+			if got := parseTesseractTSV([]byte(out)); got != tt.want {
+				t.Errorf("parseTesseractTSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
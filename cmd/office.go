@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// officeTextLimit keeps huge documents from blowing the naming prompt.
+const officeTextLimit = 4000
+
+func isOfficeFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".docx", ".pptx", ".xlsx":
+		return true
+	}
+	return false
+}
+
+// describeOffice pulls the readable text out of an Office Open XML file:
+// body text for documents, slide titles and text for decks, sheet names
+// and cell strings for workbooks.
+func describeOffice(path string) (string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	files := map[string]*zip.File{}
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+
+	var b strings.Builder
+	if title := officeTitle(files["docProps/core.xml"]); title != "" {
+		fmt.Fprintf(&b, "Document title: %s\n", title)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".docx":
+		b.WriteString("A Word document with this text:\n")
+		b.WriteString(zipXMLText(files["word/document.xml"], "t", "p"))
+	case ".pptx":
+		b.WriteString("A PowerPoint deck with these slides:\n")
+		for i, f := range slides(files) {
+			title, body := slideText(f)
+			fmt.Fprintf(&b, "Slide %d: %s\n%s\n", i+1, title, body)
+			if b.Len() > officeTextLimit {
+				break
+			}
+		}
+	case ".xlsx":
+		b.WriteString("An Excel workbook")
+		if names := sheetNames(files["xl/workbook.xml"]); len(names) > 0 {
+			fmt.Fprintf(&b, " with sheets %s", strings.Join(names, ", "))
+		}
+		b.WriteString(" containing these cell values:\n")
+		b.WriteString(zipXMLText(files["xl/sharedStrings.xml"], "t", "si"))
+	}
+
+	text := b.String()
+	if len(text) > officeTextLimit {
+		text = strings.ToValidUTF8(text[:officeTextLimit], "") + "\n..."
+	}
+	return text, nil
+}
+
+// zipXMLText concatenates the character data inside every textElem, with
+// a newline after each breakElem.
+func zipXMLText(f *zip.File, textElem, breakElem string) string {
+	if f == nil {
+		return ""
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+
+	var b strings.Builder
+	dec := xml.NewDecoder(rc)
+	inText := false
+	for b.Len() < officeTextLimit {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inText = t.Name.Local == textElem
+		case xml.EndElement:
+			if t.Name.Local == textElem {
+				inText = false
+			}
+			if t.Name.Local == breakElem {
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return collapseBlankLines(b.String())
+}
+
+func collapseBlankLines(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func officeTitle(f *zip.File) string {
+	if f == nil {
+		return ""
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	var core struct {
+		Title string `xml:"title"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&core); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(core.Title)
+}
+
+// slides returns ppt/slides/slideN.xml in slide-number order.
+func slides(files map[string]*zip.File) []*zip.File {
+	type numbered struct {
+		n int
+		f *zip.File
+	}
+	var found []numbered
+	for name, f := range files {
+		rest, ok := strings.CutPrefix(name, "ppt/slides/slide")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(rest, ".xml")); err == nil {
+			found = append(found, numbered{n, f})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].n < found[j].n })
+	out := make([]*zip.File, len(found))
+	for i, s := range found {
+		out[i] = s.f
+	}
+	return out
+}
+
+// slideText splits a slide's text into the title placeholder's text and
+// everything else.
+func slideText(f *zip.File) (title, body string) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", ""
+	}
+	defer rc.Close()
+
+	var titleB, bodyB strings.Builder
+	dec := xml.NewDecoder(rc)
+	var shapeText strings.Builder
+	isTitle, inText := false, false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF || err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp":
+				shapeText.Reset()
+				isTitle = false
+			case "ph":
+				for _, a := range t.Attr {
+					if a.Name.Local == "type" && (a.Value == "title" || a.Value == "ctrTitle") {
+						isTitle = true
+					}
+				}
+			case "t":
+				inText = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				shapeText.WriteString(" ")
+			case "sp":
+				text := strings.TrimSpace(shapeText.String())
+				if isTitle {
+					titleB.WriteString(text)
+				} else if text != "" {
+					bodyB.WriteString(text + "\n")
+				}
+			}
+		case xml.CharData:
+			if inText {
+				shapeText.Write(t)
+			}
+		}
+	}
+	return titleB.String(), strings.TrimSpace(bodyB.String())
+}
+
+func sheetNames(f *zip.File) []string {
+	if f == nil {
+		return nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&wb); err != nil {
+		return nil
+	}
+	var names []string
+	for _, s := range wb.Sheets {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func getNameForDocument(text string) (string, error) {
+	prompt := fmt.Sprintf(`You are a creative assistant that generates human-like filenames for office documents.
+
+%s
+
+Work out what this document is and suggest a short, descriptive, and human-friendly filename for it (without file extension), for example 'q3_board_deck_budget_review' or 'apartment_lease_agreement'.
+
+Make sure the name suggestion is under 40 characters, the fewer words the better:`, text)
+	return askChatGPT(prompt)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ollamaModel = "llava"
+
+	// ollamaClient waits longer than httpClient: a local model on a CPU
+	// can take minutes over one large image.
+	ollamaClient = &http.Client{Timeout: 10 * time.Minute}
+)
+
+func init() {
+	registerProvider("ollama", ollama{})
+	registerModel("ollama", &ollamaModel)
+}
+
+// ollama runs everything through a local Ollama server, OLLAMA_HOST or
+// localhost:11434, so no file leaves the machine and no API key is needed.
+type ollama struct{}
+
+func (o ollama) Describe(ctx context.Context, imagePath string) (string, error) {
+	return o.AskAboutFile(ctx, imagePath, describePrompt())
+}
+
+func (o ollama) SuggestName(ctx context.Context, description string) (string, error) {
+	return o.Ask(ctx, namingPrompt(description))
+}
+
+func (ollama) Ask(ctx context.Context, prompt string) (string, error) {
+	return askOllama(ctx, nil, prompt)
+}
+
+func (ollama) AskAboutImage(ctx context.Context, data []byte, mimeType, prompt string) (string, error) {
+	data, _, err := fitImageBytes(data, mimeType)
+	if err != nil {
+		return "", err
+	}
+	return askOllama(ctx, data, prompt)
+}
+
+func (o ollama) AskAboutFile(ctx context.Context, path, prompt string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading image file: %v", err)
+	}
+	return o.AskAboutImage(ctx, data, imageMIMEType(path), prompt)
+}
+
+// ollamaURL is the server's generate endpoint, taking OLLAMA_HOST the way
+// the ollama CLI does: a bare host:port or a full URL.
+func ollamaURL() string {
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		host = "127.0.0.1:11434"
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/") + "/api/generate"
+}
+
+type ollamaRequest struct {
+	Model  string   `json:"model"`
+	Prompt string   `json:"prompt"`
+	Images []string `json:"images,omitempty"`
+	// Format is "json" to hold the model to a JSON reply.
+	Format string `json:"format,omitempty"`
+	Stream bool   `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Model    string `json:"model"`
+	Response string `json:"response"`
+	Error    string `json:"error"`
+	// PromptEvalCount and EvalCount are the tokens read and generated.
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+// askOllama sends prompt, with the image in data if there is one.
+func askOllama(ctx context.Context, data []byte, prompt string) (string, error) {
+	content := ""
+	key := cacheKey("ollama", ollamaModel, prompt)
+	if data != nil {
+		sum := sha256.Sum256(data)
+		content = hex.EncodeToString(sum[:])
+		key = cacheKey("ollama", content, ollamaModel, prompt, uploadVariant())
+	}
+	return cachedAnswer(key, content, prompt, func() (string, string, error) {
+		reply, err := retrying("ollama", false, func() (string, error) {
+			return askOllamaUncached(ctx, data, prompt)
+		})
+		return reply, ollamaModel, err
+	})
+}
+
+func askOllamaUncached(ctx context.Context, data []byte, prompt string) (string, error) {
+	r := ollamaRequest{Model: ollamaModel, Prompt: prompt}
+	if wantsNamingJSON(prompt) {
+		r.Format = "json"
+	}
+	if data != nil {
+		r.Images = []string{base64.StdEncoding.EncodeToString(data)}
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ollamaURL(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ollamaClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Ollama API error (is `ollama serve` running?): %v", err)
+	}
+	defer resp.Body.Close()
+	var result ollamaResponse
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(msg, &result) == nil && result.Error != "" {
+			return "", fmt.Errorf("Ollama API error: %s", result.Error)
+		}
+		return "", fmt.Errorf("Ollama API error: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding Ollama response: %v", err)
+	}
+	reply := strings.TrimSpace(result.Response)
+	if reply == "" {
+		return "", fmt.Errorf("no response from Ollama")
+	}
+	rememberReply(reply, result.Model, prompt)
+	recordUsage(reply, "ollama/"+result.Model, result.PromptEvalCount, result.EvalCount)
+	return reply, nil
+}
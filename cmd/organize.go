@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// organizing is set by organize: accepted files move into a folder named
+// after their category as well as being renamed.
+var organizing bool
+
+var organizeCmd = &cobra.Command{
+	Use:   "organize <dir>...",
+	Short: "Rename files and move each into a folder named after its category",
+	Long: `Works like tell-me-more itself, with the same flags, but every file you accept
+also moves into a subfolder of its own folder named after its category:
+screenshot/, receipt/, meme/ and so on. --categories sets the folders the
+model chooses from; anything that fits none of them goes to other/.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		organizing = true
+		return rootCmd.RunE(cmd, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(organizeCmd)
+}
+
+// shareRootFlags gives organize the root command's own flags, such as
+// --dry-run and --triage. They are added once every file has defined
+// its flags, which init order doesn't promise.
+func shareRootFlags() {
+	organizeCmd.Flags().AddFlagSet(rootCmd.Flags())
+}
+
+// dir is the folder s's file goes to when accepted: its own, unless
+// organize moves it into its category's. A file already in that folder
+// stays where it is.
+func (s suggestion) dir() string {
+	dir := filepath.Dir(s.path)
+	if !organizing {
+		return dir
+	}
+	category := sanitizeFileName(fileCategory(s))
+	if category == "" || filepath.Base(dir) == category {
+		return dir
+	}
+	return filepath.Join(dir, category)
+}
+
+// fileCategory is the category the model gave s, or for file types named
+// without it, the one their type suggests. Anything outside --categories
+// is "other".
+func fileCategory(s suggestion) string {
+	category := s.category
+	if category == "" {
+		switch {
+		case isAudioFile(s.path):
+			category = "audio"
+		case isZipFile(s.path):
+			category = "archive"
+		case isOfficeFile(s.path) || isEPUBFile(s.path) || isPDFFile(s.path):
+			category = "document"
+		}
+	}
+	if !slices.Contains(namingCategories, category) {
+		return "other"
+	}
+	return category
+}
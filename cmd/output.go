@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileRecord is what --output ndjson writes for every processed file.
+type fileRecord struct {
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	NewPath     string `json:"new_path,omitempty"`
+	Action      string `json:"action"`
+	Error       string `json:"error,omitempty"`
+	// Confidence is the model's 0-1 score for the name.
+	Confidence *float64 `json:"confidence,omitempty"`
+	// Tags and Category come with the name from the naming request.
+	Tags     []string `json:"tags,omitempty"`
+	Category string   `json:"category,omitempty"`
+	// Alternatives are the other names offered with --candidates.
+	Alternatives []string `json:"alternatives,omitempty"`
+	// Junk says why the file looked like an accidental capture.
+	Junk string `json:"junk,omitempty"`
+	// InputTokens, OutputTokens and CostUSD are what describing and naming
+	// the file used; CostUSD is left out when a model's price is unknown.
+	InputTokens  int      `json:"input_tokens,omitempty"`
+	OutputTokens int      `json:"output_tokens,omitempty"`
+	CostUSD      *float64 `json:"cost_usd,omitempty"`
+}
+
+const (
+	actionRenamed     = "renamed"
+	actionSkipped     = "skipped"
+	actionDeferred    = "deferred"
+	actionQuarantined = "quarantined"
+	actionPlanned     = "planned"
+	actionDeleted     = "deleted"
+	actionError       = "error"
+)
+
+var (
+	outputFormat string
+	jsonOutput   bool
+
+	// msgOut receives the human-readable progress messages. It is moved to
+	// stderr when stdout is reserved for machine-readable records.
+	msgOut io.Writer = os.Stdout
+
+	recordEnc *json.Encoder
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "output format: text or ndjson")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "same as --output ndjson")
+	rootCmd.MarkFlagsMutuallyExclusive("output", "json")
+}
+
+// setupOutput validates --output and routes messages accordingly.
+func setupOutput() error {
+	if jsonOutput {
+		outputFormat = "ndjson"
+	}
+	switch outputFormat {
+	case "text":
+		msgOut = os.Stdout
+		recordEnc = nil
+	case "ndjson":
+		msgOut = os.Stderr
+		recordEnc = json.NewEncoder(os.Stdout)
+	default:
+		return fmt.Errorf("unknown output format %q (want text or ndjson)", outputFormat)
+	}
+	return nil
+}
+
+// emitRecord writes rec as a single line as soon as the file is done, so
+// consumers can react while a long run is still going.
+func emitRecord(rec fileRecord) {
+	if rec.Action == actionError {
+		recordFailure(rec)
+	}
+	if u := usageForFile(rec.Path); u.input+u.output > 0 {
+		rec.InputTokens, rec.OutputTokens = u.input, u.output
+		if !u.unpriced {
+			rec.CostUSD = &u.cost
+		}
+	}
+	if recordEnc == nil {
+		return
+	}
+	if err := recordEnc.Encode(rec); err != nil {
+		fmt.Fprintf(os.Stderr, "writing record: %v\n", err)
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pdfRenderSize is the longer side, in pixels, page 1 is rendered at:
+// enough to read an invoice's small print without a huge upload.
+const pdfRenderSize = 1600
+
+func isPDFFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+// scanNamePattern matches the names scanners and scanning apps give PDFs:
+// "Scan 2023-11-04.pdf", "scan0001.pdf", "Scanned Document.pdf".
+var scanNamePattern = regexp.MustCompile(`(?i)^scan`)
+
+// isUnnamedPDF reports whether name is one of the names PDFs arrive with
+// that say nothing about them.
+func isUnnamedPDF(name string) bool {
+	return isPDFFile(name) && (scanNamePattern.MatchString(filepath.Base(name)) || isMeaninglessName(name))
+}
+
+// describePDF renders the first page of a PDF and describes it like an
+// image: the first page of a scan, an invoice or a deck says what the
+// rest is.
+func describePDF(path string) (string, error) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		return "", fmt.Errorf("describing PDFs needs pdftoppm, part of Poppler (https://poppler.freedesktop.org), on the PATH")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "pdftoppm", "-f", "1", "-l", "1", "-singlefile", "-png",
+		"-scale-to", fmt.Sprint(pdfRenderSize), path, "-")
+	cmd.Stderr = &stderr
+	page, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("rendering the first page of %s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	prompt := `This is the first page of a PDF document. Say what kind of document it is (an invoice, receipt, letter, contract, statement, form, slides, a paper, a scanned photo...), who it is from and who it is for, and the title, dates, reference numbers and amounts that matter.` + langPrompt()
+	return askGeminiAboutImage(page, "image/png", prompt)
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	photoprismURL   string
+	photoprismToken string
+	photoprismForce bool
+)
+
+// photoprismFile is the part of a PhotoPrism file record we read.
+type photoprismFile struct {
+	PhotoUID string `json:"PhotoUID"`
+}
+
+type photoprismPhoto struct {
+	UID            string `json:"UID"`
+	Title          string `json:"Title"`
+	TitleSrc       string `json:"TitleSrc"`
+	Description    string `json:"Description"`
+	DescriptionSrc string `json:"DescriptionSrc"`
+}
+
+var photoprismCmd = &cobra.Command{
+	Use:   "photoprism <dir or image>...",
+	Short: "Push titles, descriptions and labels for local photos to PhotoPrism",
+	Long: `Describes the given photos and updates the matching photos in a PhotoPrism
+library with a title, a description and labels. Photos are matched by the SHA-1
+of their file, the hash PhotoPrism indexes them by, so nothing is uploaded or
+re-imported and the local files are not renamed.
+
+Titles and descriptions someone already edited in PhotoPrism are left alone
+unless --force is given. The server and an app password come from --server and
+--token, or PHOTOPRISM_URL and PHOTOPRISM_TOKEN.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if photoprismURL == "" {
+			photoprismURL = os.Getenv("PHOTOPRISM_URL")
+		}
+		if photoprismToken == "" {
+			photoprismToken = os.Getenv("PHOTOPRISM_TOKEN")
+		}
+		if photoprismURL == "" || photoprismToken == "" {
+			return fmt.Errorf("need a PhotoPrism server and token (--server/--token or PHOTOPRISM_URL/PHOTOPRISM_TOKEN)")
+		}
+		photoprismURL = strings.TrimSuffix(photoprismURL, "/")
+		if err := setupOutput(); err != nil {
+			return err
+		}
+
+		var images []string
+		for _, arg := range args {
+			if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+				images = append(images, arg)
+				continue
+			}
+			found, err := folderImages(arg)
+			if err != nil {
+				return err
+			}
+			images = append(images, found...)
+		}
+		for _, path := range images {
+			pushToPhotoPrism(path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	photoprismCmd.Flags().StringVar(&photoprismURL, "server", "", "PhotoPrism URL, e.g. http://photoprism.local:2342")
+	photoprismCmd.Flags().StringVar(&photoprismToken, "token", "", "PhotoPrism app password or access token")
+	photoprismCmd.Flags().BoolVar(&photoprismForce, "force", false, "overwrite titles and descriptions edited in PhotoPrism")
+	rootCmd.AddCommand(photoprismCmd)
+}
+
+func pushToPhotoPrism(path string) {
+	rec := fileRecord{Path: path, Action: actionSkipped}
+	defer func() { emitRecord(rec) }()
+	fail := func(err error) {
+		log.Printf("Error updating %s in PhotoPrism: %v", path, err)
+		rec.Action, rec.Error = actionError, err.Error()
+	}
+
+	hash, err := fileSHA1(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var file photoprismFile
+	if err := photoprismRequest(http.MethodGet, "/api/v1/files/"+hash, nil, &file); err != nil {
+		fmt.Fprintf(msgOut, "%s is not in the PhotoPrism library, skipping\n", path)
+		return
+	}
+	var photo photoprismPhoto
+	if err := photoprismRequest(http.MethodGet, "/api/v1/photos/"+file.PhotoUID, nil, &photo); err != nil {
+		fail(err)
+		return
+	}
+
+	description, err := describeFile(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	rec.Description = description
+	caption, labels, err := getCaptionAndTags(description)
+	if err != nil {
+		fail(err)
+		return
+	}
+	name, err := suggestName(path, description)
+	if err != nil {
+		fail(err)
+		return
+	}
+	rec.Name = sanitizeFileName(name)
+
+	update := map[string]string{}
+	if photoprismForce || photo.TitleSrc != "manual" {
+		update["Title"], update["TitleSrc"] = photoTitle(name), "manual"
+	}
+	if photoprismForce || photo.DescriptionSrc != "manual" {
+		update["Description"], update["DescriptionSrc"] = caption, "manual"
+	}
+	if len(update) > 0 {
+		if err := photoprismRequest(http.MethodPut, "/api/v1/photos/"+file.PhotoUID, update, nil); err != nil {
+			fail(err)
+			return
+		}
+	}
+	for _, label := range labels {
+		body := map[string]any{"Name": label, "Uncertainty": 0, "Priority": 10}
+		if err := photoprismRequest(http.MethodPost, "/api/v1/photos/"+file.PhotoUID+"/label", body, nil); err != nil {
+			fail(fmt.Errorf("adding label %s: %v", label, err))
+			return
+		}
+	}
+	fmt.Fprintf(msgOut, "Updated %s: %s [%s]\n", path, caption, strings.Join(labels, ", "))
+}
+
+// photoTitle turns a suggested file name into a readable title:
+// "blue_ceramic_coffee_dripper" becomes "Blue ceramic coffee dripper".
+func photoTitle(name string) string {
+	title := strings.Join(strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }), " ")
+	if title == "" {
+		return title
+	}
+	return strings.ToUpper(title[:1]) + title[1:]
+}
+
+func fileSHA1(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func photoprismRequest(method, path string, body, out any) error {
+	return jsonRequest(method, photoprismURL+path, http.Header{"Authorization": {"Bearer " + photoprismToken}}, body, out)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// pipeline is --pipeline: how many requests it takes to name an image.
+var pipeline = pipelineMode("default")
+
+// visionModel is the model the openai-vision pipeline sends images to.
+var visionModel = openai.GPT4o
+
+func init() {
+	rootCmd.PersistentFlags().Var(&pipeline, "pipeline", "how images are named: default (describe, then name), openai-vision (one GPT-4o vision request) or gemini (one Gemini request, no OpenAI key needed)")
+}
+
+type pipelineMode string
+
+func (p *pipelineMode) Set(s string) error {
+	switch s {
+	case "default", "openai-vision", "gemini":
+		*p = pipelineMode(s)
+		return nil
+	}
+	return fmt.Errorf("unknown pipeline %q (want default, openai-vision or gemini)", s)
+}
+
+func (p *pipelineMode) String() string { return string(*p) }
+
+func (p *pipelineMode) Type() string { return "mode" }
+
+// singleCall reports whether path is named by one vision request instead
+// of a description followed by a naming prompt. Animated GIFs are always
+// described first, from several of their frames.
+func singleCall(path string) bool {
+	return (pipeline == "openai-vision" || pipeline == "gemini") && isImageFile(path) && !isAnimatedGIF(path)
+}
+
+// describeAndName names the image at path in the one request --pipeline
+// asks for.
+func describeAndName(path string) (namingResult, error) {
+	if pipeline == "gemini" {
+		return describeAndNameWithGemini(path)
+	}
+	return describeAndNameWithVision(path)
+}
+
+// visionPrompt asks for a one-sentence description along with the name,
+// so triage and tags still have something to go on.
+func visionPrompt(path string) string {
+	return `You are a creative assistant that generates human-like filenames for images.
+
+Look at this image and suggest a short, descriptive, and human-friendly filename for it (without file extension). For example a screenshot of the youtube website would be 'youtube_homepage'. The name MUST be under 40 characters, the fewer words the better.` + chatMediaNameHint() + langNameHint() + ocrHint(path) + namingJSONFormat(true)
+}
+
+// describeAndNameWithVision sends the image at path straight to GPT-4o and
+// returns its description and suggested name.
+func describeAndNameWithVision(path string) (namingResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return namingResult{}, fmt.Errorf("reading image file: %v", err)
+	}
+	mimeType := imageMIMEType(path)
+	sum := sha256.Sum256(data)
+	content := hex.EncodeToString(sum[:])
+	prompt := visionPrompt(path)
+
+	reply, err := cachedAnswer(cacheKey("openai-vision", content, visionModel, prompt, uploadVariant()), content, prompt, func() (string, string, error) {
+		reply, err := withKey(openaiKeys, func(apiKey string) (string, error) {
+			return askVisionWithKey(apiKey, data, mimeType, prompt)
+		})
+		return reply, visionModel, err
+	})
+	if err != nil {
+		return namingResult{}, err
+	}
+	return parseNaming(reply, "GPT-4o vision")
+}
+
+// describeAndNameWithGemini asks Gemini for the name and description of
+// the image at path in the same request that shows it the image.
+func describeAndNameWithGemini(path string) (namingResult, error) {
+	prompt := visionPrompt(path)
+	reply, err := askGeminiAboutFile(path, prompt)
+	if err != nil {
+		return namingResult{}, err
+	}
+	rememberReply(strings.TrimSpace(reply), geminiModel, prompt)
+	return parseNaming(reply, "Gemini")
+}
+
+func askVisionWithKey(apiKey string, data []byte, mimeType, prompt string) (string, error) {
+	if apiKey == "" {
+		return "", fmt.Errorf("OpenAI API key not set")
+	}
+	data, mimeType, err := fitImageBytes(data, mimeType)
+	if err != nil {
+		return "", err
+	}
+	uploadLimiter.wait(len(data))
+
+	level := openai.ImageURLDetailAuto
+	switch detail {
+	case "low":
+		level = openai.ImageURLDetailLow
+	case "high":
+		level = openai.ImageURLDetailHigh
+	}
+
+	client := openaiClient(apiKey)
+	resp, err := client.CreateChatCompletion(runCtx, openai.ChatCompletionRequest{
+		Model: visionModel,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{Type: openai.ChatMessagePartTypeText, Text: prompt},
+					{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{
+						URL:    "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data),
+						Detail: level,
+					}},
+				},
+			},
+		},
+		MaxTokens:      300,
+		ResponseFormat: openaiResponseFormat(visionModel, prompt),
+	})
+	if err != nil {
+		return "", fmt.Errorf("GPT-4o vision API error: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from GPT-4o vision API")
+	}
+	reply := strings.TrimSpace(resp.Choices[0].Message.Content)
+	rememberReply(reply, resp.Model, prompt)
+	recordUsage(reply, resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	return reply, nil
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// planVersion is bumped when planFile changes in a way older versions of
+// apply would misread.
+const planVersion = 1
+
+// planFile is what `plan` writes and `apply` carries out. It is meant to be
+// edited in between: change a name, or set skip on an entry.
+type planFile struct {
+	Version int         `json:"version"`
+	Created time.Time   `json:"created"`
+	Entries []planEntry `json:"entries"`
+}
+
+type planEntry struct {
+	Path string `json:"path"`
+	// SHA256 lets apply notice a file that changed after it was planned.
+	SHA256      string   `json:"sha256"`
+	Description string   `json:"description,omitempty"`
+	Name        string   `json:"name"`
+	NewPath     string   `json:"new_path,omitempty"`
+	Confidence  float64  `json:"confidence,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Junk        string   `json:"junk,omitempty"`
+	Skip        bool     `json:"skip,omitempty"`
+}
+
+var planOut string
+
+var planCmd = &cobra.Command{
+	Use:   "plan <dir or file>...",
+	Short: "Write the suggested names to a plan file without renaming anything",
+	Long: `Does all the describing and naming up front and writes the result to a JSON
+plan. Edit the names in it, or set "skip": true, then run apply on it.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 && !resume {
+			return fmt.Errorf("plan needs a directory or file to plan for")
+		}
+		args, err := prepareRun(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		plan := planFile{Version: planVersion, Created: time.Now()}
+		if resume {
+			// The interrupted run planned these already.
+			if data, err := os.ReadFile(planOut); err == nil {
+				if err := json.Unmarshal(data, &plan); err != nil {
+					return fmt.Errorf("reading %s to resume: %v", planOut, err)
+				}
+			}
+		}
+		save := func() error {
+			data, err := json.MarshalIndent(plan, "", "  ")
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(planOut, append(data, '\n'), 0o644)
+		}
+		forEachTarget(args, func(s suggestion) {
+			if runCtx.Err() != nil {
+				return // interrupted; the checkpoint is being saved
+			}
+			plan.Entries = append(plan.Entries, planSuggestion(s))
+			// Saved as it grows, so --resume picks up from the plan too.
+			if err := save(); err != nil {
+				log.Printf("Error writing %s: %v", planOut, err)
+				return
+			}
+			markDone(s.path)
+		})
+		if err := save(); err != nil {
+			return err
+		}
+		finishRun()
+		fmt.Fprintf(msgOut, "Wrote %d suggestions to %s; run `tell-me-more apply %s` to rename\n", len(plan.Entries), planOut, planOut)
+		return nil
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan.json>",
+	Short: "Rename the files in a plan written by plan",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupOutput(); err != nil {
+			return err
+		}
+		// apply writes metadata and tags as a normal run does.
+		if err := validateMetadata(); err != nil {
+			return err
+		}
+		if err := validateFinderTags(); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		var plan planFile
+		if err := json.Unmarshal(data, &plan); err != nil {
+			return fmt.Errorf("reading %s: %v", args[0], err)
+		}
+		if plan.Version > planVersion {
+			return fmt.Errorf("%s was written by a newer version (plan version %d)", args[0], plan.Version)
+		}
+
+		renamed := 0
+		for _, e := range plan.Entries {
+			if applyEntry(e) {
+				renamed++
+			}
+		}
+		if dryRun {
+			printPlan()
+			return nil
+		}
+		fmt.Fprintf(msgOut, "Renamed %d of %d files\n", renamed, len(plan.Entries))
+		return nil
+	},
+}
+
+func init() {
+	planCmd.Flags().StringVarP(&planOut, "out", "o", "plan.json", "file to write the plan to")
+	rootCmd.AddCommand(planCmd, applyCmd)
+}
+
+func planSuggestion(s suggestion) planEntry {
+	e := planEntry{Path: absPath(s.path), Description: s.description, Name: s.name, Confidence: s.confidence, Tags: s.tags, Category: s.category}
+	if sum, err := fileSHA256(s.path); err == nil {
+		e.SHA256 = sum
+	}
+	if s.err != nil {
+		e.Error, e.Skip = s.err.Error(), true
+		fmt.Fprintf(msgOut, "Could not name %s: %v\n", s.path, s.err)
+		return e
+	}
+	// Junk is skipped, not named, unless a name is filled in and skip
+	// cleared by hand.
+	if s.junk != "" {
+		e.Junk, e.Skip = s.junk, true
+		fmt.Fprintf(msgOut, "Skipping %s: it looks like an accidental capture (%s)\n", s.path, s.junk)
+		return e
+	}
+	if sanitizeFileName(s.name) == "" {
+		e.Skip = true
+		fmt.Fprintf(msgOut, "Skipping %s: no name was suggested\n", s.path)
+		return e
+	}
+	if newPath, err := targetPath(s.path, s.name); err == nil {
+		e.NewPath = absPath(newPath)
+	}
+	fmt.Fprintf(msgOut, "%s -> %s\n", s.path, filepath.Base(e.NewPath))
+	return e
+}
+
+// applyEntry renames one planned file and reports whether it did.
+func applyEntry(e planEntry) bool {
+	rec := fileRecord{Path: e.Path, Description: e.Description, Name: sanitizeFileName(e.Name), Action: actionSkipped}
+	defer func() { emitRecord(rec) }()
+	fail := func(err error) {
+		log.Printf("Not renaming %s: %v", e.Path, err)
+		rec.Action, rec.Error = actionError, err.Error()
+	}
+
+	if e.Skip {
+		return false
+	}
+	sum, err := fileSHA256(e.Path)
+	if err != nil {
+		fail(err)
+		return false
+	}
+	if e.SHA256 != "" && sum != e.SHA256 {
+		fail(fmt.Errorf("it has changed since it was planned"))
+		return false
+	}
+	// Names edited by hand can easily collide; --on-conflict says what
+	// happens then.
+	newPath, err := renameFile(e.Path, e.Name)
+	if errors.Is(err, errDryRun) {
+		rec.Action, rec.NewPath = actionPlanned, newPath
+		return false
+	}
+	if errors.Is(err, errNameTaken) {
+		fmt.Fprintf(msgOut, "Not renaming %s: %v\n", e.Path, err)
+		return false
+	}
+	if err != nil {
+		fail(err)
+		return false
+	}
+	rec.Action, rec.NewPath = actionRenamed, newPath
+	fmt.Fprintf(msgOut, "Renamed %s to %s\n", e.Path, newPath)
+	afterRename(suggestion{path: e.Path, description: e.Description, name: e.Name, confidence: e.Confidence, tags: e.Tags, category: e.Category}, newPath)
+	return true
+}
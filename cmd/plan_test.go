@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyEntryDryRun(t *testing.T) {
+	defer func(d bool) { dryRun = d }(dryRun)
+	defer func(p []plannedRename) { planned = p }(planned)
+	dryRun, planned = true, nil
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Screenshot 2024-05-03 at 10.11.12.png")
+	if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applyEntry(planEntry{Path: path, Name: "cat on sofa", SHA256: sum}) {
+		t.Error("applyEntry() reported a rename under --dry-run")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(path) {
+		t.Errorf("the directory holds %v, want only %s", entries, filepath.Base(path))
+	}
+	want := filepath.Join(dir, "cat_on_sofa.png")
+	if len(planned) != 1 || planned[0].old != path || filepath.Clean(planned[0].new) != want {
+		t.Errorf("planned = %+v, want %s → %s", planned, path, want)
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Provider is a backend that can see images and name them.
+type Provider interface {
+	// Describe returns a detailed description of the image at imagePath.
+	Describe(ctx context.Context, imagePath string) (string, error)
+	// SuggestName turns a description from Describe into a short file
+	// name without extension.
+	SuggestName(ctx context.Context, description string) (string, error)
+}
+
+// promptAsker is implemented by providers that also answer the text
+// prompts other file types are named with, which otherwise go to OpenAI.
+type promptAsker interface {
+	Ask(ctx context.Context, prompt string) (string, error)
+}
+
+// imageAsker is implemented by providers that also answer other questions
+// about images, such as alt text and tiles, which otherwise go to Gemini.
+type imageAsker interface {
+	AskAboutImage(ctx context.Context, data []byte, mimeType, prompt string) (string, error)
+	AskAboutFile(ctx context.Context, path, prompt string) (string, error)
+}
+
+// providers holds every backend --provider can pick, by name. Backends add
+// themselves from init.
+var providers = map[string]Provider{
+	"gemini-openai": geminiOpenAI{},
+}
+
+// providerModels points at the model variable of each provider that uses
+// a single model, which --model replaces.
+var providerModels = map[string]*string{}
+
+var (
+	providerName  = providerFlag("gemini-openai")
+	modelOverride string
+)
+
+func init() {
+	rootCmd.PersistentFlags().Var(&providerName, "provider", "backend that describes and names images")
+	rootCmd.PersistentFlags().StringVar(&modelOverride, "model", "", "model for --provider to use instead of its default, e.g. llava with --provider=ollama")
+}
+
+func registerProvider(name string, p Provider) {
+	providers[name] = p
+}
+
+// registerModel lets --model set the model the provider keeps in model.
+func registerModel(name string, model *string) {
+	providerModels[name] = model
+}
+
+// applyModelFlag points the current provider at --model.
+func applyModelFlag() error {
+	if modelOverride == "" {
+		return nil
+	}
+	model, ok := providerModels[string(providerName)]
+	if !ok {
+		return fmt.Errorf("--model does not apply to --provider=%s; set its models in the config file instead", providerName)
+	}
+	*model = modelOverride
+	return nil
+}
+
+func currentProvider() Provider {
+	return providers[string(providerName)]
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type providerFlag string
+
+func (p *providerFlag) Set(s string) error {
+	if _, ok := providers[s]; !ok {
+		return fmt.Errorf("unknown provider %q (want %s)", s, strings.Join(providerNames(), ", "))
+	}
+	*p = providerFlag(s)
+	return nil
+}
+
+func (p *providerFlag) String() string { return string(*p) }
+
+func (p *providerFlag) Type() string { return "name" }
+
+// geminiOpenAI is the original pipeline: Gemini looks at the image and
+// GPT-4 names it from Gemini's description.
+type geminiOpenAI struct{}
+
+func (geminiOpenAI) Describe(ctx context.Context, imagePath string) (string, error) {
+	return askGeminiAboutFile(imagePath, describePrompt())
+}
+
+func (geminiOpenAI) SuggestName(ctx context.Context, description string) (string, error) {
+	return askChatGPT(namingPrompt(description))
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// purgeTarget selects the stored data about some images: the file at path,
+// any file whose content has the given SHA-256, or everything.
+type purgeTarget struct {
+	path string
+	hash string
+}
+
+func (t purgeTarget) matches(path string) bool {
+	if t.path != "" {
+		return path == t.path
+	}
+	h, err := fileSHA256(path)
+	return err == nil && h == t.hash
+}
+
+// purger removes what one state file holds about the target and returns
+// how many entries went.
+type purger func(t purgeTarget) (int, error)
+
+// purgers is filled in by the files that keep per-image state.
+var purgers = map[string]purger{}
+
+var (
+	purgeHash string
+	purgePath string
+	purgeAll  bool
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge --hash <sha256> | --path <file> | --all",
+	Short: "Delete the stored descriptions and other data about images",
+	Long: `Removes what tell-me-more has stored about an image from the state directory:
+queued suggestions and their descriptions, face embeddings and anything else kept
+per image. --hash matches by content, as printed by sha256sum.
+
+--all deletes every state file and the encryption key in the OS keychain, so
+copies left behind on disk or in backups can no longer be read. The audit log is
+never purged.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set := 0
+		for _, on := range []bool{purgeHash != "", purgePath != "", purgeAll} {
+			if on {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("give exactly one of --hash, --path or --all")
+		}
+		if purgeAll {
+			return purgeEverything()
+		}
+
+		t := purgeTarget{hash: purgeHash}
+		if purgePath != "" {
+			abs, err := filepath.Abs(purgePath)
+			if err != nil {
+				return err
+			}
+			t.path = abs
+		}
+		total := 0
+		for name, purge := range purgers {
+			n, err := purge(t)
+			if err != nil {
+				return fmt.Errorf("purging %s: %v", name, err)
+			}
+			if n > 0 {
+				fmt.Printf("Removed %d entries from %s\n", n, name)
+			}
+			total += n
+		}
+		if total == 0 {
+			fmt.Println("Nothing stored for that image")
+		}
+		return nil
+	},
+}
+
+func init() {
+	purgeCmd.Flags().StringVar(&purgeHash, "hash", "", "SHA-256 of the image's content")
+	purgeCmd.Flags().StringVar(&purgePath, "path", "", "path the image was stored under")
+	purgeCmd.Flags().BoolVar(&purgeAll, "all", false, "delete all stored state and its encryption key")
+	rootCmd.AddCommand(purgeCmd)
+}
+
+// purgeEverything removes the state directory's contents apart from the
+// audit log, then the key they were encrypted with.
+func purgeEverything() error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == auditFile {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	if os.Getenv("TELL_ME_MORE_STATE_KEY") == "" {
+		if err := deleteKeychainKey(); err != nil {
+			fmt.Printf("State deleted, but the keychain entry could not be removed: %v\n", err)
+			return nil
+		}
+	}
+	fmt.Println("Deleted all stored state")
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// purgeReviewItems drops matching entries from a list of reviewItems.
+func purgeReviewItems(file string) purger {
+	return func(t purgeTarget) (int, error) {
+		var items []reviewItem
+		if err := loadState(file, &items); err != nil || len(items) == 0 {
+			return 0, err
+		}
+		kept := items[:0]
+		for _, item := range items {
+			if !t.matches(item.Path) {
+				kept = append(kept, item)
+			}
+		}
+		n := len(items) - len(kept)
+		if n == 0 {
+			return 0, nil
+		}
+		return n, saveState(file, kept)
+	}
+}
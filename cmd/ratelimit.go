@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestRates are --rps and --rpm: requests per second and per minute,
+// by API, with "" for the limit every API gets unless given its own.
+var (
+	requestsPerSecond = requestRate{}
+	requestsPerMinute = requestRate{}
+
+	requestLimitersMu sync.Mutex
+	requestLimiters   = map[string]*requestLimiter{}
+)
+
+// rateLimitedAPIs are the APIs a limit can be given for.
+var rateLimitedAPIs = []string{"gemini", "openai", "anthropic", "ollama"}
+
+func init() {
+	rootCmd.PersistentFlags().Var(&requestsPerSecond, "rps", "at most this many API requests a second, for every API (2) or per API (gemini=1,openai=5)")
+	rootCmd.PersistentFlags().Var(&requestsPerMinute, "rpm", "at most this many API requests a minute, for every API (60) or per API (gemini=15)")
+}
+
+type requestRate map[string]float64
+
+func (r *requestRate) Set(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		api, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			api, value = "", api
+		}
+		if api != "" && !knownAPI(api) {
+			return fmt.Errorf("unknown API %q (want %s)", api, strings.Join(rateLimitedAPIs, ", "))
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid rate %q (want a number above 0)", value)
+		}
+		(*r)[api] = n
+	}
+	return nil
+}
+
+func (r *requestRate) String() string {
+	var items []string
+	for api, n := range *r {
+		v := strconv.FormatFloat(n, 'g', -1, 64)
+		if api != "" {
+			v = api + "=" + v
+		}
+		items = append(items, v)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (r *requestRate) Type() string { return "rate" }
+
+func (r requestRate) forAPI(api string) float64 {
+	if n, ok := r[api]; ok {
+		return n
+	}
+	return r[""]
+}
+
+func knownAPI(api string) bool {
+	for _, a := range rateLimitedAPIs {
+		if a == api {
+			return true
+		}
+	}
+	return false
+}
+
+// requestLimiter spaces out the requests to one API, across every worker,
+// so they stay under the stricter of its --rps and --rpm.
+type requestLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func limiterFor(api string) *requestLimiter {
+	requestLimitersMu.Lock()
+	defer requestLimitersMu.Unlock()
+	l := requestLimiters[api]
+	if l == nil {
+		l = &requestLimiter{}
+		if n := requestsPerSecond.forAPI(api); n > 0 {
+			l.interval = time.Duration(float64(time.Second) / n)
+		}
+		if n := requestsPerMinute.forAPI(api); n > 0 {
+			l.interval = max(l.interval, time.Duration(float64(time.Minute)/n))
+		}
+		requestLimiters[api] = l
+	}
+	return l
+}
+
+// waitForRequest blocks until another request may be sent to api.
+func waitForRequest(api string) {
+	l := limiterFor(api)
+	if l.interval == 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(time.Until(start))
+}
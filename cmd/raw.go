@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var rawExts = map[string]bool{
+	".cr2": true,
+	".cr3": true,
+	".nef": true,
+	".nrw": true,
+	".arw": true,
+	".dng": true,
+	".raf": true,
+	".orf": true,
+	".rw2": true,
+	".pef": true,
+}
+
+func isRAWFile(path string) bool {
+	return rawExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// cameraNamePattern matches the names cameras give photos: DSC_0001,
+// _DSC1234, DSCF1234, IMG_1234, _MG_1234, P1010001.
+var cameraNamePattern = regexp.MustCompile(`(?i)^(_?dsc[fn]?|_?mg|_?img|pict|cimg|p[0-9a-c])_?\d{4}`)
+
+// isCameraRAW reports whether path is a RAW photo still under the name
+// the camera gave it.
+func isCameraRAW(path string) bool {
+	return isRAWFile(path) && cameraNamePattern.MatchString(filepath.Base(path))
+}
+
+// rawPreviewMinSide is the shortest side a preview is worth describing;
+// smaller ones are the thumbnails RAWs also carry.
+const rawPreviewMinSide = 320
+
+// rawPreview finds the largest JPEG preview embedded in a RAW file. Every
+// RAW format keeps one for the camera's screen, usually at full or near
+// full size, so the sensor data never needs developing.
+func rawPreview(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var best []byte
+	area := 0
+	for at := 0; ; {
+		i := bytes.Index(data[at:], []byte{0xff, 0xd8, 0xff})
+		if i < 0 {
+			break
+		}
+		start := at + i
+		at = start + 3
+		end := jpegEnd(data, start)
+		if end < 0 {
+			continue
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data[start:end]))
+		if err != nil || min(cfg.Width, cfg.Height) < rawPreviewMinSide {
+			continue
+		}
+		if cfg.Width*cfg.Height > area {
+			best, area = data[start:end], cfg.Width*cfg.Height
+		}
+		at = end
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no JPEG preview in %s", path)
+	}
+	return best, nil
+}
+
+// jpegEnd returns the offset just past the JPEG starting at start, or -1
+// when it isn't one. It steps over the header segments by their lengths,
+// so a thumbnail inside the EXIF doesn't end it early, then looks for the
+// end-of-image marker after the scan data.
+func jpegEnd(data []byte, start int) int {
+	at := start + 2
+	for {
+		if at+4 > len(data) || data[at] != 0xff {
+			return -1
+		}
+		marker := data[at+1]
+		if marker == 0xff {
+			at++
+			continue
+		}
+		length := int(data[at+2])<<8 | int(data[at+3])
+		at += 2 + length
+		if marker == 0xda {
+			break
+		}
+	}
+	for ; at+1 < len(data); at++ {
+		if data[at] != 0xff {
+			continue
+		}
+		switch m := data[at+1]; {
+		case m == 0xd9:
+			return at + 2
+		case m == 0x00, m >= 0xd0 && m <= 0xd7:
+			// stuffed byte or restart marker inside the scan
+		case m == 0xda, m == 0xc4, m == 0xdd, m == 0xdb:
+			// the next scan of a progressive JPEG
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// describeRAW describes a RAW photo from its embedded preview.
+func describeRAW(path string) (string, error) {
+	preview, err := rawPreview(path)
+	if err != nil {
+		return "", err
+	}
+	return describeImageBytes(preview, "image/jpeg")
+}
+
+// rawSidecars are the edit files photo tools keep next to a RAW, as
+// suffixes of its name without the extension (DSC_0001.xmp) or with it
+// (DSC_0001.NEF.xmp, darktable's and DxO's way).
+var rawSidecars = []string{".xmp", ".XMP", ".pp3", ".dop"}
+
+// rawSidecarPairs pairs each sidecar of the RAW at old with the name it
+// takes when the RAW becomes new.
+func rawSidecarPairs(old, new string) [][2]string {
+	if !isRAWFile(old) {
+		return nil
+	}
+	oldStem, newStem := strings.TrimSuffix(old, filepath.Ext(old)), strings.TrimSuffix(new, filepath.Ext(new))
+	var pairs [][2]string
+	for _, suffix := range rawSidecars {
+		for _, pair := range [][2]string{{oldStem + suffix, newStem + suffix}, {old + suffix, new + suffix}} {
+			if _, err := os.Stat(pair[0]); err == nil {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	return pairs
+}
+
+// moveRAWSidecars renames the sidecars of the RAW at old to follow it to
+// new, so edits made in Lightroom, darktable, RawTherapee or DxO stay with
+// the photo. Each is journaled, so undo takes them back too.
+func moveRAWSidecars(old, new string) {
+	rename := renameRetrying
+	if copyRenames {
+		rename = copyRename
+	}
+	for _, pair := range rawSidecarPairs(old, new) {
+		from, to := pair[0], pair[1]
+		// On a case-insensitive disk .xmp and .XMP are the same file.
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if _, err := os.Lstat(to); err == nil {
+			log.Printf("Not moving %s: %s already exists", from, to)
+			continue
+		}
+		if err := rename(from, to); err != nil {
+			log.Printf("Failed to move %s along with %s: %v", from, old, err)
+			continue
+		}
+		audit("rename", absPath(from), absPath(to), old)
+		journal(from, to, old, nil)
+		recordOriginalName(from, to)
+		fmt.Fprintf(msgOut, "Renamed %s to %s\n", from, to)
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import "testing"
+
+func TestJPEGEnd(t *testing.T) {
+	// A header segment with its length, a start of scan, scan data and the
+	// end-of-image marker.
+	jpeg := func(scan ...byte) []byte {
+		data := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 0x00, 0x00, 0xff, 0xda, 0x00, 0x02}
+		return append(append(data, scan...), 0xff, 0xd9)
+	}
+	withThumbnail := []byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x08, 0xff, 0xd8, 0xff, 0xd9, 0x00, 0x00, 0xff, 0xda, 0x00, 0x02, 0x01, 0xff, 0xd9}
+	tests := []struct {
+		name  string
+		data  []byte
+		start int
+		want  int
+	}{
+		{"plain", jpeg(0x01, 0x02), 0, 16},
+		{"stuffed byte in the scan", jpeg(0xff, 0x00, 0x01), 0, 17},
+		{"restart marker in the scan", jpeg(0x01, 0xff, 0xd3, 0x02), 0, 18},
+		{"thumbnail inside a header segment", withThumbnail, 0, len(withThumbnail)},
+		{"after other data", append([]byte{1, 2, 3}, jpeg(0x01)...), 3, 18},
+		{"trailing data is left out", append(jpeg(0x01), 0xaa, 0xbb), 0, 15},
+		{"no end marker", jpeg(0x01)[:13], 0, -1},
+		{"truncated header", []byte{0xff, 0xd8, 0xff}, 0, -1},
+		{"not a marker", []byte{0xff, 0xd8, 0x00, 0x00, 0x00, 0x00}, 0, -1},
+		{"unexpected marker in the scan", append(jpeg(0x01)[:13], 0xff, 0xe1, 0xff, 0xd9), 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegEnd(tt.data, tt.start); got != tt.want {
+				t.Errorf("jpegEnd() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	refRoot    string
+	refGlobs   []string
+	noObsidian bool
+)
+
+// vaultGlobs are the files in an Obsidian vault that can embed or link to
+// an attachment.
+var vaultGlobs = []string{"*.md", "*.canvas"}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&refRoot, "rewrite-refs", "", "project directory whose source files should have references to renamed files updated")
+	rootCmd.PersistentFlags().StringSliceVar(&refGlobs, "ref-globs",
+		[]string{"*.md", "*.mdx", "*.html", "*.htm", "*.tsx", "*.jsx", "*.ts", "*.js", "*.vue", "*.svelte", "*.css", "*.scss"},
+		"source files searched for references with --rewrite-refs")
+	rootCmd.PersistentFlags().BoolVar(&noObsidian, "no-obsidian", false, "don't update links in the Obsidian vault a renamed file belongs to")
+}
+
+// refEdit is the new content of one source file that refers to a renamed
+// file.
+type refEdit struct {
+	path     string
+	mode     fs.FileMode
+	old, new []byte
+}
+
+// refScope is where the references to a renamed file may be: the sources
+// under root matching globs, leaving out the directories in skip. A
+// reference is resolved against the directory of the source it is in, or
+// when it starts with a slash against roots, the directories a site or
+// project serves from /.
+type refScope struct {
+	root  string
+	globs []string
+	skip  []string
+	roots []string
+	// rootRelative is set where references without a leading slash may be
+	// relative to roots too, as vault paths in Obsidian and resource paths
+	// in Hugo front matter are.
+	rootRelative bool
+	// byName is set where a bare file name finds the file wherever it is,
+	// as long as no other file has that name, as in Obsidian.
+	byName bool
+}
+
+// referenceScope works out which sources may refer to path; root is empty
+// when references are not being rewritten. --rewrite-refs wins; otherwise a
+// file in an Obsidian vault has the vault's notes rewritten, and one in a
+// Hugo or Jekyll site its content files.
+func referenceScope(path string) refScope {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return refScope{}
+	}
+	if refRoot != "" {
+		root, err := filepath.Abs(refRoot)
+		if err != nil {
+			return refScope{}
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return refScope{root: root, globs: refGlobs, roots: []string{root, filepath.Join(root, "public"), filepath.Join(root, "static")}}
+		}
+		return refScope{}
+	}
+	if !noObsidian {
+		if vault := obsidianVault(abs); vault != "" {
+			return refScope{root: vault, globs: vaultGlobs, roots: []string{vault}, rootRelative: true, byName: true}
+		}
+	}
+	if !noSiteRefs {
+		return staticSiteScope(abs)
+	}
+	return refScope{}
+}
+
+// obsidianVault returns the closest directory above path that holds an
+// .obsidian settings directory.
+func obsidianVault(path string) string {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(filepath.Join(dir, ".obsidian")); err == nil && info.IsDir() {
+			return dir
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return ""
+		}
+	}
+}
+
+// planReferenceRewrites finds every source file under the reference root
+// with a reference that resolves to oldPath and works out its content
+// after the rename to newPath. References to other files of the same name
+// are left alone. Nothing is written.
+func planReferenceRewrites(oldPath, newPath string) ([]refEdit, error) {
+	scope := referenceScope(oldPath)
+	if scope.root == "" {
+		return nil, nil
+	}
+	target, err := filepath.Abs(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	oldName, newName := filepath.Base(oldPath), filepath.Base(newPath)
+	unique, err := scope.uniqueName(target)
+	if err != nil {
+		return nil, err
+	}
+	var edits []refEdit
+	err = scope.walkSources(func(path string, d fs.DirEntry) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(data, []byte(oldName)) && !bytes.Contains(data, []byte(url.PathEscape(oldName))) {
+			return nil
+		}
+		updated := replaceReferences(data, scope.resolver(path, target, unique), oldName, newName)
+		if bytes.Equal(updated, data) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		edits = append(edits, refEdit{path: path, mode: info.Mode().Perm(), old: data, new: updated})
+		return nil
+	})
+	return edits, err
+}
+
+// walkSources calls fn for each source file in the scope.
+func (s refScope) walkSources(fn func(path string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skipDir(s.root, path) || s.skips(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !matchesGlob(s.globs, d.Name()) {
+			return nil
+		}
+		return fn(path, d)
+	})
+}
+
+// uniqueName reports whether no file in the scope but target has target's
+// name, which is when a bare name in a byName scope means target.
+func (s refScope) uniqueName(target string) (bool, error) {
+	if !s.byName {
+		return false, nil
+	}
+	name := filepath.Base(target)
+	unique := true
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skipDir(s.root, path) || s.skips(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == name && path != target {
+			unique = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return unique, err
+}
+
+// resolver returns whether a reference in source, given as the directory
+// part written before the file name, points at target. unique says that
+// a bare name does, in a byName scope.
+func (s refScope) resolver(source, target string, unique bool) func(dir string) bool {
+	return func(dir string) bool {
+		if strings.Contains(dir, "://") {
+			return false
+		}
+		// {{ site.baseurl }}/images/ and the like start at the site root.
+		if i := strings.LastIndex(dir, "}}"); i >= 0 {
+			dir = "/" + strings.TrimLeft(dir[i+2:], "/")
+		}
+		if d, err := url.PathUnescape(dir); err == nil {
+			dir = d
+		}
+		if dir == "" && s.byName && unique {
+			return true
+		}
+		// Without roots, as when undo runs without --rewrite-refs, a path
+		// from the root is taken to be target's when target ends with it.
+		if strings.HasPrefix(dir, "/") && len(s.roots) == 0 {
+			return strings.HasSuffix(filepath.ToSlash(target), dir+filepath.Base(target))
+		}
+		var bases []string
+		if !strings.HasPrefix(dir, "/") {
+			bases = append(bases, filepath.Dir(source))
+		}
+		if strings.HasPrefix(dir, "/") || s.rootRelative {
+			bases = append(bases, s.roots...)
+		}
+		for _, base := range bases {
+			if filepath.Join(base, filepath.FromSlash(dir), filepath.Base(target)) == target {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (s refScope) skips(path string) bool {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range s.skip {
+		if filepath.ToSlash(rel) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesGlob(globs []string, name string) bool {
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// refBefore and refAfter are the characters that may surround a path in a
+// link, import, url(), attribute or ![[wiki embed|alias]], so that
+// "cat.png" does not also match "bobcat.png". refDir is the directory part
+// of the path, up to the file name. refAfter is checked outside the
+// expression, so the character after one reference can be the one before
+// the next.
+const (
+	refBefore = "(^|[\\s\"'`(\\[=<|])"
+	refDir    = "((?:[^\\s\"'`()\\[\\]<>|=,;]*/)?)"
+	refAfter  = " \t\r\n\f\v\"'`)]?#|>,;"
+)
+
+// replaceReferences rewrites plain and percent-encoded references to
+// oldName whose directory part resolves reports as pointing at the
+// renamed file.
+func replaceReferences(data []byte, resolves func(dir string) bool, oldName, newName string) []byte {
+	pairs := [][2]string{{oldName, newName}}
+	if escaped := url.PathEscape(oldName); escaped != oldName {
+		pairs = append(pairs, [2]string{escaped, url.PathEscape(newName)})
+	}
+	for _, p := range pairs {
+		re := regexp.MustCompile("(?m)" + refBefore + refDir + regexp.QuoteMeta(p[0]))
+		var out []byte
+		last := 0
+		for _, m := range re.FindAllSubmatchIndex(data, -1) {
+			if m[1] < len(data) && !strings.ContainsRune(refAfter, rune(data[m[1]])) {
+				continue
+			}
+			dir := string(data[m[4]:m[5]])
+			if !resolves(dir) {
+				continue
+			}
+			out = append(out, data[last:m[5]]...)
+			out = append(out, p[1]...)
+			last = m[1]
+		}
+		data = append(out, data[last:]...)
+	}
+	return data
+}
+
+// printReferenceDiff shows the planned edits as a line diff. Renaming never
+// adds or removes lines, so lines are compared one to one.
+func printReferenceDiff(edits []refEdit) {
+	for _, e := range edits {
+		fmt.Fprintf(msgOut, "--- %s\n+++ %s\n", e.path, e.path)
+		oldLines := strings.Split(string(e.old), "\n")
+		newLines := strings.Split(string(e.new), "\n")
+		for i := range oldLines {
+			if i < len(newLines) && oldLines[i] != newLines[i] {
+				fmt.Fprintf(msgOut, "@@ line %d @@\n-%s\n+%s\n", i+1, oldLines[i], newLines[i])
+			}
+		}
+	}
+}
+
+// renameWithReferences renames oldPath and rewrites the references to it
+// together: the new sources are staged next to the originals first, so a
+// failed rename leaves every file as it was, and they are swapped in only
+// once the rename has succeeded. It returns the sources that were updated.
+func renameWithReferences(oldPath, newPath string, rename func(string, string) error) ([]string, error) {
+	edits, err := planReferenceRewrites(oldPath, newPath)
+	if err != nil {
+		return nil, fmt.Errorf("finding references to %s: %v", oldPath, err)
+	}
+
+	var staged []string
+	discard := func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}
+	for _, e := range edits {
+		tmp, err := os.CreateTemp(filepath.Dir(e.path), "."+filepath.Base(e.path)+".*")
+		if err == nil {
+			staged = append(staged, tmp.Name())
+			_, err = tmp.Write(e.new)
+			if cerr := tmp.Close(); err == nil {
+				err = cerr
+			}
+			if err == nil {
+				err = os.Chmod(tmp.Name(), e.mode)
+			}
+		}
+		if err != nil {
+			discard()
+			return nil, fmt.Errorf("updating references in %s: %v", e.path, err)
+		}
+	}
+
+	if err := rename(oldPath, newPath); err != nil {
+		discard()
+		return nil, err
+	}
+	var updated []string
+	for i, e := range edits {
+		if err := os.Rename(staged[i], e.path); err != nil {
+			log.Printf("Error updating references in %s: %v", e.path, err)
+			os.Remove(staged[i])
+			continue
+		}
+		fmt.Fprintf(msgOut, "Updated references in %s\n", e.path)
+		audit("update-references", absPath(oldPath), absPath(e.path), oldPath)
+		updated = append(updated, absPath(e.path))
+	}
+	return updated, nil
+}
+
+// restoreReferences points the references in sources that name newPath
+// back at oldPath, for undo.
+func restoreReferences(sources []string, newPath, oldPath string) {
+	if len(sources) == 0 {
+		return
+	}
+	// The references were resolved where the file was renamed to.
+	scope := referenceScope(newPath)
+	target := absPath(newPath)
+	unique, err := scope.uniqueName(target)
+	if err != nil {
+		log.Printf("Error restoring references to %s: %v", newPath, err)
+		return
+	}
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			log.Printf("Error restoring references in %s: %v", src, err)
+			continue
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			log.Printf("Error restoring references in %s: %v", src, err)
+			continue
+		}
+		restored := replaceReferences(data, scope.resolver(src, target, unique), filepath.Base(newPath), filepath.Base(oldPath))
+		if bytes.Equal(restored, data) {
+			continue
+		}
+		if err := os.WriteFile(src, restored, info.Mode().Perm()); err != nil {
+			log.Printf("Error restoring references in %s: %v", src, err)
+			continue
+		}
+		fmt.Fprintf(msgOut, "Restored references in %s\n", src)
+		audit("update-references", newPath, src, "")
+	}
+}
+
+// showReferenceDiff previews the reference updates that accepting name for
+// path would make.
+func showReferenceDiff(path, name string) {
+	if referenceScope(path).root == "" {
+		return
+	}
+	newPath, err := targetPath(path, name)
+	if err != nil {
+		return
+	}
+	edits, err := planReferenceRewrites(path, newPath)
+	if err != nil {
+		log.Printf("Error finding references to %s: %v", path, err)
+		return
+	}
+	printReferenceDiff(edits)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceReferences(t *testing.T) {
+	site := filepath.FromSlash("/site")
+	source := filepath.Join(site, "notes", "post.md")
+	target := filepath.Join(site, "notes", "img", "shot.png")
+	spaced := filepath.Join(site, "notes", "img", "my shot.png")
+	project := refScope{root: site, roots: []string{site}}
+	vault := refScope{root: site, roots: []string{site}, rootRelative: true, byName: true}
+
+	tests := []struct {
+		name    string
+		scope   refScope
+		target  string
+		unique  bool
+		in      string
+		oldName string
+		newName string
+		want    string
+	}{
+		{
+			name:  "relative to the source",
+			scope: project, target: target,
+			in:      "![](img/shot.png)",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![](img/cat.png)",
+		},
+		{
+			name:  "same name in another folder",
+			scope: project, target: target,
+			in:      "![](img/shot.png) ![](other/shot.png) ![](../img/shot.png)",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![](img/cat.png) ![](other/shot.png) ![](../img/shot.png)",
+		},
+		{
+			name:  "up and back down",
+			scope: project, target: target,
+			in:      `<img src="../notes/img/shot.png">`,
+			oldName: "shot.png", newName: "cat.png",
+			want: `<img src="../notes/img/cat.png">`,
+		},
+		{
+			name:  "from the root",
+			scope: project, target: target,
+			in:      "![](/notes/img/shot.png) ![](/img/shot.png)",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![](/notes/img/cat.png) ![](/img/shot.png)",
+		},
+		{
+			name:  "template before a root path",
+			scope: project, target: target,
+			in:      `src="{{ site.baseurl }}/notes/img/shot.png"`,
+			oldName: "shot.png", newName: "cat.png",
+			want: `src="{{ site.baseurl }}/notes/img/cat.png"`,
+		},
+		{
+			name:  "urls are left alone",
+			scope: project, target: target,
+			in:      "![](https://example.com/notes/img/shot.png)",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![](https://example.com/notes/img/shot.png)",
+		},
+		{
+			name:  "import, url() and attribute",
+			scope: project, target: target,
+			in:      "import shot from './img/shot.png'\nbackground: url(img/shot.png);\n<img src=\"/notes/img/shot.png\">",
+			oldName: "shot.png", newName: "cat.png",
+			want: "import shot from './img/cat.png'\nbackground: url(img/cat.png);\n<img src=\"/notes/img/cat.png\">",
+		},
+		{
+			name:  "only whole names",
+			scope: project, target: target,
+			in:      "![](img/bigshot.png) ![](img/shot.png.bak) ![](img/shot.png?v=2)",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![](img/bigshot.png) ![](img/shot.png.bak) ![](img/cat.png?v=2)",
+		},
+		{
+			name:  "one after another",
+			scope: project, target: target,
+			in:      "srcset: img/shot.png img/shot.png",
+			oldName: "shot.png", newName: "cat.png",
+			want: "srcset: img/cat.png img/cat.png",
+		},
+		{
+			name:  "percent-encoded",
+			scope: project, target: spaced,
+			in:      "![](img/my%20shot.png) [[img/my shot.png]]",
+			oldName: "my shot.png", newName: "my cat.png",
+			want: "![](img/my%20cat.png) [[img/my cat.png]]",
+		},
+		{
+			name:  "dollar signs in the new name",
+			scope: project, target: target,
+			in:      "![](img/shot.png)",
+			oldName: "shot.png", newName: "price_$5.png",
+			want: "![](img/price_$5.png)",
+		},
+		{
+			name:  "bare name in a vault when it is unique",
+			scope: vault, target: target, unique: true,
+			in:      "![[shot.png]] and ![[shot.png|alias]]",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![[cat.png]] and ![[cat.png|alias]]",
+		},
+		{
+			name:  "bare name in a vault when it is not",
+			scope: vault, target: target,
+			in:      "![[shot.png]] and ![[notes/img/shot.png]]",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![[shot.png]] and ![[notes/img/cat.png]]",
+		},
+		{
+			name:  "bare name outside a vault is relative",
+			scope: project, target: filepath.Join(site, "notes", "shot.png"),
+			in:      "![](shot.png)",
+			oldName: "shot.png", newName: "cat.png",
+			want: "![](cat.png)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolves := tt.scope.resolver(source, tt.target, tt.unique)
+			if got := string(replaceReferences([]byte(tt.in), resolves, tt.oldName, tt.newName)); got != tt.want {
+				t.Errorf("replaceReferences() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
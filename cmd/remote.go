@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var outDir string
+
+func init() {
+	rootCmd.Flags().StringVar(&outDir, "out-dir", ".", "directory to save downloaded remote images into")
+}
+
+var httpClient = &http.Client{Timeout: 2 * time.Minute}
+
+// jsonRequest sends body as JSON with the given extra headers and decodes
+// the response into out, unless out is nil. Any non-2xx status is an error.
+func jsonRequest(method, url string, header http.Header, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func isRemoteURL(arg string) bool {
+	u, err := url.Parse(arg)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// remoteMaxSize is the largest download processRemote saves; anything
+// bigger is not the image the URL looked like.
+const remoteMaxSize = 100 << 20
+
+// processRemote downloads an image, names it from its content and saves it
+// under outDir. Downloads are saved rather than renamed, so like email
+// attachments they are not journaled: there is no earlier name to undo to.
+func processRemote(rawURL string) {
+	fmt.Fprintf(msgOut, "Downloading %s\n", rawURL)
+	rec := fileRecord{Path: rawURL, Action: actionSkipped}
+	defer func() { emitRecord(rec) }()
+	fail := func(err error) {
+		log.Printf("Error saving %s: %v", rawURL, err)
+		rec.Action, rec.Error = actionError, err.Error()
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		fail(err)
+		return
+	}
+	scratch, err := os.MkdirTemp(outDir, ".tell-me-more-")
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer os.RemoveAll(scratch)
+	tmp, err := downloadImage(rawURL, scratch)
+	if err != nil {
+		log.Printf("Error downloading %s: %v", rawURL, err)
+		rec.Action, rec.Error = actionError, err.Error()
+		return
+	}
+
+	description, err := describeFile(tmp)
+	if err != nil {
+		log.Printf("Error getting labels from image: %v", err)
+		description = strings.TrimSuffix(filepath.Base(tmp), filepath.Ext(tmp))
+	} else {
+		rec.Description = description
+	}
+	name, err := suggestName(tmp, description)
+	if err == nil {
+		name, err = finalName(tmp, name)
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	rec.Name = sanitizeFileName(name)
+	target, err := targetPathIn(outDir, tmp, name)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if dryRun {
+		rec.Action, rec.NewPath = actionPlanned, target
+		planRename(rawURL, target)
+		return
+	}
+	if nameTaken(tmp, target) {
+		if target, _, err = resolveConflict(target); errors.Is(err, errNameTaken) {
+			fmt.Fprintf(msgOut, "Not saving %s: %v\n", rawURL, err)
+			return
+		} else if err != nil {
+			fail(err)
+			return
+		}
+	}
+	if err := renameRetrying(tmp, target); err != nil {
+		fail(err)
+		return
+	}
+	rec.Action, rec.NewPath = actionRenamed, target
+	fmt.Fprintf(msgOut, "Saved %s as %s\n", rawURL, target)
+	audit("download", rawURL, absPath(target), tmp)
+}
+
+// downloadImage fetches rawURL into dir under the name in the URL, which
+// templates can use as the original name. dir is inside the directory the
+// file ends up in, so the final rename never has to cross filesystems.
+func downloadImage(rawURL, dir string) (string, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > remoteMaxSize {
+		return "", fmt.Errorf("%s is over the %s download limit", formatBytes(resp.ContentLength), formatBytes(remoteMaxSize))
+	}
+
+	ext, err := remoteImageExt(rawURL, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+	stem := "download"
+	if u, err := url.Parse(rawURL); err == nil {
+		if s := sanitizeFileName(strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))); s != "" {
+			stem = s
+		}
+	}
+
+	name := filepath.Join(dir, stem+ext)
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, remoteMaxSize+1))
+	if err == nil && n > remoteMaxSize {
+		err = fmt.Errorf("over the %s download limit", formatBytes(remoteMaxSize))
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// remoteImageExt picks the file extension for a download, preferring an
+// image extension in the URL and otherwise going by the Content-Type, so
+// that thumb.php serving a PNG is saved as .png.
+func remoteImageExt(rawURL, contentType string) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "" && !strings.HasPrefix(mediaType, "image/") {
+		return "", fmt.Errorf("not an image (Content-Type %s)", mediaType)
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		if ext := strings.ToLower(path.Ext(u.Path)); imageExts[ext] {
+			return ext, nil
+		}
+	}
+	if mediaType == "image/jpeg" {
+		return ".jpg", nil // ExtensionsByType would offer .jfif first
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0], nil
+	}
+	return "", fmt.Errorf("cannot determine image type")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import "testing"
+
+func TestRemoteImageExt(t *testing.T) {
+	tests := []struct {
+		url         string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{"https://example.com/cat.png", "image/png", ".png", false},
+		{"https://example.com/cat.JPEG?w=800", "", ".jpeg", false},
+		{"https://example.com/thumb.php?id=4", "image/png", ".png", false},
+		{"https://example.com/photo", "image/jpeg; charset=binary", ".jpg", false},
+		{"https://example.com/cat.png", "image/webp", ".png", false},
+		{"https://example.com/page.html", "text/html; charset=utf-8", "", true},
+		{"https://example.com/thumb.php", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := remoteImageExt(tt.url, tt.contentType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("remoteImageExt(%q, %q) error = %v, want error %v", tt.url, tt.contentType, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("remoteImageExt(%q, %q) = %q, want %q", tt.url, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"syscall"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	// retryAttempts is how many more times a call that failed with a
+	// transient error is tried.
+	retryAttempts int
+	// retryBackoff is the wait before the first retry; it doubles after
+	// each one.
+	retryBackoff time.Duration
+	// retryJitter spreads each wait by up to this fraction either way, so
+	// concurrent workers don't retry in lockstep.
+	retryJitter float64
+)
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&retryAttempts, "retries", 3, "retry API calls that fail with a rate limit, server or network error this many times")
+	rootCmd.PersistentFlags().DurationVar(&retryBackoff, "retry-backoff", time.Second, "wait before the first retry, doubling after each one")
+	rootCmd.PersistentFlags().Float64Var(&retryJitter, "retry-jitter", 0.5, "randomize each retry wait by up to this fraction of it (0 to 1)")
+}
+
+// retrying runs fn until it succeeds, fails with an error that is not
+// worth retrying, or runs out of --retries. While other keys are there
+// to rotate to, a rate limit is returned straight away instead. Every
+// attempt waits its turn under --rps and --rpm.
+func retrying[T any](provider string, rotate bool, fn func() (T, error)) (T, error) {
+	delay := retryBackoff
+	for attempt := 1; ; attempt++ {
+		waitForRequest(provider)
+		result, err := fn()
+		if err == nil || attempt > retryAttempts || !isTransient(err) || (rotate && isRateLimited(err)) {
+			return result, err
+		}
+		wait := jittered(delay)
+		log.Printf("%s: %v; retrying in %v (%d of %d)", provider, err, wait.Round(time.Millisecond), attempt, retryAttempts)
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
+
+func jittered(d time.Duration) time.Duration {
+	j := min(max(retryJitter, 0), 1)
+	return time.Duration(float64(d) * (1 + j*(2*rand.Float64()-1)))
+}
+
+// isTransient reports whether err is a rate limit, a 5xx from the API or
+// a dropped or timed-out connection: something that may well work the
+// next time.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if isRateLimited(err) {
+		return true
+	}
+	var oerr *openai.APIError
+	if errors.As(err, &oerr) && oerr.HTTPStatusCode >= http.StatusInternalServerError {
+		return true
+	}
+	var rerr *openai.RequestError
+	if errors.As(err, &rerr) && rerr.HTTPStatusCode >= http.StatusInternalServerError {
+		return true
+	}
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code >= http.StatusInternalServerError {
+		return true
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Most callers wrap with %v, so fall back to the message.
+	return transientPattern.MatchString(err.Error())
+}
+
+var transientPattern = regexp.MustCompile(`Error 5\d\d\b|status code: 5\d\d\b|API error: 5\d\d\b|connection reset by peer|unexpected EOF|i/o timeout|TLS handshake timeout|Client\.Timeout exceeded`)
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// originalNameAttr is the extended attribute a renamed file keeps its
+// original name in. It travels with the file, so revert works where the
+// rename history doesn't: on another machine, or after a sync.
+const originalNameAttr = "user.tellmemore.original"
+
+// recordOriginalName stores the name the file now at new had before it
+// was renamed from old. A file renamed before keeps its first name, the
+// one revert should go back to. Filesystems without extended attributes
+// just leave revert to the history.
+func recordOriginalName(old, new string) {
+	if name, err := getXattr(new, originalNameAttr); err != nil || name != nil {
+		return
+	}
+	setXattr(new, originalNameAttr, []byte(filepath.Base(old)))
+}
+
+// forgetOriginalName drops the original name of a file that is back
+// under it.
+func forgetOriginalName(path string) {
+	if name, err := getXattr(path, originalNameAttr); err == nil && string(name) == filepath.Base(path) {
+		removeXattr(path, originalNameAttr)
+	}
+}
+
+var revertCmd = &cobra.Command{
+	Use:   "revert <renamed file>...",
+	Short: "Rename files back to the original name stored with them",
+	Long: `revert renames each file back to the name it had before tell-me-more first
+renamed it, which is stored in the file's user.tellmemore.original extended
+attribute. Unlike undo it needs no rename history, so it works on another
+machine or after syncing, as long as the copy kept its extended attributes.
+The file stays in the folder it is in now.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		type pending struct{ path, original string }
+		var picked []pending
+		for _, path := range args {
+			name, err := getXattr(path, originalNameAttr)
+			if err != nil {
+				log.Printf("Cannot revert %s: %v", path, err)
+				continue
+			}
+			if name == nil {
+				log.Printf("Cannot revert %s: no original name is stored with it", path)
+				continue
+			}
+			original := filepath.Join(filepath.Dir(path), filepath.Base(string(name)))
+			if original == filepath.Clean(path) {
+				fmt.Printf("%s already has its original name\n", path)
+				continue
+			}
+			picked = append(picked, pending{path, original})
+		}
+		if len(picked) == 0 {
+			return nil
+		}
+		for _, p := range picked {
+			fmt.Printf("  %s -> %s\n", p.path, p.original)
+		}
+		if !confirm("Do you want to revert these renames?") {
+			return nil
+		}
+		for _, p := range picked {
+			if err := revertToOriginal(p.path, p.original); err != nil {
+				log.Printf("Cannot revert %s: %v", p.path, err)
+				continue
+			}
+			fmt.Printf("Renamed %s back to %s\n", p.path, p.original)
+			// The sidecars of a RAW carry their own original names.
+			for _, pair := range rawSidecarPairs(p.path, p.original) {
+				if name, err := getXattr(pair[0], originalNameAttr); err == nil && name != nil {
+					original := filepath.Join(filepath.Dir(pair[0]), filepath.Base(string(name)))
+					if err := revertToOriginal(pair[0], original); err != nil {
+						log.Printf("Cannot revert %s: %v", pair[0], err)
+						continue
+					}
+					fmt.Printf("Renamed %s back to %s\n", pair[0], original)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(revertCmd)
+}
+
+// revertToOriginal renames path to original, refusing to overwrite
+// anything that has taken the name since, and rewrites references to it
+// as a rename would. A matching entry in this machine's history is marked
+// undone, so undo doesn't try it again.
+func revertToOriginal(path, original string) error {
+	if _, err := os.Lstat(original); err == nil {
+		return fmt.Errorf("%s exists again", original)
+	}
+	renameMu.Lock()
+	defer renameMu.Unlock()
+	if _, err := renameWithReferences(path, original, renameRetrying); err != nil {
+		return err
+	}
+	audit("revert", absPath(path), absPath(original), "")
+	removeXattr(original, originalNameAttr)
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	entries, err := loadHistory()
+	if err != nil {
+		log.Printf("Error reading rename history: %v", err)
+		return nil
+	}
+	for _, e := range entries {
+		if !e.Undone && e.New == absPath(path) {
+			if err := appendHistory(undone(e)); err != nil {
+				log.Printf("Error saving rename history: %v", err)
+			}
+		}
+	}
+	return nil
+}
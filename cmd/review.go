@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const reviewQueueFile = "review.json"
+
+// reviewItem is a deferred decision, kept with its suggestion so coming
+// back to it later costs no API calls.
+type reviewItem struct {
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name"`
+	// Alternatives are the other names offered with --candidates.
+	Alternatives []string  `json:"alternatives,omitempty"`
+	DeferredAt   time.Time `json:"deferred_at"`
+	namingTrace
+}
+
+var (
+	reviewList       bool
+	reviewQuarantine bool
+
+	assumeYes bool
+	noInput   bool
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Go through the files deferred with 'd'",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := reviewQueueFile
+		if reviewQuarantine {
+			file = quarantineFile
+		}
+		var queue []reviewItem
+		if err := loadState(file, &queue); err != nil {
+			return err
+		}
+		if len(queue) == 0 {
+			fmt.Println("Nothing to review")
+			return nil
+		}
+		if reviewList {
+			for _, item := range queue {
+				fmt.Printf("%s -> %s\n", item.Path, item.Name)
+			}
+			return nil
+		}
+
+		var remaining []reviewItem
+		for _, item := range queue {
+			if _, err := os.Stat(item.Path); err != nil {
+				log.Printf("Dropping %s from the list: %v", item.Path, err)
+				continue
+			}
+			fmt.Printf("File: %s\n", item.Path)
+			fmt.Printf("Suggested description: %s\n", item.Name)
+			s := suggestion{path: item.Path, name: item.Name, alternatives: item.Alternatives}
+			// --dry-run shows where every queued file would go.
+			decision := "y"
+			if !dryRun {
+				decision = askDecision(&s)
+			}
+			item.Name, item.Alternatives = s.name, s.alternatives
+			switch decision {
+			case "y":
+				namingTraces.Store(item.Path, item.namingTrace)
+				newPath, err := renameFile(item.Path, item.Name)
+				if errors.Is(err, errDryRun) {
+					continue
+				}
+				if err != nil {
+					log.Printf("Failed to rename file: %v", err)
+					remaining = append(remaining, item)
+					continue
+				}
+				fmt.Printf("Renamed %s to %s\n", item.Path, newPath)
+			case "d":
+				remaining = append(remaining, item)
+			}
+		}
+		if dryRun {
+			printPlan()
+			return nil
+		}
+		return saveState(file, remaining)
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "accept every suggestion without asking")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "never ask; leave anything that needs a decision as it is")
+	rootCmd.MarkFlagsMutuallyExclusive("yes", "no-input")
+	reviewCmd.Flags().BoolVar(&reviewList, "list", false, "only list the queued files")
+	reviewCmd.Flags().BoolVar(&reviewQuarantine, "quarantine", false, "go through the files --triage quarantined instead")
+	rootCmd.AddCommand(reviewCmd)
+	purgers[reviewQueueFile] = purgeReviewItems(reviewQueueFile)
+}
+
+// askDecision prompts for y(es), n(o), e(dit) or d(efer) and returns the
+// lowercased answer; anything unrecognised counts as no. Editing changes
+// s.name and counts as yes. With --candidates the names are listed and
+// answering with a number picks that name and says yes.
+func askDecision(s *suggestion) string {
+	choices := ""
+	if len(s.alternatives) > 0 {
+		for i, name := range s.names() {
+			fmt.Fprintf(msgOut, "  %d) %s\n", i+1, name)
+		}
+		choices = fmt.Sprintf(", or 1-%d to pick a name", len(s.alternatives)+1)
+	}
+	fmt.Fprintf(msgOut, "Do you want to rename the file? (y/n/e to edit/d to decide later%s): ", choices)
+	if answer, ok := unattendedAnswer(); ok {
+		return answer
+	}
+	var input string
+	fmt.Scanln(&input)
+	if n, err := strconv.Atoi(input); err == nil && n >= 1 && n <= len(s.alternatives)+1 {
+		s.pick(n - 1)
+		return "y"
+	}
+	switch input = strings.ToLower(input); input {
+	case "y", "d":
+		return input
+	case "e":
+		name, ok := editName(s.name)
+		if !ok {
+			return askDecision(s)
+		}
+		s.name = name
+		return "y"
+	}
+	return "n"
+}
+
+// deferForReview queues s for `tell-me-more review`, replacing any older
+// entry for the same file.
+func deferForReview(s suggestion) error {
+	path, err := filepath.Abs(s.path)
+	if err != nil {
+		return err
+	}
+	var queue []reviewItem
+	if err := loadState(reviewQueueFile, &queue); err != nil {
+		return err
+	}
+	kept := queue[:0]
+	for _, item := range queue {
+		if item.Path != path {
+			kept = append(kept, item)
+		}
+	}
+	kept = append(kept, reviewItem{
+		Path:         path,
+		Description:  s.description,
+		Name:         s.name,
+		Alternatives: s.alternatives,
+		DeferredAt:   time.Now(),
+		namingTrace:  namingTraceFor(s.path),
+	})
+	return saveState(reviewQueueFile, kept)
+}
+
+// confirm asks a y/n question, answering it without reading stdin under
+// --yes or --no-input, and with no under --dry-run.
+func confirm(question string) bool {
+	fmt.Fprintf(msgOut, "%s (y/n): ", question)
+	if dryRun {
+		fmt.Fprintln(msgOut, "n (--dry-run)")
+		return false
+	}
+	if answer, ok := unattendedAnswer(); ok {
+		return answer == "y"
+	}
+	var input string
+	fmt.Scanln(&input)
+	return strings.ToLower(input) == "y"
+}
+
+// unattendedAnswer is the answer --yes or --no-input give to a prompt that
+// has just been printed, echoed so logs show what was decided.
+func unattendedAnswer() (string, bool) {
+	switch {
+	case assumeYes:
+		fmt.Fprintln(msgOut, "y")
+		return "y", true
+	case noInput:
+		fmt.Fprintln(msgOut, "n (--no-input)")
+		return "n", true
+	}
+	return "", false
+}
@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -13,7 +15,6 @@ import (
 	"github.com/google/generative-ai-go/genai"
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
-	"google.golang.org/api/option"
 )
 
 // func main() {
@@ -22,181 +23,917 @@ import (
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-    Use:   "tell-me-more",
-    Short: "A CLI tool to rename image files based on their content",
-    Run: func(cmd *cobra.Command, args []string) {
-        if len(args) < 1 {
-            fmt.Println("Please provide a directory to search")
-            return
-        }
-        searchDirectory(args[0])
-    },
+	Use:   "tell-me-more",
+	Short: "A CLI tool to rename image files based on their content",
+	// Without this cobra treats the directory argument as an unknown
+	// subcommand now that the root command has children.
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listed, err := readFileList()
+		if err != nil {
+			return err
+		}
+		args = append(args, listed...)
+		if len(args) < 1 && !resume {
+			fmt.Println("Please provide a directory to search, or files to rename")
+			return nil
+		}
+		if args, err = prepareRun(cmd, args); err != nil {
+			return err
+		}
+		if batchMode {
+			if err := runBatch(args); err != nil {
+				return err
+			}
+		}
+		if useTUI(args) {
+			if err := runTUI(args); err != nil {
+				return err
+			}
+			reportDuplicates()
+			printCostSummary()
+			return runResult(cmd)
+		}
+		var local []string
+		for _, arg := range args {
+			if isRemoteURL(arg) {
+				processRemote(arg)
+			} else {
+				local = append(local, arg)
+			}
+		}
+		forEachTarget(local, func(s suggestion) {
+			if runCtx.Err() != nil {
+				return // interrupted; the checkpoint is being saved
+			}
+			if rec := review(s); rec.Action != actionError {
+				markDone(s.path)
+			}
+		})
+		printTriageSummary()
+		reportDuplicates()
+		printPlan()
+		printCostSummary()
+		finishRun()
+		return runResult(cmd)
+	},
 }
 
 func Execute() {
-    if err := rootCmd.Execute(); err != nil {
-        fmt.Println(err)
-        os.Exit(1)
-    }
-}
-
-func searchDirectory(dir string) {
-    err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-
-        if !info.IsDir() && isTargetFile(info.Name()) {
-            fmt.Printf("Found target file: %s\n", path)
-            // labels, err := getLabelsFromImage(path)
-						labels, err := getImageSentiment(path)
-            if err != nil {
-                log.Printf("Error getting labels from image: %v", err)
-                labels = strings.Split(info.Name(), ".")[0]
-            }
-
-            description, err := getDescriptionFromChatGPT(labels)
-            if err != nil {
-                log.Printf("Error getting description from ChatGPT: %v", err)
-                return nil
-            }
-
-            fmt.Printf("Suggested description: %s\n", description)
-            fmt.Print("Do you want to rename the file? (y/n): ")
-            var input string
-            fmt.Scanln(&input)
-            if strings.ToLower(input) == "y" {
-                renameFile(path, description)
-            }
-        }
-        return nil
-    })
-
-    if err != nil {
-        log.Fatalf("Error walking the path %q: %v\n", dir, err)
-    }
+	shareRootFlags()
+	if runningAsService() {
+		os.Exit(runService())
+	}
+	if err := rootCmd.Execute(); err != nil {
+		if !errors.Is(err, errFilesFailed) {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+}
+
+// prepareRun does what every command that describes and names files does
+// first: it sets up the output and the checkpoint, applies the naming
+// options and checks those that need a tool. It returns the arguments to
+// work on, which --resume fills in.
+func prepareRun(cmd *cobra.Command, args []string) ([]string, error) {
+	if err := setupOutput(); err != nil {
+		return nil, err
+	}
+	args, err := startRun(cmd.CommandPath(), args)
+	if err != nil {
+		return nil, err
+	}
+	return args, prepareNaming(cmd)
+}
+
+// prepareNaming applies the naming options and checks those that need a
+// tool, for prepareRun and for shortcuts, which names files without a run.
+func prepareNaming(cmd *cobra.Command) error {
+	if seriesMode && !cmd.Flags().Changed("template") {
+		nameTemplateText = seriesTemplate
+	}
+	if err := applyPreset(cmd); err != nil {
+		return err
+	}
+	if err := loadTemplate(); err != nil {
+		return err
+	}
+	for _, validate := range []func() error{validateMode, validateTriage, validateMetadata, validateFinderTags, validateOCR, validateWhisper, validateStateKey} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// geminiModel and openaiModel can be changed in the config file.
+var (
+	geminiModel = "gemini-1.5-flash"
+	openaiModel = openai.GPT4
+)
+
+// prefetchDepth is how many files past the one being reviewed are
+// described and named in the background.
+const prefetchDepth = 3
+
+// concurrency overrides how many files are described at once; 0 picks
+// prefetchDepth+1, or one at a time on network mounts.
+var concurrency int
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 0, "describe and name this many files in parallel (default 4, 1 on network mounts); answers are still asked for in order")
+}
+
+// suggestion is the outcome of describing and naming one file.
+type suggestion struct {
+	path string
+	// description is empty when Gemini failed and the name was guessed
+	// from the file name.
+	description string
+	name        string
+	// confidence is the model's 0-1 score for name; rated says whether it
+	// has one, given with the name or asked for by --triage.
+	confidence float64
+	rated      bool
+	// tags and category come with the name from the naming request.
+	tags     []string
+	category string
+	// alternatives are the other names offered with --candidates.
+	alternatives []string
+	// junk says why the file looks like an accidental capture, in which
+	// case it hasn't been described yet.
+	junk string
+	err  error
+}
+
+// walkSuggestions finds the target files under dir and hands handle their
+// suggestions one at a time, in walk order.
+func walkSuggestions(dir string, handle func(suggestion)) {
+	depth := tuneForFilesystem(dir)
+	if concurrency > 0 {
+		depth = concurrency - 1
+	}
+
+	var conflicts syncConflicts
+	paths := make(chan string)
+	go func() {
+		defer close(paths)
+		var busy []string
+		batches := make(chan []string, scanWorkers)
+		scanErr := make(chan error, 1)
+		go func() {
+			defer close(batches)
+			scanErr <- scanTree(dir, scanWorkers, func(path string) bool {
+				return isTargetPath(path) || (reportConflicts && isSyncConflict(filepath.Base(path)))
+			}, batches)
+		}()
+		for batch := range batches {
+			var found []string
+			copies := conflicts.inDir(filepath.Dir(batch[0]))
+			for _, path := range batch {
+				name := filepath.Base(path)
+				if isSyncConflict(name) {
+					continue
+				}
+				// Renaming an original would separate it from its
+				// conflict copies and make the conflict harder to resolve.
+				if c := copies[name]; len(c) > 0 {
+					log.Printf("Skipping %s: it has sync-conflict copies (%s)", path, strings.Join(c, ", "))
+					continue
+				}
+				if seriesMode {
+					if !fileBusy(path) || waitUntilReady(path) {
+						found = append(found, path)
+					} else {
+						log.Printf("Skipping %s: still being written or locked", path)
+					}
+				} else if fileBusy(path) {
+					busy = append(busy, path)
+				} else {
+					paths <- path
+				}
+			}
+			// A batch is a whole directory, which is all a series
+			// needs to be numbered.
+			for _, path := range orderSeries(found) {
+				paths <- path
+			}
+		}
+		if err := <-scanErr; err != nil {
+			log.Printf("Error walking the path %q: %v", dir, err)
+			emitRecord(fileRecord{Path: dir, Action: actionError, Error: err.Error()})
+		}
+
+		// Files that were still being written get another chance once
+		// everything else has been queued. Only files modified in the
+		// last few seconds end up here, so the list stays short.
+		for _, path := range busy {
+			if waitUntilReady(path) {
+				paths <- path
+			} else {
+				log.Printf("Skipping %s: still being written or locked", path)
+			}
+		}
+	}()
+
+	for s := range prefetch(paths, depth) {
+		handle(s)
+	}
+	if reportConflicts {
+		conflicts.report()
+	}
+}
+
+// prefetch starts suggesting for up to depth files ahead of the consumer
+// and delivers the results in walk order.
+func prefetch(paths <-chan string, depth int) <-chan suggestion {
+	pending := make(chan chan suggestion, depth)
+	go func() {
+		defer close(pending)
+		for path := range paths {
+			if alreadyDone(path) {
+				continue
+			}
+			ch := make(chan suggestion, 1)
+			pending <- ch
+			go func(path string) { ch <- suggest(path) }(path)
+		}
+	}()
+
+	out := make(chan suggestion)
+	go func() {
+		defer close(out)
+		for ch := range pending {
+			out <- <-ch
+		}
+	}()
+	return out
+}
+
+func suggest(path string) suggestion {
+	noteForDups(path)
+	if reason := junkReason(path); reason != "" {
+		return suggestion{path: path, junk: reason}
+	}
+	g, generated := aiGenerationOf(path)
+	if generated && g.Prompt != "" && !noAIPrompt {
+		if s, ok := suggestFromPrompt(path, g); ok {
+			return finishSuggestion(s)
+		}
+	}
+	s := describeAndSuggest(path)
+	if generated && s.err == nil {
+		s.markGenerated()
+	}
+	return s
+}
+
+// describeAndSuggest describes and names the file at path.
+func describeAndSuggest(path string) suggestion {
+	s := suggestion{path: path}
+	if singleCall(path) {
+		r, err := describeAndName(path)
+		if s.err = err; err == nil {
+			s.description = r.Description
+			s.useNaming(r)
+			traceNaming(path, s.name)
+			chargeFile(path, s.name)
+		} else {
+			s.useTextName(err)
+		}
+		return finishSuggestion(s)
+	}
+	// labels, err := getLabelsFromImage(path)
+	labels, err := describeFile(path)
+	if err != nil {
+		log.Printf("Error getting labels from %s: %v", path, err)
+		labels = strings.Split(filepath.Base(path), ".")[0]
+	} else {
+		s.description = labels
+		chargeFile(path, labels)
+	}
+
+	r, err := suggestNaming(path, labels+ocrHint(path))
+	if s.err = err; err == nil {
+		s.useNaming(r)
+		chargeFile(path, s.name)
+	} else {
+		s.useTextName(err)
+	}
+	return finishSuggestion(s)
+}
+
+// useTextName names s after the text in its image when naming it failed
+// with err, as --ocr allows.
+func (s *suggestion) useTextName(err error) {
+	if errors.Is(err, errQueuedForBatch) || runCtx.Err() != nil {
+		return
+	}
+	if name := nameFromText(s.path); name != "" {
+		log.Printf("Naming %s from its text instead: %v", s.path, err)
+		s.name, s.err = name, nil
+	}
+}
+
+// useNaming takes the name and what came with it from r.
+func (s *suggestion) useNaming(r namingResult) {
+	s.name, s.tags, s.category = r.Name, r.Tags, r.Category
+	if r.Confidence != nil {
+		s.confidence, s.rated = *r.Confidence, true
+	}
+}
+
+// finishSuggestion rates the suggested name under --triage, applies the
+// naming template and asks for --candidates.
+func finishSuggestion(s suggestion) suggestion {
+	if s.err == nil && triageMode && !s.rated {
+		s.confidence, s.err = rateName(s.description, s.name)
+		s.rated = s.err == nil
+	}
+	if s.err == nil {
+		raw := s.name
+		if s.name, s.err = finalName(s.path, s.name); s.err == nil {
+			addAlternatives(&s, raw)
+		}
+	}
+	return s
+}
+
+// review shows a suggestion and renames the file if the user accepts it,
+// returning what was done.
+func review(s suggestion) (rec fileRecord) {
+	fmt.Fprintf(msgOut, "Found target file: %s\n", s.path)
+	rec = fileRecord{Path: s.path, Description: s.description, Action: actionSkipped}
+	defer func() {
+		emitRecord(rec)
+		if triageMode {
+			countTriage(rec.Action)
+		}
+	}()
+
+	if s.junk != "" && handleJunk(&s, &rec) {
+		return
+	}
+	if s.err != nil {
+		log.Printf("Error getting description from ChatGPT: %v", s.err)
+		rec.Action, rec.Error = actionError, s.err.Error()
+		return
+	}
+	rec.Name = sanitizeFileName(s.name)
+	rec.Alternatives = s.alternatives
+	rec.Tags, rec.Category = s.tags, s.category
+	if s.rated {
+		rec.Confidence = &s.confidence
+	}
+
+	fmt.Fprintf(msgOut, "Suggested description: %s\n", s.name)
+	if organizing {
+		fmt.Fprintf(msgOut, "Moving to: %s\n", s.dir())
+	}
+	if showCost {
+		fmt.Fprintf(msgOut, "Cost: %v\n", usageForFile(s.path))
+	}
+	showReferenceDiff(s.path, s.name)
+	if dryRun {
+		newPath, err := targetPathIn(s.dir(), s.path, s.name)
+		if err != nil {
+			log.Printf("Error planning %s: %v", s.path, err)
+			rec.Action, rec.Error = actionError, err.Error()
+			return
+		}
+		rec.Action, rec.NewPath = actionPlanned, newPath
+		planRename(s.path, newPath)
+		return
+	}
+	decision := ""
+	if triageMode {
+		fmt.Fprintf(msgOut, "Confidence: %.0f%%\n", s.confidence*100)
+		decision = triageDecision(s)
+	} else {
+		decision = askDecision(&s)
+	}
+	return applyDecision(s, decision, rec)
+}
+
+// afterRename does what follows renaming s to newPath: the redirect map,
+// the duplicate report, and the metadata, Finder tags and sidecar asked for.
+func afterRename(s suggestion, newPath string) {
+	if err := recordRedirect(s.path, newPath); err != nil {
+		log.Printf("Error writing redirect map: %v", err)
+	}
+	movedForDups(s.path, newPath)
+	if err := writeMetadata(newPath, s); err != nil {
+		log.Printf("Error writing metadata to %s: %v", newPath, err)
+	}
+	if err := writeFinderTags(newPath, s); err != nil {
+		log.Printf("Error writing Finder tags to %s: %v", newPath, err)
+	}
+	if err := writeSidecar(newPath, s); err != nil {
+		log.Printf("Error writing sidecar for %s: %v", newPath, err)
+	}
+}
+
+// applyDecision carries out decision about s, filling in rec.
+func applyDecision(s suggestion, decision string, rec fileRecord) fileRecord {
+	rec.Name = sanitizeFileName(s.name)
+	switch decision {
+	case "y":
+		newPath, err := moveFile(s.path, s.dir(), s.name)
+		if errors.Is(err, errNameTaken) {
+			fmt.Fprintf(msgOut, "Not renaming %s: %v\n", s.path, err)
+			return rec
+		}
+		if err != nil {
+			log.Printf("Failed to rename %s: %v", s.path, err)
+			rec.Action, rec.Error = actionError, err.Error()
+			return rec
+		}
+		rec.Action, rec.NewPath = actionRenamed, newPath
+		fmt.Fprintf(msgOut, "Renamed %s to %s\n", s.path, newPath)
+		afterRename(s, newPath)
+	case "d":
+		if err := deferForReview(s); err != nil {
+			log.Printf("Error deferring %s: %v", s.path, err)
+			rec.Action, rec.Error = actionError, err.Error()
+			return rec
+		}
+		rec.Action = actionDeferred
+		fmt.Fprintf(msgOut, "Deferred %s, run `tell-me-more review` to decide later\n", s.path)
+	case "q":
+		if err := quarantine(s); err != nil {
+			log.Printf("Error quarantining %s: %v", s.path, err)
+			rec.Action, rec.Error = actionError, err.Error()
+			return rec
+		}
+		rec.Action = actionQuarantined
+		fmt.Fprintf(msgOut, "Quarantined %s\n", s.path)
+	}
+	return rec
 }
 
 func isTargetFile(filename string) bool {
-    filename = strings.ToLower(filename)
-    screenshotPattern := regexp.MustCompile(`screenshot`)
-    dallePattern := regexp.MustCompile(`dalle?`)
-    return screenshotPattern.MatchString(filename) || dallePattern.MatchString(filename)
+	if isSyncConflict(filename) {
+		return false
+	}
+	if ok, decided := matchesUserPatterns(filename); decided {
+		return ok
+	}
+	filename = strings.ToLower(filename)
+	screenshotPattern := regexp.MustCompile(`screenshot`)
+	dallePattern := regexp.MustCompile(`dalle?`)
+	recordingPattern := regexp.MustCompile(`recording|voice memo|memo`)
+	// Obsidian's default name for pasted attachments.
+	pastedPattern := regexp.MustCompile(`^pasted image`)
+	return screenshotPattern.MatchString(filename) || dallePattern.MatchString(filename) ||
+		(isImageFile(filename) && pastedPattern.MatchString(filename)) ||
+		((isImageFile(filename) || isAudioFile(filename)) && isChatMedia(filename)) ||
+		((isAudioFile(filename) || isVideoFile(filename)) && recordingPattern.MatchString(filename)) ||
+		((isZipFile(filename) || isOfficeFile(filename)) && isMeaninglessName(filename)) ||
+		isEPUBFile(filename) || isUnnamedPDF(filename) || isCameraRAW(filename) || matchesExtraPattern(filename)
+}
+
+// describeFile returns a textual account of path's content: a transcript
+// for audio and a Gemini description for everything else.
+func describeFile(path string) (string, error) {
+	switch {
+	case isVideo(path):
+		return describeVideo(path)
+	case isAudioFile(path):
+		return transcribeAudio(path)
+	case isZipFile(path):
+		return describeZip(path)
+	case isOfficeFile(path):
+		return describeOffice(path)
+	case isEPUBFile(path):
+		return describeEPUB(path)
+	case isPDFFile(path):
+		return describePDF(path)
+	case isAnimatedGIF(path):
+		return describeGIF(path)
+	case isRAWFile(path):
+		return describeRAW(path)
+	case isImageFile(path):
+		if description, ok, err := describeTiled(path); ok {
+			return description, err
+		}
+		if useThumbnail {
+			if description, ok, err := describeThumbnail(path); ok {
+				return description, err
+			}
+		}
+	}
+	return getImageSentiment(path)
+}
+
+// suggestName asks for a file name based on what describeFile returned.
+func suggestName(path, description string) (string, error) {
+	r, err := suggestNaming(path, description)
+	return r.Name, err
+}
+
+// suggestNaming is suggestName with the tags, category and confidence
+// that images are named with.
+func suggestNaming(path, description string) (namingResult, error) {
+	var r namingResult
+	var err error
+	switch {
+	case isAudioFile(path) && !isVideo(path):
+		r.Name, err = getNameFromTranscript(description)
+	case isZipFile(path):
+		r.Name, err = getNameForArchive(description)
+	case isOfficeFile(path):
+		r.Name, err = getNameForDocument(description)
+	case isEPUBFile(path):
+		r.Name, err = getNameForEPUB(description)
+	case namingMode == "seo":
+		r.Name, err = getSEOSlug(description)
+	default:
+		r, err = getNaming(description)
+	}
+	if err == nil {
+		traceNaming(path, r.Name)
+	}
+	return r, err
 }
 
 func getImageSentiment(imagePath string) (string, error) {
-	ctx := context.Background()
-	// Access your API key as an environment variable
-	client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("GEMINI_API_KEY")))
+	return currentProvider().Describe(runCtx, imagePath)
+}
+
+// askGeminiAboutFile uploads a file and asks Gemini prompt about it, or
+// asks the --provider instead when it can see images.
+func askGeminiAboutFile(imagePath, prompt string) (string, error) {
+	if p, ok := currentProvider().(imageAsker); ok && isImageFile(imagePath) {
+		return p.AskAboutFile(runCtx, imagePath, prompt)
+	}
+	ask := func() (string, string, error) {
+		reply, err := withKey(geminiKeys, func(apiKey string) (string, error) {
+			return askGeminiAboutFileWithKey(apiKey, imagePath, prompt)
+		})
+		return reply, geminiModel, err
+	}
+	content, err := fileSHA256(imagePath)
+	if err != nil {
+		reply, _, err := ask()
+		return reply, err
+	}
+	return cachedAnswer(cacheKey("gemini", content, geminiModel, prompt, uploadVariant()), content, prompt, ask)
+}
+
+func askGeminiAboutFileWithKey(apiKey, imagePath, prompt string) (string, error) {
+	ctx := runCtx
+	client, err := geminiClient(apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	upload, cleanup, err := prepareUpload(imagePath)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	defer client.Close()
+	defer cleanup()
 
-	file, err := client.UploadFileFromPath(ctx, filepath.Join(imagePath), nil)
+	info, err := os.Stat(upload)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+	model := geminiModelFor(client, prompt)
+	// Small images go with the request itself, which saves uploading,
+	// polling for and deleting a file.
+	if isImageFile(imagePath) && info.Size() <= inlineImageLimit {
+		data, err := os.ReadFile(upload)
+		if err != nil {
+			return "", err
+		}
+		uploadLimiter.wait(len(data))
+		resp, err := model.GenerateContent(ctx,
+			genai.Blob{MIMEType: imageMIMEType(upload), Data: data},
+			genai.Text(prompt))
+		if err != nil {
+			return "", fmt.Errorf("Gemini API error: %v", err)
+		}
+		result := responseText(resp)
+		recordGeminiUsage(result, resp)
+		return result, nil
 	}
-	defer client.DeleteFile(ctx, file.Name)
 
-	gotFile, err := client.GetFile(ctx, file.Name)
+	var fileName string
+	if info.Size() > resumableThreshold {
+		fileName, err = uploadResumable(ctx, apiKey, upload)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		f, err := os.Open(upload)
+		if err != nil {
+			return "", err
+		}
+		file, err := client.UploadFile(ctx, "", throttled(f), nil)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("uploading %s: %v", imagePath, err)
+		}
+		fileName = file.Name
+	}
+	// A file left behind would sit in the project's storage for two days,
+	// so it is deleted even when the run is being interrupted.
+	uploadsInFlight.Add(1)
+	defer uploadsInFlight.Add(-1)
+	defer client.DeleteFile(context.WithoutCancel(ctx), fileName)
+
+	file, err := waitForFile(ctx, client, fileName)
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("fetching uploaded file: %v", err)
 	}
-	fmt.Println("File received:", gotFile.Name)
+	log.Printf("File received: %s", file.Name)
 
-	model := client.GenerativeModel("gemini-1.5-flash")
 	resp, err := model.GenerateContent(ctx,
 		genai.FileData{URI: file.URI},
-		genai.Text("Can you tell me about this photo, describe it in as much detail as possible, include an overall impression about what the image may be about."))
+		genai.Text(prompt))
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("Gemini API error: %v", err)
 	}
 
-	var result string
-	for _, c := range resp.Candidates {
-			if c.Content != nil {
-					for _, part := range c.Content.Parts {
-							if text, ok := part.(genai.Text); ok {
-									result += string(text)
-							}
-					}
-			}
-	}
+	result := responseText(resp)
 	// fmt.Println("Result:", result)
+	recordGeminiUsage(result, resp)
 	return result, nil
 
+}
+
+// describeImageBytes is getImageSentiment for an image that only exists in
+// memory; it is sent inline rather than through the Files API.
+func describeImageBytes(data []byte, mimeType string) (string, error) {
+	return askGeminiAboutImage(data, mimeType, describePrompt())
+}
 
+// askGeminiAboutImage sends an image inline with the request instead of
+// uploading it first.
+func askGeminiAboutImage(data []byte, mimeType, prompt string) (string, error) {
+	if p, ok := currentProvider().(imageAsker); ok {
+		return p.AskAboutImage(runCtx, data, mimeType, prompt)
+	}
+	sum := sha256.Sum256(data)
+	content := hex.EncodeToString(sum[:])
+	return cachedAnswer(cacheKey("gemini", content, geminiModel, prompt, uploadVariant()), content, prompt, func() (string, string, error) {
+		reply, err := withKey(geminiKeys, func(apiKey string) (string, error) {
+			return askGeminiAboutImageWithKey(apiKey, data, mimeType, prompt)
+		})
+		return reply, geminiModel, err
+	})
 }
 
+func askGeminiAboutImageWithKey(apiKey string, data []byte, mimeType, prompt string) (string, error) {
+	ctx := runCtx
+	client, err := geminiClient(apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, mimeType, err = fitImageBytes(data, mimeType)
+	if err != nil {
+		return "", err
+	}
+	uploadLimiter.wait(len(data))
+
+	model := geminiModelFor(client, prompt)
+	resp, err := model.GenerateContent(ctx,
+		genai.Blob{MIMEType: mimeType, Data: data},
+		genai.Text(prompt))
+	if err != nil {
+		return "", fmt.Errorf("Gemini API error: %v", err)
+	}
+	result := responseText(resp)
+	recordGeminiUsage(result, resp)
+	return result, nil
+}
 
-func getDescriptionFromChatGPT(labels string) (string, error) {
-    openaiAPIKey := os.Getenv("OPENAI_API_KEY")
-    if openaiAPIKey == "" {
-        return "", fmt.Errorf("OpenAI API key not set")
-    }
+// askGemini answers a text prompt with Gemini, which --pipeline=gemini
+// uses for everything that would otherwise go to OpenAI.
+func askGemini(prompt string) (string, error) {
+	return cachedAnswer(cacheKey("gemini", geminiModel, prompt), "", prompt, func() (string, string, error) {
+		reply, err := withKey(geminiKeys, func(apiKey string) (string, error) {
+			return askGeminiWithKey(apiKey, prompt)
+		})
+		return reply, geminiModel, err
+	})
+}
 
-    client := openai.NewClient(openaiAPIKey)
+func askGeminiWithKey(apiKey, prompt string) (string, error) {
+	client, err := geminiClient(apiKey)
+	if err != nil {
+		return "", err
+	}
+	resp, err := geminiModelFor(client, prompt).GenerateContent(runCtx, genai.Text(prompt))
+	if err != nil {
+		return "", fmt.Errorf("Gemini API error: %v", err)
+	}
+	result := strings.TrimSpace(responseText(resp))
+	if result == "" {
+		return "", fmt.Errorf("no response from Gemini API")
+	}
+	rememberReply(result, geminiModel, prompt)
+	recordGeminiUsage(result, resp)
+	return result, nil
+}
 
-    var prompt string
-    if len(labels) > 0 {
-        prompt = fmt.Sprintf(`You are a creative assistant that generates human-like filenames for images.
+func describePrompt() string {
+	if config.Prompts.Describe != "" {
+		return config.Prompts.Describe + webpagePrompt + chatMediaPrompt() + langPrompt()
+	}
+	return "Can you tell me about this photo, describe it in as much detail as possible, include an overall impression about what the image may be about." + webpagePrompt + chatMediaPrompt() + langPrompt()
+}
 
-An image is provided, but no labels or descriptions are available.
+// recordGeminiUsage is recordUsage for a Gemini response.
+func recordGeminiUsage(reply string, resp *genai.GenerateContentResponse) {
+	if m := resp.UsageMetadata; m != nil {
+		recordUsage(reply, geminiModel, int(m.PromptTokenCount), int(m.CandidatesTokenCount))
+	}
+}
 
-Using your imagination, suggest a short, descriptive, and human-friendly filename for the image (without file extension). There will be a large reward for the best, most human file name. Don't forget to be a human the output name MUST be short. 
-For example a screenshot of the youtube website, will have lots of descriptive and various interesting points but a good name would be 'youtube_homepage'
+func responseText(resp *genai.GenerateContentResponse) string {
+	var result string
+	for _, c := range resp.Candidates {
+		if c.Content != nil {
+			for _, part := range c.Content.Parts {
+				if text, ok := part.(genai.Text); ok {
+					result += string(text)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// getNaming asks the --provider to name the file labels describe.
+func getNaming(labels string) (namingResult, error) {
+	reply, err := currentProvider().SuggestName(runCtx, labels)
+	if err != nil {
+		return namingResult{}, err
+	}
+	return parseNaming(reply, "naming")
+}
 
-Make sure the name suggestion is under 40 characters, the fewer words the better:`, labels)
-    } else {
-        prompt = `You are a creative assistant that generates human-like filenames for images.
+// namingPrompt asks for a file name for the image labels describe.
+func namingPrompt(labels string) string {
+	if config.Prompts.Name != "" {
+		return nameFromConfigPrompt(labels) + namingJSONFormat(false)
+	}
+	var prompt string
+	if len(labels) > 0 {
+		prompt = fmt.Sprintf(`You are a creative assistant that generates human-like filenames for images.
+
+Here is a description of the image:
+%s
+
+Using this description, suggest a short, descriptive, and human-friendly filename for the image (without file extension). There will be a large reward for the best, most human file name. Don't forget to be a human the output name MUST be short.
+For example a screenshot of the youtube website, will have lots of descriptive and various interesting points but a good name would be 'youtube_homepage'%s
+
+Make sure the name suggestion is under 40 characters, the fewer words the better:`, labels, webpageHint(labels)+chatMediaNameHint()+langNameHint())
+	} else {
+		prompt = `You are a creative assistant that generates human-like filenames for images.
 
 An image is provided, but no labels or descriptions are available.
 
-Using your imagination, suggest a short, descriptive, and human-friendly filename for the image (without file extension). There will be a large reward for the best, most human file name. Don't forget to be a human the output name MUST be short. 
+Using your imagination, suggest a short, descriptive, and human-friendly filename for the image (without file extension). There will be a large reward for the best, most human file name. Don't forget to be a human the output name MUST be short.
 For example a screenshot of the youtube website, will have lots of descriptive and various interesting points but a good name would be 'youtube_homepage'
 
 Make sure the name suggestion is under 40 characters, the fewer words the better:`
-    }
-
-    ctx := context.Background()
-    resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-        Model: openai.GPT4, // Use openai.GPT3Dot5Turbo if GPT-4 is not available
-        Messages: []openai.ChatCompletionMessage{
-            {
-                Role:    openai.ChatMessageRoleUser,
-                Content: prompt,
-            },
-        },
-        MaxTokens:   100,
-        Temperature: 0.9,
-    })
-    if err != nil {
-        return "", fmt.Errorf("ChatGPT API error: %v", err)
-    }
-
-    if len(resp.Choices) > 0 {
-        return strings.TrimSpace(resp.Choices[0].Message.Content), nil
-    }
-
-    return "", fmt.Errorf("no response from ChatGPT API")
-}
-
-func renameFile(path, description string) {
-    dir := filepath.Dir(path)
-    ext := filepath.Ext(path)
-    newName := fmt.Sprintf("%s/%s%s", dir, sanitizeFileName(description), ext)
-
-    err := os.Rename(path, newName)
-    if err != nil {
-        log.Fatalf("Failed to rename file: %v", err)
-    }
-    fmt.Printf("Renamed %s to %s\n", path, newName)
+	}
+	return prompt + namingJSONFormat(false)
+}
+
+// askChatGPT sends a single-message prompt and returns the trimmed reply.
+// Providers that answer text prompts themselves get it instead.
+func askChatGPT(prompt string) (string, error) {
+	if p, ok := currentProvider().(promptAsker); ok {
+		return p.Ask(runCtx, prompt)
+	}
+	if pipeline == "gemini" {
+		return askGemini(prompt)
+	}
+	return cachedAnswer(cacheKey("openai", openaiModel, prompt), "", prompt, func() (string, string, error) {
+		if queueForBatch(prompt) {
+			return "", "", errQueuedForBatch
+		}
+		reply, err := withKey(openaiKeys, func(openaiAPIKey string) (string, error) {
+			return askChatGPTWithKey(openaiAPIKey, prompt)
+		})
+		return reply, openaiModel, err
+	})
+}
+
+// chatRequest is the request askChatGPT sends for prompt, directly or as
+// part of a --batch.
+func chatRequest(prompt string) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model: openaiModel, // Use openai.GPT3Dot5Turbo if GPT-4 is not available
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		MaxTokens:      200,
+		Temperature:    0.9,
+		ResponseFormat: openaiResponseFormat(openaiModel, prompt),
+	}
+}
+
+func askChatGPTWithKey(openaiAPIKey, prompt string) (string, error) {
+	if openaiAPIKey == "" {
+		return "", fmt.Errorf("OpenAI API key not set")
+	}
+
+	client := openaiClient(openaiAPIKey)
+
+	ctx := runCtx
+	resp, err := client.CreateChatCompletion(ctx, chatRequest(prompt))
+	if err != nil {
+		return "", fmt.Errorf("ChatGPT API error: %v", err)
+	}
+
+	if len(resp.Choices) > 0 {
+		reply := strings.TrimSpace(resp.Choices[0].Message.Content)
+		rememberReply(reply, resp.Model, prompt)
+		recordUsage(reply, resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+		return reply, nil
+	}
+
+	return "", fmt.Errorf("no response from ChatGPT API")
+}
+
+// renameFile renames path to the sanitized description, keeping the
+// original extension, and returns the new path. A name that is taken is
+// handled as --on-conflict says.
+func renameFile(path, description string) (string, error) {
+	return moveFile(path, filepath.Dir(path), description)
+}
+
+// moveFile is renameFile into dir, which is created if need be.
+func moveFile(path, dir, description string) (string, error) {
+	newName, err := targetPathIn(dir, path, description)
+	if err != nil {
+		return "", err
+	}
+	if dryRun {
+		planRename(path, newName)
+		return newName, errDryRun
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if nameTaken(path, newName) {
+		var replace bool
+		if newName, replace, err = resolveConflict(newName); err != nil {
+			return "", err
+		}
+		// Copying refuses to replace anything, so clear the way.
+		if replace && copyRenames {
+			if err := os.Remove(newName); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// An interrupt waits for the rename and its journal entry, so undo
+	// always knows about it.
+	renameMu.Lock()
+	defer renameMu.Unlock()
+	rename := renameRetrying
+	if copyRenames {
+		rename = copyRename
+	}
+	refs, err := renameWithReferences(path, newName, rename)
+	if err != nil {
+		return "", err
+	}
+	audit("rename", absPath(path), absPath(newName), path)
+	journal(path, newName, path, refs)
+	recordOriginalName(path, newName)
+	moveRAWSidecars(path, newName)
+	return newName, nil
+}
+
+// targetPath is where renameFile would move path to.
+func targetPath(path, description string) (string, error) {
+	return targetPathIn(filepath.Dir(path), path, description)
+}
+
+// targetPathIn is where moveFile would move path to.
+func targetPathIn(dir, path, description string) (string, error) {
+	name := sanitizeFileName(description)
+	if name == "" {
+		return "", fmt.Errorf("suggested name %q is empty after sanitizing", description)
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s/%s%s", dir, name, ext), nil
 }
 
 func sanitizeFileName(name string) string {
-    reg := regexp.MustCompile(`[^\w\- ]`)
-    cleanName := reg.ReplaceAllString(name, "")
-    cleanName = strings.ReplaceAll(strings.TrimSpace(cleanName), " ", "_")
-    return cleanName
-}
\ No newline at end of file
+	reg := regexp.MustCompile(`[^\w\- ]`)
+	cleanName := reg.ReplaceAllString(name, "")
+	cleanName = strings.ReplaceAll(strings.TrimSpace(cleanName), " ", "_")
+	return cleanName
+}
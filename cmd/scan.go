@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	scanWorkers int
+
+	// maxDepth is how many levels below the starting directory are
+	// walked; -1 for no limit.
+	maxDepth       int
+	followSymlinks bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&scanWorkers, "scan-workers", 16, "directories read in parallel while looking for files")
+	rootCmd.PersistentFlags().IntVar(&maxDepth, "max-depth", -1, "walk at most this many directory levels below the one given (0: only that directory)")
+	rootCmd.PersistentFlags().BoolVar(&followSymlinks, "follow-symlinks", false, "follow symbolic links to files and directories, each directory at most once")
+}
+
+// scanTree walks root with up to workers directories being read at once
+// and, for every directory, sends the paths of the files in it that match
+// accepts as one batch. Directories skipDir rejects are not entered,
+// and neither is anything --exclude or a .tmmignore excludes, anything
+// deeper than --max-depth, or, without --follow-symlinks, any symlink.
+// A directory that can't be read is logged and left out; only failing to
+// read root itself is an error.
+//
+// Entries come from os.ReadDir, so, unlike filepath.Walk, nothing is
+// stat'ed just to be looked at. Memory follows the shape of the tree
+// rather than its size: the paths of the directories waiting to be read
+// are queued, depth first, and a batch holds the matching files of one
+// directory, whole however many there are, since a series is numbered
+// across all of them. Workers block on out while the consumer is busy, so
+// no more than a few batches are held at once.
+func scanTree(root string, workers int, match func(path string) bool, out chan<- []string) error {
+	if _, err := os.ReadDir(root); err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	// scanDir is a queued directory with the ignore rules above it.
+	type scanDir struct {
+		path, abs string
+		rules     []ignoreRule
+		depth     int
+	}
+	var (
+		mu      sync.Mutex
+		cond    = sync.NewCond(&mu)
+		queue   = []scanDir{{root, absRoot, rootIgnoreRules(root), 0}}
+		pending = 1 // directories queued or being read
+
+		// visited are the real paths of the directories entered, so
+		// following a link back up the tree does not loop.
+		visited = map[string]bool{}
+	)
+	firstVisit := func(path string) bool {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if visited[real] {
+			return false
+		}
+		visited[real] = true
+		return true
+	}
+	if followSymlinks {
+		firstVisit(root)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				for len(queue) == 0 && pending > 0 {
+					cond.Wait()
+				}
+				if pending == 0 {
+					mu.Unlock()
+					return
+				}
+				dir := queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+				mu.Unlock()
+
+				entries, err := os.ReadDir(dir.path)
+				if err != nil {
+					log.Printf("Error reading %s: %v", dir.path, err)
+				}
+				rules := readIgnoreFile(dir.abs, dir.rules)
+				var subdirs []scanDir
+				var batch []string
+				for _, e := range entries {
+					path := filepath.Join(dir.path, e.Name())
+					abs := filepath.Join(dir.abs, e.Name())
+					isDir := e.IsDir()
+					if e.Type()&fs.ModeSymlink != 0 {
+						if !followSymlinks {
+							continue
+						}
+						info, err := os.Stat(path)
+						if err != nil {
+							continue // dangling
+						}
+						isDir = info.IsDir()
+					}
+					if ignored(rules, abs, isDir) {
+						continue
+					}
+					if isDir {
+						if (maxDepth >= 0 && dir.depth >= maxDepth) || skipDir(root, path) {
+							continue
+						}
+						if followSymlinks && !firstVisit(path) {
+							log.Printf("Not walking %s again: it leads to a directory already walked", path)
+							continue
+						}
+						subdirs = append(subdirs, scanDir{path, abs, rules, dir.depth + 1})
+					} else if match(path) {
+						batch = append(batch, path)
+					}
+				}
+				entries = nil
+				if len(batch) > 0 {
+					out <- batch
+				}
+
+				mu.Lock()
+				queue = append(queue, subdirs...)
+				pending += len(subdirs) - 1
+				cond.Broadcast()
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// screenshotNamePattern matches the names macOS gives screenshots, in
+// English and a few other languages, old and new style:
+//
+//	Screenshot 2024-05-03 at 10.11.12.png
+//	Screen Shot 2020-01-01 at 1.02.03 PM.png
+//	Bildschirmfoto 2024-05-03 um 10.11.12.png
+//
+// Since Ventura the space before AM/PM is a narrow no-break space.
+var screenshotNamePattern = regexp.MustCompile(`(?i)^(?:screen ?shot|bildschirmfoto|capture d.écran|schermafbeelding|captura de pantalla|schermata|skärmavbild|スクリーンショット) (\d{4}-\d{2}-\d{2}) (?:at|um|à|om|a las|alle|kl\.|) ?(\d{1,2})\.(\d{2})\.(\d{2})(?:[ \x{202F}]?([AP]M))?`)
+
+// screenshotTime parses the capture time out of a macOS screenshot name.
+func screenshotTime(name string, loc *time.Location) (time.Time, bool) {
+	m := screenshotNamePattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	value, layout := m[1]+" "+m[2]+"."+m[3]+"."+m[4], "2006-01-02 15.04.05"
+	if m[5] != "" {
+		value, layout = value+" "+strings.ToUpper(m[5]), "2006-01-02 3.04.05 PM"
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	return t, err == nil
+}
+
+// windowTextKeys are the PNG text chunk keys capture tools record the
+// captured window or application under.
+var windowTextKeys = []string{"window", "window title", "windowtitle", "title", "application", "app"}
+
+// screenshotWindow returns the captured window or application recorded in
+// a screenshot's PNG text chunks, or "" when the tool didn't record one
+// (macOS itself doesn't).
+func screenshotWindow(path string) string {
+	text := pngText(path)
+	for _, key := range windowTextKeys {
+		if v := strings.TrimSpace(text[key]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// pngTextLimit skips text chunks too large to be metadata worth reading.
+const pngTextLimit = 1 << 20
+
+// pngText reads the tEXt, zTXt and iTXt chunks of a PNG, keyed by their
+// lower-cased keyword. Image data is skipped over, not read.
+func pngText(path string) map[string]string {
+	text := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return text
+	}
+	defer f.Close()
+	sig := make([]byte, 8)
+	if _, err := io.ReadFull(f, sig); err != nil || string(sig) != "\x89PNG\r\n\x1a\n" {
+		return text
+	}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			return text
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:])
+		if kind == "IEND" {
+			return text
+		}
+		if (kind != "tEXt" && kind != "zTXt" && kind != "iTXt") || length > pngTextLimit {
+			if _, err := f.Seek(length+4, io.SeekCurrent); err != nil {
+				return text
+			}
+			continue
+		}
+		data := make([]byte, length+4) // and the CRC
+		if _, err := io.ReadFull(f, data); err != nil {
+			return text
+		}
+		if key, value, ok := pngTextChunk(kind, data[:length]); ok {
+			text[strings.ToLower(key)] = value
+		}
+	}
+}
+
+func pngTextChunk(kind string, data []byte) (string, string, bool) {
+	key, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return "", "", false
+	}
+	switch kind {
+	case "tEXt":
+		return string(key), latin1(rest), true
+	case "zTXt":
+		if len(rest) < 1 {
+			return "", "", false
+		}
+		value, ok := inflate(rest[1:])
+		return string(key), latin1(value), ok
+	}
+	// iTXt: compression flag, method, language tag, translated keyword, text.
+	if len(rest) < 2 {
+		return "", "", false
+	}
+	compressed, rest := rest[0] == 1, rest[2:]
+	if _, rest, ok = bytes.Cut(rest, []byte{0}); !ok {
+		return "", "", false
+	}
+	if _, rest, ok = bytes.Cut(rest, []byte{0}); !ok {
+		return "", "", false
+	}
+	if compressed {
+		if rest, ok = inflate(rest); !ok {
+			return "", "", false
+		}
+	}
+	return string(key), string(rest), true
+}
+
+func inflate(data []byte) ([]byte, bool) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, pngTextLimit))
+	return out, err == nil
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+var (
+	// byNameOnly is --by-name-only: images whose names don't give them
+	// away aren't opened to see whether they are screenshots.
+	byNameOnly bool
+	// byScreenSize is --by-screen-size: a PNG the size of a display counts
+	// as a screenshot with nothing else to say so. Wallpapers are that
+	// size too, so it is off by default.
+	byScreenSize bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&byNameOnly, "by-name-only", false, "find screenshots by their names alone, without reading the metadata of other images")
+	rootCmd.PersistentFlags().BoolVar(&byScreenSize, "by-screen-size", false, "also take PNG and WebP images exactly the size of a common screen for screenshots, even with no metadata saying so (this picks up wallpapers too)")
+}
+
+// isTargetPath is isTargetFile for a file that can be looked into: images
+// with other names are still targets when their metadata or size says
+// they are screenshots, unless tell-me-more gave them their name.
+func isTargetPath(path string) bool {
+	name := filepath.Base(path)
+	if isTargetFile(name) {
+		return true
+	}
+	if _, decided := matchesUserPatterns(name); decided || byNameOnly || isSyncConflict(name) ||
+		strings.HasPrefix(name, ".") || !isImageFile(name) {
+		return false
+	}
+	return screenshotEvidence(path) != "" && !renamedBefore(path)
+}
+
+// screenshotTools are the capture tools that name themselves in the
+// Software field of what they save.
+var screenshotTools = []string{
+	"screenshot", "screencapture", "snipping tool", "snip & sketch", "greenshot", "sharex",
+	"spectacle", "flameshot", "ksnip", "shutter", "lightshot", "shottr", "cleanshot", "snagit",
+}
+
+// screenshotEvidence says what marks the image at path as a screenshot, or
+// returns "". macOS and iOS write "Screenshot" as the user comment, in the
+// XMP of a PNG or the EXIF of a JPEG, capture tools put their name in the
+// Software field, and with --by-screen-size a lossless image exactly the
+// size of a common display, either way up, is taken for one.
+func screenshotEvidence(path string) string {
+	text := pngText(path)
+	software := text["software"]
+	if strings.Contains(text["xml:com.adobe.xmp"], ">Screenshot<") {
+		return "its metadata says Screenshot"
+	}
+	if x, err := readEXIF(path); err == nil {
+		if tag, err := x.Get(exif.Software); err == nil {
+			software, _ = tag.StringVal()
+		}
+		if exifUserComment(path) == "Screenshot" {
+			return "its metadata says Screenshot"
+		}
+	}
+	lower := strings.ToLower(software)
+	for _, tool := range screenshotTools {
+		if strings.Contains(lower, tool) {
+			return "it was saved by " + strings.TrimSpace(software)
+		}
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); byScreenSize && (ext == ".png" || ext == ".webp") {
+		if cfg, err := imageConfig(path); err == nil && displaySizes[[2]int{max(cfg.Width, cfg.Height), min(cfg.Width, cfg.Height)}] {
+			return fmt.Sprintf("it is %dx%d, the size of a screen", cfg.Width, cfg.Height)
+		}
+	}
+	return ""
+}
+
+// displaySizes are the resolutions of common monitors, laptops, tablets
+// and phones, in pixels, longer side first.
+var displaySizes = map[[2]int]bool{
+	{1280, 720}: true, {1280, 800}: true, {1366, 768}: true, {1440, 900}: true,
+	{1536, 864}: true, {1600, 900}: true, {1680, 1050}: true, {1920, 1080}: true,
+	{1920, 1200}: true, {2560, 1080}: true, {2560, 1440}: true, {2560, 1600}: true,
+	{2880, 1800}: true, {3024, 1964}: true, {3456, 2234}: true, {3440, 1440}: true,
+	{3840, 2160}: true, {5120, 2880}: true, {2736, 1824}: true, {2880, 1920}: true,
+	{2048, 1536}: true, {2224, 1668}: true, {2360, 1640}: true, {2388, 1668}: true,
+	{2732, 2048}: true, {1334, 750}: true, {1792, 828}: true, {2436, 1125}: true,
+	{2532, 1170}: true, {2556, 1179}: true, {2688, 1242}: true, {2778, 1284}: true,
+	{2796, 1290}: true, {2340, 1080}: true, {2400, 1080}: true, {2316, 1080}: true,
+	{3120, 1440}: true, {3200, 1440}: true, {2208, 1242}: true,
+}
+
+var (
+	renamedOnce sync.Once
+	// renamedPaths holds the names tell-me-more has given files: those in
+	// the rename history and those given since it was read.
+	renamedPaths sync.Map
+)
+
+// renamedBefore reports whether path is a name tell-me-more gave a file.
+func renamedBefore(path string) bool {
+	renamedOnce.Do(func() {
+		historyMu.Lock()
+		entries, err := loadHistory()
+		historyMu.Unlock()
+		if err != nil {
+			log.Printf("Error reading rename history: %v", err)
+		}
+		for _, e := range entries {
+			if !e.Undone {
+				renamedPaths.Store(e.New, true)
+			}
+		}
+	})
+	_, ok := renamedPaths.Load(absPath(path))
+	return ok
+}
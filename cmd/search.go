@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const searchIndexFile = "index.json"
+
+// indexSaveEvery is how many newly indexed files go by between saves, so
+// a long run that is cut short keeps most of its work.
+const indexSaveEvery = 25
+
+var (
+	rebuildIndex bool
+	searchLimit  int
+)
+
+// searchIndex is the local search index: a description of each image and
+// the embedding of it, all made with one embedding model.
+type searchIndex struct {
+	Model   string       `json:"model"`
+	Entries []indexEntry `json:"entries"`
+}
+
+type indexEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	// Text is what was embedded: the description and when the image was
+	// taken, so searches can mention dates.
+	Text      string    `json:"text"`
+	Vector    []float32 `json:"vector"`
+	IndexedAt time.Time `json:"indexed_at"`
+}
+
+var indexCmd = &cobra.Command{
+	Use:   "index <dir>...",
+	Short: "Describe the images under each directory into a local index for search",
+	Long: `Describes every image under the given directories and stores the description
+and its embedding in the state directory, where search looks for them. Images
+already indexed are only described again when their content has changed, and
+images that are gone are dropped from the index.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var idx searchIndex
+		if !rebuildIndex {
+			if err := loadState(searchIndexFile, &idx); err != nil {
+				return err
+			}
+		}
+		model := embeddingModel()
+		if idx.Model != "" && idx.Model != model {
+			return fmt.Errorf("the index was built with %s, not %s; run index again with --rebuild", idx.Model, model)
+		}
+		idx.Model = model
+		for _, dir := range args {
+			if err := indexDir(&idx, dir); err != nil {
+				return err
+			}
+		}
+		printCostSummary()
+		return nil
+	},
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Find indexed images by what they show, e.g. \"stripe dashboard screenshot from march\"",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var idx searchIndex
+		if err := loadState(searchIndexFile, &idx); err != nil {
+			return err
+		}
+		if len(idx.Entries) == 0 {
+			return fmt.Errorf("nothing is indexed yet; run `tell-me-more index <dir>` first")
+		}
+		if model := embeddingModel(); idx.Model != model {
+			return fmt.Errorf("the index was built with %s, but this search would use %s; search with the same --provider and --pipeline, or rebuild the index", idx.Model, model)
+		}
+		query, err := embedText(strings.Join(args, " "), true)
+		if err != nil {
+			return err
+		}
+		type match struct {
+			path  string
+			score float64
+		}
+		var matches []match
+		for _, e := range idx.Entries {
+			if _, err := os.Stat(e.Path); err != nil {
+				continue
+			}
+			matches = append(matches, match{e.Path, cosineSimilarity(query, e.Vector)})
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+		for _, m := range matches[:min(searchLimit, len(matches))] {
+			fmt.Printf("%.2f  %s\n", m.score, m.path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	indexCmd.Flags().BoolVar(&rebuildIndex, "rebuild", false, "start the index afresh, e.g. after switching embedding model")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 10, "show at most this many matches")
+	purgers[searchIndexFile] = purgeIndex
+	rootCmd.AddCommand(indexCmd, searchCmd)
+}
+
+// indexDir brings idx up to date with the images under dir, saving it as
+// it goes.
+func indexDir(idx *searchIndex, dir string) error {
+	dir = absPath(dir)
+	images, err := folderImages(dir)
+	if err != nil {
+		return fmt.Errorf("walking %s: %v", dir, err)
+	}
+	present := map[string]bool{}
+	for _, path := range images {
+		present[path] = true
+	}
+	// Images that were under dir and aren't any more leave the index.
+	at := map[string]int{}
+	kept := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		inDir := strings.HasPrefix(e.Path, dir+string(filepath.Separator))
+		if inDir && !present[e.Path] {
+			continue
+		}
+		at[e.Path] = len(kept)
+		kept = append(kept, e)
+	}
+	idx.Entries = kept
+
+	added := 0
+	for _, path := range images {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			log.Printf("Skipping %s: %v", path, err)
+			continue
+		}
+		i, ok := at[path]
+		if ok && idx.Entries[i].SHA256 == sum {
+			continue
+		}
+		description, err := describeFile(path)
+		if err != nil {
+			log.Printf("Error describing %s: %v", path, err)
+			continue
+		}
+		text := indexText(path, description)
+		vector, err := embedText(text, false)
+		if err != nil {
+			log.Printf("Error indexing %s: %v", path, err)
+			continue
+		}
+		e := indexEntry{Path: path, SHA256: sum, Text: text, Vector: vector, IndexedAt: time.Now()}
+		if ok {
+			idx.Entries[i] = e
+		} else {
+			at[path] = len(idx.Entries)
+			idx.Entries = append(idx.Entries, e)
+		}
+		fmt.Printf("Indexed %s\n", path)
+		if added++; added%indexSaveEvery == 0 {
+			if err := saveState(searchIndexFile, idx); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("%s: %d images, %d new or changed\n", dir, len(images), added)
+	return saveState(searchIndexFile, idx)
+}
+
+// indexText is what gets embedded for the image at path.
+func indexText(path, description string) string {
+	text := description
+	if t, _ := captureTime(path); !t.IsZero() {
+		text += "\n\nTaken on " + t.Format("Monday, 2 January 2006")
+	}
+	return text
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// purgeIndex is the purger for the search index. Entries match by the
+// content they were indexed with, which also finds files that are gone.
+func purgeIndex(t purgeTarget) (int, error) {
+	var idx searchIndex
+	if err := loadState(searchIndexFile, &idx); err != nil || len(idx.Entries) == 0 {
+		return 0, err
+	}
+	kept := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if (t.path != "" && e.Path != t.path) || (t.hash != "" && e.SHA256 != t.hash) {
+			kept = append(kept, e)
+		}
+	}
+	n := len(idx.Entries) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	idx.Entries = kept
+	return n, saveState(searchIndexFile, idx)
+}
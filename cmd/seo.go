@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	namingMode  string
+	slugMax     int
+	redirectMap string
+
+	redirectMu sync.Mutex
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&namingMode, "mode", "default", "naming style: default or seo (lowercase-hyphenated keyword slugs)")
+	rootCmd.PersistentFlags().IntVar(&slugMax, "slug-max", 60, "maximum slug length in --mode seo")
+	rootCmd.PersistentFlags().StringVar(&redirectMap, "redirect-map", "", "append old,new path pairs of every rename to this CSV file")
+}
+
+func validateMode() error {
+	if namingMode != "default" && namingMode != "seo" {
+		return fmt.Errorf("unknown --mode %q (want default or seo)", namingMode)
+	}
+	return nil
+}
+
+// slugStopWords carry no search value and only make slugs longer.
+var slugStopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "of": true,
+	"in": true, "on": true, "at": true, "to": true, "for": true, "with": true,
+	"by": true, "from": true, "is": true, "are": true, "this": true, "that": true,
+	"image": true, "photo": true, "picture": true,
+}
+
+var slugSeparatorPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify lowercases, hyphenates and drops stop words, then cuts the slug
+// at a hyphen so it fits in max characters.
+func slugify(text string, max int) string {
+	var words []string
+	for _, w := range slugSeparatorPattern.Split(strings.ToLower(text), -1) {
+		if w != "" && !slugStopWords[w] {
+			words = append(words, w)
+		}
+	}
+	slug := ""
+	for _, w := range words {
+		next := w
+		if slug != "" {
+			next = slug + "-" + w
+		}
+		if len(next) > max {
+			break
+		}
+		slug = next
+	}
+	if slug == "" && len(words) > 0 {
+		slug = words[0][:min(len(words[0]), max)]
+	}
+	return slug
+}
+
+func getSEOSlug(description string) (string, error) {
+	prompt := fmt.Sprintf(`You are an SEO specialist naming image files for a website.
+
+Here is a description of the image:
+%s
+
+Suggest a file name made of 3 to 8 lowercase keywords separated by hyphens, most important first, using the words people would search for, for example 'blue-ceramic-pour-over-coffee-dripper'. No stop words, no file extension, nothing else in the reply.`, description)
+	slug, err := askChatGPT(prompt)
+	if err != nil {
+		return "", err
+	}
+	if t, ok := replyTraces.Load(slug); ok {
+		replyTraces.Store(slugify(slug, slugMax), t)
+	}
+	slug = slugify(slug, slugMax)
+	if slug == "" {
+		return "", fmt.Errorf("no usable slug in the reply")
+	}
+	return slug, nil
+}
+
+// recordRedirect appends a rename to --redirect-map so the old URLs can be
+// redirected to the new ones.
+func recordRedirect(oldPath, newPath string) error {
+	if redirectMap == "" {
+		return nil
+	}
+	redirectMu.Lock()
+	defer redirectMu.Unlock()
+	f, err := os.OpenFile(redirectMap, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{filepath.ToSlash(oldPath), filepath.ToSlash(newPath)})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
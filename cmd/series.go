@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// seriesTemplate is the default --template in --series mode.
+const seriesTemplate = "{{.Seq}}_{{.Name}}"
+
+var (
+	seriesMode bool
+
+	seriesMu  sync.Mutex
+	seriesSeq = map[string]string{}
+)
+
+func init() {
+	rootCmd.Flags().BoolVar(&seriesMode, "series", false, "number files in capture-time order within each folder ("+seriesTemplate+" unless --template is given)")
+}
+
+// orderSeries sorts paths by capture time within each directory, keeping
+// directories in the order they were found, and records each file's
+// zero-padded position for {{.Seq}}.
+func orderSeries(paths []string) []string {
+	var dirs []string
+	byDir := map[string][]string{}
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], path)
+	}
+
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	var ordered []string
+	for _, dir := range dirs {
+		files := byDir[dir]
+		times := make(map[string]time.Time, len(files))
+		for _, path := range files {
+			times[path], _ = captureTime(path)
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return times[files[i]].Before(times[files[j]])
+		})
+		width := len(fmt.Sprint(len(files)))
+		if width < 3 {
+			width = 3
+		}
+		for i, path := range files {
+			seriesSeq[path] = fmt.Sprintf("%0*d", width, i+1)
+		}
+		ordered = append(ordered, files...)
+	}
+	return ordered
+}
+
+func seriesNumber(path string) string {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	seq, ok := seriesSeq[path]
+	if !ok && templateUses("Seq") {
+		log.Printf("No sequence number for %s; use --series", path)
+	}
+	return seq
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// serviceName is what the watch service is registered and logs as.
+const serviceName = "tell-me-more"
+
+var (
+	serviceAccount  string
+	servicePassword string
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run watch as a Windows service",
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install [--account user --password pass] -- <dir>... [watch flags]",
+	Short: "Register and start a Windows service that runs watch",
+	Long: `install registers a service that runs tell-me-more watch with the arguments
+after --, starts it, and starts it again after every boot. Its messages go to
+the Application event log under the tell-me-more source. When it stops
+unexpectedly it is restarted after 5 seconds, then 30 seconds, then a minute.
+
+A service can't ask anything, so the arguments need --yes or --no-input. It
+runs as LocalSystem unless --account and --password name a user; use your own
+account so it sees the same home folder and rename history you do. API keys
+come from the config file, which the service is pointed at.
+
+  tell-me-more service install --account .\me --password secret -- C:\Users\me\Pictures\Screenshots --yes`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !slices.ContainsFunc(args, func(arg string) bool {
+			return arg == "-y" || strings.HasPrefix(arg, "--yes") || strings.HasPrefix(arg, "--no-input")
+		}) {
+			return fmt.Errorf("a service can't ask before renaming; add --yes or --no-input to the watch arguments")
+		}
+		watchArgs := []string{"watch"}
+		for _, arg := range args {
+			// The service doesn't start in this directory.
+			if info, err := os.Stat(arg); err == nil && info.IsDir() {
+				arg = absPath(arg)
+			}
+			watchArgs = append(watchArgs, arg)
+		}
+		if !slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--config") }) {
+			path := configPath
+			if path == "" {
+				path = defaultConfigPath()
+			}
+			if _, err := os.Stat(path); err == nil {
+				watchArgs = append(watchArgs, "--config", absPath(path))
+			}
+		}
+		if err := installService(watchArgs); err != nil {
+			return fmt.Errorf("installing the %s service: %v", serviceName, err)
+		}
+		fmt.Printf("Installed and started the %s service: tell-me-more %s\n", serviceName, strings.Join(watchArgs, " "))
+		return nil
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the Windows service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := removeService(); err != nil {
+			return fmt.Errorf("removing the %s service: %v", serviceName, err)
+		}
+		fmt.Printf("Removed the %s service\n", serviceName)
+		return nil
+	},
+}
+
+func init() {
+	serviceInstallCmd.Flags().StringVar(&serviceAccount, "account", "", `the user the service runs as, e.g. .\me (default LocalSystem)`)
+	serviceInstallCmd.Flags().StringVar(&servicePassword, "password", "", "the password of --account")
+	serviceCmd.AddCommand(serviceInstallCmd, serviceUninstallCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package cmd
+
+import "errors"
+
+var errNoService = errors.New("services are only installed on Windows; use a launchd agent on macOS or a systemd user unit on Linux to keep watch running")
+
+func runningAsService() bool { return false }
+
+func runService() int { return 0 }
+
+func installService(args []string) error { return errNoService }
+
+func removeService() error { return errNoService }
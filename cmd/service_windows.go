@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceRestarts are the waits before the service manager restarts a
+// service that stopped unexpectedly, for the first, second and later
+// failures in a day.
+var serviceRestarts = []time.Duration{5 * time.Second, 30 * time.Second, time.Minute}
+
+// runningAsService reports whether the service manager started this
+// process.
+func runningAsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+func installService(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return errors.New("it is already installed; run service uninstall first")
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName:      "tell-me-more",
+		Description:      "Renames new screenshots and recordings as they appear.",
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true,
+		ServiceStartName: serviceAccount,
+		Password:         servicePassword,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var actions []mgr.RecoveryAction
+	for _, delay := range serviceRestarts {
+		actions = append(actions, mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: delay})
+	}
+	err = s.SetRecoveryActions(actions, uint32((24 * time.Hour).Seconds()))
+	if err == nil {
+		// A watch that exits with an error counts as a failure too.
+		err = s.SetRecoveryActionsOnNonCrashFailures(true)
+	}
+	if err == nil {
+		eventlog.Remove(serviceName)
+		err = eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+	}
+	if err != nil {
+		s.Delete()
+		return err
+	}
+	return s.Start()
+}
+
+func removeService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("it is not installed: %v", err)
+	}
+	defer s.Close()
+	if status, err := s.Control(svc.Stop); err == nil {
+		for deadline := time.Now().Add(uploadCleanupTimeout); status.State != svc.Stopped && time.Now().Before(deadline); {
+			time.Sleep(300 * time.Millisecond)
+			if status, err = s.Query(); err != nil {
+				break
+			}
+		}
+	}
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(serviceName)
+}
+
+// runService runs the command line the service was installed with under
+// the service manager and returns the exit code.
+func runService() int {
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		return 1
+	}
+	defer elog.Close()
+	// A service has no console: what would be printed goes to the event
+	// log, messages as information and log lines as warnings.
+	stdout, err := eventLogStream(elog.Info)
+	if err == nil {
+		os.Stdout = stdout
+		os.Stderr, err = eventLogStream(elog.Warning)
+	}
+	if err != nil {
+		elog.Error(1, fmt.Sprintf("Cannot send output to the event log: %v", err))
+		return 1
+	}
+	log.SetOutput(os.Stderr)
+
+	w := &watchService{}
+	if err := svc.Run(serviceName, w); err != nil {
+		elog.Error(1, fmt.Sprintf("Cannot run as a service: %v", err))
+		return 1
+	}
+	if w.err != nil {
+		elog.Error(1, w.err.Error())
+		return 1
+	}
+	return 0
+}
+
+// eventLogStream is a file whose lines are each written to the event log
+// with write.
+func eventLogStream(write func(eid uint32, msg string) error) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				write(1, line)
+			}
+		}
+		io.Copy(io.Discard, r)
+	}()
+	return w, nil
+}
+
+// watchService runs the command line as a service, stopping it when the
+// service manager asks.
+type watchService struct {
+	err error
+}
+
+func (w *watchService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() { done <- rootCmd.Execute() }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			status <- svc.Status{State: svc.StopPending}
+			if err != nil {
+				w.err = err
+				return true, 1
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancelRun()
+			}
+		}
+	}
+}
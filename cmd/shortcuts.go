@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// shortcutsVersion is bumped whenever the JSON shape below changes in a
+// way that would break an existing shortcut.
+const shortcutsVersion = 1
+
+// shortcutRequest is the optional JSON document accepted on stdin. Plain
+// newline-separated paths (what Shortcuts sends for "Pass input: to stdin")
+// are accepted too.
+type shortcutRequest struct {
+	Paths []string `json:"paths"`
+	Name  string   `json:"name,omitempty"`
+}
+
+type shortcutResult struct {
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	NewPath     string `json:"new_path,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type shortcutResponse struct {
+	Version int              `json:"version"`
+	Action  string           `json:"action"`
+	Results []shortcutResult `json:"results"`
+}
+
+var shortcutName string
+
+var shortcutsCmd = &cobra.Command{
+	Use:   "shortcuts <describe|suggest|rename> [file...]",
+	Short: "Non-interactive JSON interface for the macOS Shortcuts app",
+	Long: `Runs a single action on the given files and prints one JSON document to stdout.
+
+Files are taken from the arguments, or from stdin when no arguments are given
+(either newline-separated paths or {"paths": [...], "name": "..."}).
+
+  describe  describe each image
+  suggest   describe each image and suggest a file name
+  rename    suggest a name (or use --name) and rename the file
+
+Per-file failures are reported in the "error" field; the exit status is only
+non-zero when the request itself is invalid.`,
+	ValidArgs: []string{"describe", "suggest", "rename"},
+	Args:      cobra.MatchAll(cobra.MinimumNArgs(1), validShortcutAction),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		action, paths := args[0], args[1:]
+		name := shortcutName
+		if len(paths) == 0 {
+			req, err := readShortcutRequest(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			paths = req.Paths
+			if name == "" {
+				name = req.Name
+			}
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no files given")
+		}
+		// stdout is reserved for the JSON document, and a shortcut can't
+		// be asked anything: a taken name gets a number as under --yes.
+		msgOut = os.Stderr
+		if onConflict == "prompt" {
+			onConflict = "suffix"
+		}
+		if err := prepareNaming(cmd); err != nil {
+			return err
+		}
+		catchInterrupts()
+
+		resp := shortcutResponse{Version: shortcutsVersion, Action: action, Results: []shortcutResult{}}
+		for _, path := range paths {
+			resp.Results = append(resp.Results, runShortcut(action, path, name))
+		}
+
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	},
+}
+
+func init() {
+	shortcutsCmd.Flags().StringVar(&shortcutName, "name", "", "use this name for rename instead of asking the model")
+	rootCmd.AddCommand(shortcutsCmd)
+}
+
+func validShortcutAction(cmd *cobra.Command, args []string) error {
+	for _, a := range cmd.ValidArgs {
+		if args[0] == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown action %q (want describe, suggest or rename)", args[0])
+}
+
+func readShortcutRequest(r io.Reader) (shortcutRequest, error) {
+	var req shortcutRequest
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return req, err
+	}
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "{") {
+		if err := json.Unmarshal([]byte(text), &req); err != nil {
+			return req, fmt.Errorf("invalid JSON request: %v", err)
+		}
+		return req, nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			req.Paths = append(req.Paths, line)
+		}
+	}
+	return req, scanner.Err()
+}
+
+func runShortcut(action, path, name string) shortcutResult {
+	res := shortcutResult{Path: path}
+	if _, err := os.Stat(path); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	if action == "rename" && name != "" {
+		res.Name = sanitizeFileName(name)
+	} else {
+		description, err := describeFile(path)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		res.Description = description
+		if action == "describe" {
+			return res
+		}
+		suggestion, err := suggestName(path, description)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		if suggestion, err = finalName(path, suggestion); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		res.Name = sanitizeFileName(suggestion)
+	}
+	if action != "rename" {
+		return res
+	}
+
+	newPath, err := renameFile(path, res.Name)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.NewPath = newPath
+	return res
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sidecarFormat is --sidecar: the kind of description file written next
+// to each renamed file, if any.
+var sidecarFormat = sidecarFlag("")
+
+func init() {
+	rootCmd.PersistentFlags().Var(&sidecarFormat, "sidecar", "after renaming, write the description, tags, model and original name to <new name>.json, .txt or .md next to the file")
+}
+
+type sidecarFlag string
+
+func (f *sidecarFlag) Set(s string) error {
+	switch s {
+	case "", "json", "txt", "md":
+		*f = sidecarFlag(s)
+		return nil
+	}
+	return fmt.Errorf("unknown sidecar format %q (want json, txt or md)", s)
+}
+
+func (f *sidecarFlag) String() string { return string(*f) }
+
+func (f *sidecarFlag) Type() string { return "format" }
+
+// sidecarRecord is what a sidecar says about the file it sits next to.
+type sidecarRecord struct {
+	File        string    `json:"file"`
+	Original    string    `json:"original"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	Confidence  *float64  `json:"confidence,omitempty"`
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model,omitempty"`
+	RenamedAt   time.Time `json:"renamed_at"`
+}
+
+// writeSidecar writes the --sidecar file for s, which has just been
+// renamed to newPath. A file already there by that name is left alone.
+func writeSidecar(newPath string, s suggestion) error {
+	if sidecarFormat == "" {
+		return nil
+	}
+	r := sidecarRecord{
+		File:        filepath.Base(newPath),
+		Original:    filepath.Base(s.path),
+		Description: s.description,
+		Tags:        s.tags,
+		Category:    s.category,
+		Provider:    string(providerName),
+		Model:       namingTraceFor(s.path).Model,
+		RenamedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	if s.rated {
+		r.Confidence = &s.confidence
+	}
+
+	var body string
+	switch sidecarFormat {
+	case "json":
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		body = string(data) + "\n"
+	case "txt":
+		body = sidecarText(r)
+	case "md":
+		body = sidecarMarkdown(r)
+	}
+
+	path := strings.TrimSuffix(newPath, filepath.Ext(newPath)) + "." + string(sidecarFormat)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		fmt.Fprintf(msgOut, "Not writing %s: it already exists\n", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func sidecarText(r sidecarRecord) string {
+	var b strings.Builder
+	if r.Description != "" {
+		b.WriteString(r.Description + "\n\n")
+	}
+	if len(r.Tags) > 0 {
+		b.WriteString("Tags: " + strings.Join(r.Tags, ", ") + "\n")
+	}
+	if r.Category != "" {
+		b.WriteString("Category: " + r.Category + "\n")
+	}
+	b.WriteString("Original name: " + r.Original + "\n")
+	b.WriteString("Named by: " + sidecarNamer(r) + " on " + r.RenamedAt.Format(time.RFC3339) + "\n")
+	return b.String()
+}
+
+// sidecarMarkdown puts the details in YAML front matter, where note apps
+// such as Obsidian pick up tags, and embeds the file above its
+// description.
+func sidecarMarkdown(r sidecarRecord) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("original: " + strconv.Quote(r.Original) + "\n")
+	if len(r.Tags) > 0 {
+		quoted := make([]string, len(r.Tags))
+		for i, t := range r.Tags {
+			quoted[i] = strconv.Quote(t)
+		}
+		b.WriteString("tags: [" + strings.Join(quoted, ", ") + "]\n")
+	}
+	if r.Category != "" {
+		b.WriteString("category: " + strconv.Quote(r.Category) + "\n")
+	}
+	b.WriteString("named_by: " + strconv.Quote(sidecarNamer(r)) + "\n")
+	b.WriteString("renamed_at: " + r.RenamedAt.Format(time.RFC3339) + "\n")
+	b.WriteString("---\n\n")
+	if isImageFile(r.File) {
+		b.WriteString("![](<" + r.File + ">)\n\n")
+	} else {
+		b.WriteString("[" + r.File + "](<" + r.File + ">)\n\n")
+	}
+	if r.Description != "" {
+		b.WriteString(r.Description + "\n")
+	}
+	return b.String()
+}
+
+func sidecarNamer(r sidecarRecord) string {
+	if r.Model == "" {
+		return r.Provider
+	}
+	return r.Provider + " (" + r.Model + ")"
+}
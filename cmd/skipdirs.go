@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// defaultSkipDirs are directories that never hold screenshots worth naming
+// but can hold hundreds of thousands of files. Entries with a slash match
+// the end of the path, the rest match a single directory name.
+var defaultSkipDirs = []string{
+	".git",
+	".hg",
+	".svn",
+	"node_modules",
+	"vendor",
+	".venv",
+	"venv",
+	"__pycache__",
+	".cache",
+	".Trash",
+	".Trashes",
+	"$RECYCLE.BIN",
+	"Library/Caches",
+	".local/share/Trash",
+}
+
+var noDefaultSkips bool
+
+func init() {
+	rootCmd.Flags().BoolVar(&noDefaultSkips, "no-default-skips", false, "also walk into node_modules, .git, caches, trash and similar directories")
+}
+
+// skipDir reports whether the walk should not descend into path. The
+// directory the user pointed at is never skipped.
+func skipDir(root, path string) bool {
+	if noDefaultSkips || filepath.Clean(path) == filepath.Clean(root) {
+		return false
+	}
+	slashed := filepath.ToSlash(path)
+	base := filepath.Base(path)
+	for _, skip := range defaultSkipDirs {
+		if strings.Contains(skip, "/") {
+			if strings.HasSuffix(slashed, "/"+skip) {
+				return true
+			}
+		} else if base == skip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	keychainService = "tell-me-more"
+	keychainAccount = "state-key"
+)
+
+// stateMagic starts every encrypted state file; files without it are
+// plain JSON from before encryption and are encrypted on their next save.
+var stateMagic = []byte("TMM1")
+
+var (
+	stateKeyOnce sync.Once
+	stateKeyVal  []byte
+	stateKeyErr  error
+)
+
+var allowPlaintextState bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&allowPlaintextState, "allow-plaintext-state", false, "store state unencrypted when there is no TELL_ME_MORE_STATE_KEY and no keychain, instead of refusing to write it")
+}
+
+// dataDir is where persistent state lives: $XDG_DATA_HOME/tell-me-more,
+// falling back to ~/.local/share/tell-me-more.
+func dataDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(base, "tell-me-more")
+	return dir, os.MkdirAll(dir, 0o700)
+}
+
+// loadState decodes the named state file into v. A missing file leaves v
+// untouched and is not an error.
+func loadState(name string, v any) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if data, err = openState(data); err != nil {
+		return fmt.Errorf("reading %s: %v", name, err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveState writes v to the named state file, replacing it atomically so
+// an interrupted run never leaves a truncated file behind.
+func saveState(name string, v any) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = sealState(data); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// removeState deletes the named state file, if there is one.
+func removeState(name string) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// stateKey is the AES-256 key state files are encrypted with. It comes from
+// $TELL_ME_MORE_STATE_KEY (64 hex digits) or the OS keychain. When neither
+// is available it is an error, unless --allow-plaintext-state is set, in
+// which case the key is nil and state is stored unencrypted.
+func stateKey() ([]byte, error) {
+	stateKeyOnce.Do(func() {
+		var err error
+		if env := os.Getenv("TELL_ME_MORE_STATE_KEY"); env != "" {
+			stateKeyVal, err = hex.DecodeString(env)
+		} else {
+			stateKeyVal, err = keychainKey()
+		}
+		if err == nil && len(stateKeyVal) != 32 {
+			err = fmt.Errorf("the key must be 32 bytes, got %d", len(stateKeyVal))
+		}
+		if err != nil {
+			stateKeyVal = nil
+			if allowPlaintextState {
+				log.Printf("No state encryption key (%v); state is stored unencrypted.", err)
+			} else {
+				stateKeyErr = fmt.Errorf("no state encryption key (%v); set TELL_ME_MORE_STATE_KEY to 64 hex digits, or pass --allow-plaintext-state to store state unencrypted", err)
+			}
+		}
+	})
+	return stateKeyVal, stateKeyErr
+}
+
+// validateStateKey stops a run before it renames anything whose history
+// it couldn't store.
+func validateStateKey() error {
+	_, err := stateKey()
+	return err
+}
+
+func newStateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+func stateCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealState encrypts data with AES-GCM under a fresh nonce.
+func sealState(data []byte) ([]byte, error) {
+	key, err := stateKey()
+	if key == nil {
+		return data, err
+	}
+	aead, err := stateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, stateMagic...), nonce...)
+	return aead.Seal(out, nonce, data, stateMagic), nil
+}
+
+func openState(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, stateMagic) {
+		return data, nil
+	}
+	key, err := stateKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("the file is encrypted and no key is available")
+	}
+	aead, err := stateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(stateMagic):]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("the file is truncated")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], stateMagic)
+	if err != nil {
+		return nil, errors.New("the file cannot be decrypted with the current key")
+	}
+	return plain, nil
+}
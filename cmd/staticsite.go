@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+)
+
+var noSiteRefs bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noSiteRefs, "no-site-refs", false, "don't update front matter and content of the Hugo or Jekyll site a renamed file belongs to")
+}
+
+// siteGlobs are the files of a Hugo or Jekyll site that carry front matter
+// (cover:, images:, image:) or shortcodes and includes pointing at images.
+var siteGlobs = []string{"*.md", "*.markdown", "*.html"}
+
+// siteMarkers identify the root of a site by its configuration file.
+var siteMarkers = map[string][]string{
+	"hugo":   {"hugo.toml", "hugo.yaml", "hugo.json", "config.toml", "config.yaml"},
+	"jekyll": {"_config.yml", "_config.yaml"},
+}
+
+// siteOutputDirs are generated and rebuilt from the sources, so they are
+// not rewritten.
+var siteOutputDirs = map[string][]string{
+	"hugo":   {"public", "resources"},
+	"jekyll": {"_site", ".jekyll-cache"},
+}
+
+// bundleIndexes mark a Hugo page bundle: the content file and its images
+// live in one directory.
+var bundleIndexes = []string{"index.md", "_index.md", "index.html", "_index.html"}
+
+// staticSiteScope returns the sources to rewrite for an image in a Hugo or
+// Jekyll site. An image in a page bundle is only referenced by the bundle's
+// own content files; anything else, like a file under static/ or assets/,
+// can be used from any page.
+func staticSiteScope(path string) refScope {
+	root, kind := staticSite(path)
+	if root == "" {
+		return refScope{}
+	}
+	if kind == "hugo" {
+		dir := filepath.Dir(path)
+		for _, index := range bundleIndexes {
+			if _, err := os.Stat(filepath.Join(dir, index)); err == nil {
+				return refScope{root: dir, globs: siteGlobs}
+			}
+		}
+		// Hugo serves static/ from / and finds resources in assets/ by
+		// their path inside it.
+		return refScope{root: root, globs: siteGlobs, skip: siteOutputDirs[kind],
+			roots: []string{filepath.Join(root, "static"), filepath.Join(root, "assets")}, rootRelative: true}
+	}
+	return refScope{root: root, globs: siteGlobs, skip: siteOutputDirs[kind], roots: []string{root}}
+}
+
+// staticSite returns the closest directory above path that is the root of
+// a Hugo or Jekyll site, and which of the two it is. A bare config.toml is
+// only taken for Hugo when there is a content directory next to it.
+func staticSite(path string) (string, string) {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		for _, kind := range []string{"hugo", "jekyll"} {
+			for _, marker := range siteMarkers[kind] {
+				if _, err := os.Stat(filepath.Join(dir, marker)); err != nil {
+					continue
+				}
+				if kind == "hugo" {
+					if info, err := os.Stat(filepath.Join(dir, "content")); err != nil || !info.IsDir() {
+						continue
+					}
+				}
+				return dir, kind
+			}
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return "", ""
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// namingResult is the JSON object the naming prompts ask for.
+type namingResult struct {
+	Name string `json:"name"`
+	// Description is only asked for when the same request looks at the
+	// image, as the single-call pipelines do.
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags"`
+	Category    string   `json:"category"`
+	// Confidence is 0-1, or nil when the reply gave none.
+	Confidence *float64 `json:"confidence"`
+}
+
+// namingCategories are the categories a file can be put in, which
+// --categories replaces.
+var namingCategories = []string{"screenshot", "photo", "document", "receipt", "diagram", "chart", "code", "chat", "meme", "artwork", "ai-art", "audio", "archive", "other"}
+
+func init() {
+	rootCmd.PersistentFlags().StringSliceVar(&namingCategories, "categories", namingCategories, "categories the model sorts each file into, for organize and the JSON output")
+}
+
+// namingJSONIntro starts the reply format added to every naming prompt.
+// Requests are built from the prompt alone, for the cache and --batch
+// alike, so this is also how they tell that a prompt wants the schema.
+const namingJSONIntro = "Reply with a JSON object only, with these keys:"
+
+// namingJSONFormat tells the model how to answer a naming prompt, with
+// the one-sentence description too when withDescription is set.
+func namingJSONFormat(withDescription bool) string {
+	keys := `
+"name": the filename, without extension`
+	if withDescription {
+		keys += `
+"description": one sentence describing the image`
+	}
+	return "\n\n" + namingJSONIntro + keys + `
+"tags": 3 to 8 short lowercase keywords someone would search for
+"category": one of ` + strings.Join(namingCategories, ", ") + `
+"confidence": from 0 to 1, how sure you are that the name says specifically and correctly what the file is`
+}
+
+// wantsNamingJSON reports whether prompt asks for a namingResult.
+func wantsNamingJSON(prompt string) bool {
+	return strings.Contains(prompt, namingJSONIntro)
+}
+
+func withDescriptionKey(prompt string) bool {
+	return strings.Contains(prompt, `"description": one sentence`)
+}
+
+// namingSchema is the JSON schema of namingResult for OpenAI's strict
+// structured outputs, which want every key required.
+func namingSchema(withDescription bool) *jsonschema.Definition {
+	props := map[string]jsonschema.Definition{
+		"name":       {Type: jsonschema.String},
+		"tags":       {Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String}},
+		"category":   {Type: jsonschema.String, Enum: namingCategories},
+		"confidence": {Type: jsonschema.Number},
+	}
+	required := []string{"name", "tags", "category", "confidence"}
+	if withDescription {
+		props["description"] = jsonschema.Definition{Type: jsonschema.String}
+		required = append(required, "description")
+	}
+	return &jsonschema.Definition{Type: jsonschema.Object, Properties: props, Required: required, AdditionalProperties: false}
+}
+
+// openaiResponseFormat is the response format to request from model for
+// prompt: the schema where the model supports structured outputs, plain
+// JSON mode where it only has that, and nil otherwise, leaving it to the
+// prompt.
+func openaiResponseFormat(model, prompt string) *openai.ChatCompletionResponseFormat {
+	if !wantsNamingJSON(prompt) {
+		return nil
+	}
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-5", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+				JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+					Name:   "file_name",
+					Schema: namingSchema(withDescriptionKey(prompt)),
+					Strict: true,
+				},
+			}
+		}
+	}
+	for _, prefix := range []string{"gpt-4-turbo", "gpt-4-1106", "gpt-4-0125", "gpt-3.5-turbo"} {
+		if strings.HasPrefix(model, prefix) {
+			return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+		}
+	}
+	return nil
+}
+
+// geminiModelFor is the Gemini model to send prompt to, told to answer
+// with a namingResult when prompt wants one.
+func geminiModelFor(client *genai.Client, prompt string) *genai.GenerativeModel {
+	model := client.GenerativeModel(geminiModel)
+	if wantsNamingJSON(prompt) {
+		str := &genai.Schema{Type: genai.TypeString}
+		schema := &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"name":       str,
+				"tags":       {Type: genai.TypeArray, Items: str},
+				"category":   {Type: genai.TypeString, Format: "enum", Enum: namingCategories},
+				"confidence": {Type: genai.TypeNumber},
+			},
+			Required: []string{"name", "tags", "category", "confidence"},
+		}
+		if withDescriptionKey(prompt) {
+			schema.Properties["description"] = str
+			schema.Required = append(schema.Required, "description")
+		}
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = schema
+	}
+	return model
+}
+
+// parseNaming reads a reply to a naming prompt. Models without JSON mode
+// may wrap the object in prose or a code fence, and a reply that is not
+// JSON at all is taken as a bare name, as replies used to be. What was
+// recorded about the reply is carried over to the name.
+func parseNaming(reply, source string) (namingResult, error) {
+	var r namingResult
+	reply = strings.TrimSpace(reply)
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start || json.Unmarshal([]byte(reply[start:end+1]), &r) != nil {
+		r = namingResult{Name: reply}
+	}
+	r.Name = strings.Trim(strings.TrimSpace(r.Name), "`'\"")
+	if i := strings.IndexByte(r.Name, '\n'); i >= 0 {
+		r.Name = strings.TrimSpace(r.Name[:i])
+	}
+	if r.Name == "" {
+		return namingResult{}, fmt.Errorf("no file name in %s reply", source)
+	}
+	r.Description = strings.TrimSpace(r.Description)
+	r.Tags = parseTags(strings.Join(r.Tags, ","))
+	r.Category = strings.ToLower(strings.TrimSpace(r.Category))
+	if c := r.Confidence; c != nil {
+		// Some models answer in percent despite being asked for 0 to 1.
+		if *c > 1 {
+			*c /= 100
+		}
+		*c = min(max(*c, 0), 1)
+	}
+	// The audit trail and the cost report look names up by the reply
+	// they came from.
+	if t, ok := replyTraces.Load(reply); ok {
+		replyTraces.Store(r.Name, t)
+	}
+	moveUsage(reply, r.Name)
+	return r, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// getCaptionAndTags turns a description into a one-sentence caption and a
+// handful of search keywords, for photo libraries that store them.
+func getCaptionAndTags(description string) (string, []string, error) {
+	prompt := fmt.Sprintf(`Here is a description of a photo:
+%s
+
+Reply with exactly two lines and nothing else:
+Caption: one plain sentence saying what the photo shows
+Tags: 3 to 8 short lowercase keywords someone would search for, comma-separated`, description) + langNameHint()
+	reply, err := askChatGPT(prompt)
+	if err != nil {
+		return "", nil, err
+	}
+	var caption string
+	var tags []string
+	for _, line := range strings.Split(reply, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "caption":
+			caption = strings.Trim(strings.TrimSpace(value), "\"")
+		case "tags":
+			tags = parseTags(value)
+		}
+	}
+	if caption == "" {
+		return "", nil, fmt.Errorf("no caption in the reply")
+	}
+	return caption, tags, nil
+}
+
+func parseTags(list string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.ToLower(strings.Trim(strings.TrimSpace(tag), "\"'#."))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
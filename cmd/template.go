@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// maxNameLength caps the sanitized name before any prefix or suffix, in
+// case the model ignores the length it was asked for.
+const maxNameLength = 80
+
+var (
+	nameTemplateText string
+	namePrefixText   string
+	nameSuffixText   string
+
+	keepOriginal string
+
+	// datePrefix is the time layout of --date-prefix, empty without it.
+	datePrefix string
+
+	nameTemplate   *template.Template
+	prefixTemplate *template.Template
+	suffixTemplate *template.Template
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&nameTemplateText, "template", "{{.Name}}", "Go template for new names, e.g. \"{{.Author}}_{{.Title}}\"")
+	rootCmd.PersistentFlags().StringVar(&namePrefixText, "prefix", "", "text or template put in front of every new name, e.g. \"proj42_\"")
+	rootCmd.PersistentFlags().StringVar(&nameSuffixText, "suffix", "", "text or template put after every new name, e.g. \"_{{.Date}}\"")
+	rootCmd.PersistentFlags().StringVar(&keepOriginal, "keep-original", "", "keep the original file name in the new one: prefix or suffix")
+	rootCmd.PersistentFlags().StringVar(&datePrefix, "date-prefix", "", "start every new name with the capture date (EXIF, falling back to the modification time) in this Go time layout")
+	rootCmd.PersistentFlags().Lookup("date-prefix").NoOptDefVal = "2006-01-02"
+}
+
+// originalSeparator joins the new name and the kept original one.
+const originalSeparator = "__"
+
+// nameFields are the values a --template can refer to.
+type nameFields struct {
+	// Name is the suggestion from the model, or from metadata when the file
+	// type has enough of it.
+	Name string
+	// Original is the file name being replaced, without extension.
+	Original string
+	Ext      string
+
+	// Title and Author come from document metadata such as an EPUB's OPF.
+	Title  string
+	Author string
+
+	// Place is the city the photo's EXIF GPS position resolves to.
+	Place string
+
+	// Date is the capture date as YYYY-MM-DD; Time is the full capture time
+	// for custom layouts such as {{.Time.Format "2006-01"}}.
+	Date string
+	Time time.Time
+
+	// Seq is the file's zero-padded position in --series mode.
+	Seq string
+
+	// People lists the labelled faces in the photo, e.g. "mum_and_alex".
+	People string
+
+	// CaptureTime is the timestamp in a macOS screenshot's name, as
+	// 2024-05-03_10-11-12; Window is the captured window or app, when the
+	// capture tool recorded it.
+	CaptureTime string
+	Window      string
+}
+
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// loadTemplate compiles --template, --prefix and --suffix. It must run
+// before any naming.
+func loadTemplate() error {
+	var err error
+	if nameTemplate, err = parseNameTemplate("--template", nameTemplateText); err != nil {
+		return err
+	}
+	if prefixTemplate, err = parseNameTemplate("--prefix", namePrefixText); err != nil {
+		return err
+	}
+	if suffixTemplate, err = parseNameTemplate("--suffix", nameSuffixText); err != nil {
+		return err
+	}
+	switch keepOriginal {
+	case "", "prefix", "suffix":
+	default:
+		return fmt.Errorf("unknown --keep-original value %q (want prefix or suffix)", keepOriginal)
+	}
+	if datePrefix != "" && time.Now().Format(datePrefix) == datePrefix {
+		return fmt.Errorf("--date-prefix %q is not a Go time layout such as 2006-01-02", datePrefix)
+	}
+	_, err = captureZone()
+	return err
+}
+
+func parseNameTemplate(flag, text string) (*template.Template, error) {
+	t, err := template.New(flag).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", flag, err)
+	}
+	return t, nil
+}
+
+// templateFields gathers everything known about path for the template.
+func templateFields(path, name string) nameFields {
+	ext := filepath.Ext(path)
+	f := nameFields{
+		Name:     name,
+		Original: strings.TrimSuffix(filepath.Base(path), ext),
+		Ext:      strings.TrimPrefix(ext, "."),
+	}
+	if templateUses("Seq") {
+		f.Seq = seriesNumber(path)
+	}
+	if templateUses("Date") || templateUses("Time") {
+		if t, _ := captureTime(path); !t.IsZero() {
+			f.Time, f.Date = t, t.Format("2006-01-02")
+		}
+	}
+	if templateUses("People") {
+		f.People = photoPeople(path)
+	}
+	if templateUses("CaptureTime") {
+		if loc, err := captureZone(); err == nil {
+			if t, ok := screenshotTime(filepath.Base(path), loc); ok {
+				f.CaptureTime = t.Format("2006-01-02_15-04-05")
+			}
+		}
+	}
+	if templateUses("Window") {
+		f.Window = screenshotWindow(path)
+	}
+	if templateUses("Place") {
+		f.Place = photoPlace(path)
+	}
+	if isEPUBFile(path) {
+		if meta, err := readEPUBMetadata(path); err == nil {
+			f.Title, f.Author = meta.Title, meta.Author
+		}
+	}
+	return f
+}
+
+// finalName renders the template for path and its suggested name, then
+// sanitizes and shortens it and adds the prefix and suffix, which are
+// sanitized on their own so they are never cut off.
+func finalName(path, name string) (string, error) {
+	if nameTemplate == nil {
+		return name, nil
+	}
+	fields := templateFields(path, name)
+	body, err := renderName(nameTemplate, fields)
+	if err != nil {
+		return "", err
+	}
+	prefix, err := renderName(prefixTemplate, fields)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := renderName(suffixTemplate, fields)
+	if err != nil {
+		return "", err
+	}
+	if datePrefix != "" {
+		if t, _ := captureTime(path); !t.IsZero() {
+			prefix = t.Format(datePrefix) + "_" + prefix
+		}
+	}
+	body = truncateName(sanitizeFileName(body), maxNameLength)
+	switch original := keptOriginal(fields.Original); keepOriginal {
+	case "prefix":
+		body = original + originalSeparator + body
+	case "suffix":
+		body = body + originalSeparator + original
+	}
+	return sanitizeFileName(prefix) + body + sanitizeFileName(suffix), nil
+}
+
+// keptOriginal returns the part of an original name worth keeping: for a
+// file renamed before with --keep-original, only the name it started with.
+func keptOriginal(original string) string {
+	clean := sanitizeFileName(original)
+	switch keepOriginal {
+	case "prefix":
+		if i := strings.Index(clean, originalSeparator); i > 0 {
+			return clean[:i]
+		}
+	case "suffix":
+		if i := strings.LastIndex(clean, originalSeparator); i >= 0 && i+len(originalSeparator) < len(clean) {
+			return clean[i+len(originalSeparator):]
+		}
+	}
+	return clean
+}
+
+func renderName(t *template.Template, fields nameFields) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, fields); err != nil {
+		return "", fmt.Errorf("rendering %s: %v", t.Name(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// truncateName shortens a sanitized name to at most max bytes, preferring
+// to cut at a word boundary.
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	cut := name[:max]
+	if i := strings.LastIndexAny(cut, "_-"); i > max/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, "_-")
+}
+
+// templateUses reports whether --template, --prefix or --suffix refer to
+// field, so expensive lookups like geocoding only happen when their result
+// is wanted.
+func templateUses(field string) bool {
+	for _, text := range []string{nameTemplateText, namePrefixText, nameSuffixText} {
+		if strings.Contains(text, "."+field) {
+			return true
+		}
+	}
+	return false
+}
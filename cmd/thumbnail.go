@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+var useThumbnail bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&useThumbnail, "use-thumbnail", false, "describe photos from their embedded EXIF thumbnail instead of uploading the full image")
+}
+
+// exifThumbnail returns the JPEG preview a camera or phone embedded in the
+// photo's EXIF data, if there is one. Only JPEGs carry one in practice.
+func exifThumbnail(path string) ([]byte, bool) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".jpg" && ext != ".jpeg" {
+		return nil, false
+	}
+	x, err := readEXIF(path)
+	if err != nil {
+		return nil, false
+	}
+	thumb, err := x.JpegThumbnail()
+	if err != nil || !bytes.HasPrefix(thumb, []byte{0xff, 0xd8}) {
+		return nil, false
+	}
+	return thumb, true
+}
+
+// describeThumbnail describes path from its embedded thumbnail, reporting
+// false when there is none so the caller can fall back to the full image.
+func describeThumbnail(path string) (string, bool, error) {
+	thumb, ok := exifThumbnail(path)
+	if !ok {
+		log.Printf("No EXIF thumbnail in %s, using the full image", path)
+		return "", false, nil
+	}
+	description, err := describeImageBytes(thumb, "image/jpeg")
+	return description, true, err
+}
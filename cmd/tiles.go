@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	tiling   string
+	maxTiles int
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&tiling, "tiles", "auto", "split very tall or wide screenshots into tiles described one by one: auto or off")
+	rootCmd.PersistentFlags().IntVar(&maxTiles, "max-tiles", 6, "most tiles one image is split into")
+}
+
+const (
+	// tileAspect is how much longer than wide (or wider than tall) an image
+	// has to be before it is tiled: full-page captures and screenshots
+	// spanning several monitors, which would be shrunk until their text is
+	// unreadable.
+	tileAspect = 2.5
+	// tileOverlap is the share of a tile repeated in the next one, so a
+	// line of text on a boundary is whole in at least one of them.
+	tileOverlap = 0.05
+)
+
+// tileRects splits bounds along its long side into tiles about 1.5 times
+// as long as the short side, at most maxTiles of them. It returns nil when
+// the image isn't long enough to need tiling.
+func tileRects(bounds image.Rectangle) []image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	tall := h >= w
+	long, short := h, w
+	if !tall {
+		long, short = w, h
+	}
+	if short == 0 || float64(long)/float64(short) < tileAspect {
+		return nil
+	}
+	n := min(max(2, (long*2+short*3-1)/(short*3)), maxTiles)
+	size := long / n
+	overlap := int(float64(size) * tileOverlap)
+
+	rects := make([]image.Rectangle, n)
+	for i := range rects {
+		start := max(0, i*size-overlap)
+		end := min(long, (i+1)*size+overlap)
+		if i == n-1 {
+			end = long
+		}
+		if tall {
+			rects[i] = image.Rect(bounds.Min.X, bounds.Min.Y+start, bounds.Max.X, bounds.Min.Y+end)
+		} else {
+			rects[i] = image.Rect(bounds.Min.X+start, bounds.Min.Y, bounds.Min.X+end, bounds.Max.Y)
+		}
+	}
+	return rects
+}
+
+// describeTiled describes a very tall or wide image tile by tile and joins
+// the parts into one description, reporting false when the image doesn't
+// need tiling so the caller describes it whole.
+func describeTiled(path string) (string, bool, error) {
+	if tiling == "off" || maxTiles < 2 {
+		return "", false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, nil
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil || tileRects(image.Rect(0, 0, cfg.Width, cfg.Height)) == nil {
+		f.Close()
+		return "", false, nil
+	}
+	f.Seek(0, 0)
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return "", false, nil
+	}
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return "", false, nil
+	}
+
+	rects := tileRects(img.Bounds())
+	across := "top to bottom"
+	if img.Bounds().Dx() > img.Bounds().Dy() {
+		across = "left to right"
+	}
+	log.Printf("%s is %dx%d, describing it in %d tiles", path, cfg.Width, cfg.Height, len(rects))
+
+	var parts []string
+	for i, r := range rects {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, sub.SubImage(r)); err != nil {
+			return "", true, err
+		}
+		prompt := fmt.Sprintf("This is part %d of %d of one very long screenshot, split %s. Describe what this part shows in as much detail as possible, including any text that matters.", i+1, len(rects), across) + langPrompt()
+		if i == 0 {
+			prompt += webpagePrompt
+		}
+		text, err := askGeminiAboutImage(buf.Bytes(), "image/png", prompt)
+		if err != nil {
+			return "", true, fmt.Errorf("describing part %d of %s: %v", i+1, path, err)
+		}
+		parts = append(parts, fmt.Sprintf("Part %d of %d:\n%s", i+1, len(rects), strings.TrimSpace(text)))
+	}
+	return fmt.Sprintf("A screenshot too long to view at once, described in %d parts from %s.\n\n%s", len(rects), across, strings.Join(parts, "\n\n")), true, nil
+}
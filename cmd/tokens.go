@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// showCost prints what each file and the whole run cost in tokens
+	// and dollars.
+	showCost bool
+
+	// maxCost and maxTokens stop the run once it has spent this much; 0
+	// for no limit.
+	maxCost   float64
+	maxTokens int
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&showCost, "cost", false, "show the tokens used and approximate cost of each file and of the whole run")
+	rootCmd.PersistentFlags().Float64Var(&maxCost, "max-cost", 0, "stop the run, so it can be resumed, once it has cost this many dollars")
+	rootCmd.PersistentFlags().IntVar(&maxTokens, "max-tokens", 0, "stop the run, so it can be resumed, once it has used this many tokens")
+}
+
+// modelPrices are list prices in dollars per million input and output
+// tokens, matched against the model a reply names by prefix, so the
+// more specific names come first.
+var modelPrices = []struct {
+	prefix     string
+	input, out float64
+}{
+	{"gpt-4o-mini", 0.15, 0.60},
+	{"gpt-4o", 2.50, 10},
+	{"gpt-4-turbo", 10, 30},
+	{"gpt-4", 30, 60},
+	{"gpt-3.5-turbo", 0.50, 1.50},
+	{"gemini-1.5-flash-8b", 0.0375, 0.15},
+	{"gemini-1.5-flash", 0.075, 0.30},
+	{"gemini-1.5-pro", 1.25, 5},
+	{"gemini-2.0-flash", 0.10, 0.40},
+	{"claude-3-5-haiku", 0.80, 4},
+	{"claude-3-5-sonnet", 3, 15},
+	{"claude-3-opus", 15, 75},
+	{"claude-3-haiku", 0.25, 1.25},
+	{"text-embedding-3-small", 0.02, 0},
+	{"text-embedding-3-large", 0.13, 0},
+	{"ollama/", 0, 0}, // runs locally
+}
+
+// tokenUsage is what one or more requests used. unpriced is set when a
+// model without a known price was among them, so cost is a lower bound.
+type tokenUsage struct {
+	input, output int
+	cost          float64
+	unpriced      bool
+}
+
+func (u *tokenUsage) add(v tokenUsage) {
+	u.input += v.input
+	u.output += v.output
+	u.cost += v.cost
+	u.unpriced = u.unpriced || v.unpriced
+}
+
+func (u tokenUsage) String() string {
+	cost := fmt.Sprintf("$%.4f", u.cost)
+	if u.unpriced {
+		cost = "at least " + cost
+	}
+	return fmt.Sprintf("%d tokens in, %d out, %s", u.input, u.output, cost)
+}
+
+func priceUsage(model string, input, output int) tokenUsage {
+	u := tokenUsage{input: input, output: output}
+	for _, p := range modelPrices {
+		if strings.HasPrefix(model, p.prefix) {
+			u.cost = (float64(input)*p.input + float64(output)*p.out) / 1e6
+			return u
+		}
+	}
+	u.unpriced = input+output > 0
+	return u
+}
+
+var (
+	// replyUsage maps a model reply to what the request for it used,
+	// until a file is charged with it, and fileUsage a file to its total.
+	replyUsage sync.Map
+	fileUsage  sync.Map
+
+	runUsageMu sync.Mutex
+	runUsage   = map[string]*tokenUsage{}
+	budgetOnce sync.Once
+)
+
+// recordUsage notes the tokens model used for reply, for the file it
+// turns out to be about and for the run's total.
+func recordUsage(reply, model string, input, output int) {
+	addUsage(reply, model, priceUsage(model, input, output))
+}
+
+// recordBatchUsage is recordUsage for a reply from the OpenAI Batch API,
+// which costs half as much.
+func recordBatchUsage(reply, model string, input, output int) {
+	u := priceUsage(model, input, output)
+	u.cost /= 2
+	addUsage(reply, model, u)
+}
+
+func addUsage(reply, model string, u tokenUsage) {
+	replyUsage.Store(reply, u)
+	runUsageMu.Lock()
+	defer runUsageMu.Unlock()
+	total := runUsage[model]
+	if total == nil {
+		total = &tokenUsage{}
+		runUsage[model] = total
+	}
+	total.add(u)
+	checkBudget()
+}
+
+// checkBudget stops the run once --max-cost or --max-tokens is used up.
+// Requests already in flight still finish, so a run can go over by a few
+// of them; their answers are cached for the resumed run.
+func checkBudget() {
+	if maxCost <= 0 && maxTokens <= 0 {
+		return
+	}
+	var total tokenUsage
+	for _, u := range runUsage {
+		total.add(*u)
+	}
+	var reason string
+	switch {
+	case maxCost > 0 && total.cost >= maxCost:
+		reason = fmt.Sprintf("$%.2f spent, --max-cost is $%.2f", total.cost, maxCost)
+	case maxTokens > 0 && total.input+total.output >= maxTokens:
+		reason = fmt.Sprintf("%d tokens used, --max-tokens is %d", total.input+total.output, maxTokens)
+	default:
+		return
+	}
+	budgetOnce.Do(func() {
+		fmt.Fprintf(os.Stderr, "\nBudget reached (%s); stopping\n", reason)
+		// The caller may be an upload that stopRun waits to clean up.
+		go stopRun(1)
+	})
+}
+
+// moveUsage files what reply used under name, when name is what is left
+// of reply once parsed.
+func moveUsage(reply, name string) {
+	if u, ok := replyUsage.LoadAndDelete(reply); ok {
+		replyUsage.Store(name, u)
+	}
+}
+
+// chargeFile adds what the requests for replies used to path's total.
+// Each reply is charged once, and answers from the cache cost nothing.
+func chargeFile(path string, replies ...string) {
+	total := usageForFile(path)
+	for _, reply := range replies {
+		if u, ok := replyUsage.LoadAndDelete(reply); ok {
+			total.add(u.(tokenUsage))
+		}
+	}
+	fileUsage.Store(absPath(path), total)
+}
+
+// usageForFile is what has been charged to path. Calls made for more than
+// one file, such as rating the name under --triage, only count towards
+// the run's total.
+func usageForFile(path string) tokenUsage {
+	u, _ := fileUsage.Load(absPath(path))
+	total, _ := u.(tokenUsage)
+	return total
+}
+
+// printCostSummary shows the tokens and cost of the run by model.
+func printCostSummary() {
+	if !showCost {
+		return
+	}
+	runUsageMu.Lock()
+	defer runUsageMu.Unlock()
+	if len(runUsage) == 0 {
+		return
+	}
+	models := make([]string, 0, len(runUsage))
+	for m := range runUsage {
+		models = append(models, m)
+	}
+	sort.Strings(models)
+	var total tokenUsage
+	fmt.Fprintln(msgOut, "\nTokens and approximate cost:")
+	for _, m := range models {
+		u := *runUsage[m]
+		total.add(u)
+		cost := fmt.Sprintf("$%.4f", u.cost)
+		if u.unpriced {
+			cost = "unknown price"
+		}
+		fmt.Fprintf(msgOut, "  %-28s %10d in %8d out  %s\n", m, u.input, u.output, cost)
+	}
+	fmt.Fprintf(msgOut, "  %-28s %10d in %8d out  $%.4f", "total", total.input, total.output, total.cost)
+	if total.unpriced {
+		fmt.Fprint(msgOut, " (without the models of unknown price)")
+	}
+	fmt.Fprintln(msgOut)
+}
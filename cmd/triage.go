@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const quarantineFile = "quarantine.json"
+
+var (
+	triageMode      bool
+	autoApply       float64
+	quarantineBelow float64
+
+	triageMu          sync.Mutex
+	triageCounts      = map[string]int{}
+	triageQuarantined []string
+)
+
+func init() {
+	rootCmd.Flags().BoolVar(&triageMode, "triage", false, "don't ask: rename confident suggestions, queue unsure ones for review and quarantine the rest")
+	rootCmd.Flags().Float64Var(&autoApply, "auto-apply", 0.85, "with --triage, rename without asking at or above this confidence (0-1)")
+	purgers[quarantineFile] = purgeReviewItems(quarantineFile)
+	rootCmd.Flags().Float64Var(&quarantineBelow, "quarantine-below", 0.4, "with --triage, quarantine suggestions below this confidence (0-1)")
+}
+
+func validateTriage() error {
+	if !triageMode {
+		return nil
+	}
+	if autoApply < 0 || autoApply > 1 || quarantineBelow < 0 || quarantineBelow > 1 || quarantineBelow > autoApply {
+		return fmt.Errorf("need 0 <= --quarantine-below <= --auto-apply <= 1")
+	}
+	return nil
+}
+
+var confidencePattern = regexp.MustCompile(`\d{1,3}`)
+
+// rateName asks how sure the model is that name fits what the description
+// says, from 0 to 1. With no description the name was guessed from the old
+// file name, which deserves no confidence at all.
+func rateName(description, name string) (float64, error) {
+	if description == "" {
+		return 0, nil
+	}
+	prompt := fmt.Sprintf(`Here is a description of a file:
+%s
+
+The suggested file name is: %s
+
+How confident are you, from 0 to 100, that this name says specifically and correctly what the file is? Score low when the description is vague or unsure, or when the name is generic. Reply with the number only.`, description, name)
+	reply, err := askChatGPT(prompt)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(confidencePattern.FindString(reply))
+	if err != nil {
+		return 0, fmt.Errorf("no confidence score in %q", reply)
+	}
+	return float64(min(n, 100)) / 100, nil
+}
+
+// triageDecision is askDecision for --triage: y above --auto-apply, d for
+// the review queue in between and q for quarantine below
+// --quarantine-below.
+func triageDecision(s suggestion) string {
+	switch {
+	case s.confidence >= autoApply:
+		return "y"
+	case s.confidence < quarantineBelow:
+		return "q"
+	}
+	return "d"
+}
+
+// quarantine sets s aside in the quarantine list, which
+// `tell-me-more review --quarantine` goes through.
+func quarantine(s suggestion) error {
+	path, err := filepath.Abs(s.path)
+	if err != nil {
+		return err
+	}
+	var items []reviewItem
+	if err := loadState(quarantineFile, &items); err != nil {
+		return err
+	}
+	kept := items[:0]
+	for _, item := range items {
+		if item.Path != path {
+			kept = append(kept, item)
+		}
+	}
+	kept = append(kept, reviewItem{
+		Path:         path,
+		Description:  s.description,
+		Name:         s.name,
+		Alternatives: s.alternatives,
+		DeferredAt:   time.Now(),
+		namingTrace:  namingTraceFor(s.path),
+	})
+	if err := saveState(quarantineFile, kept); err != nil {
+		return err
+	}
+	triageMu.Lock()
+	triageQuarantined = append(triageQuarantined, fmt.Sprintf("%s (%.0f%%: %s)", s.path, s.confidence*100, s.name))
+	triageMu.Unlock()
+	return nil
+}
+
+func countTriage(action string) {
+	triageMu.Lock()
+	triageCounts[action]++
+	triageMu.Unlock()
+}
+
+// printTriageSummary reports how many files ended up in each bucket.
+func printTriageSummary() {
+	if !triageMode || dryRun {
+		return
+	}
+	triageMu.Lock()
+	defer triageMu.Unlock()
+	fmt.Fprintf(msgOut, "\nAuto-renamed:      %d\n", triageCounts[actionRenamed])
+	fmt.Fprintf(msgOut, "Queued for review: %d (tell-me-more review)\n", triageCounts[actionDeferred])
+	fmt.Fprintf(msgOut, "Quarantined:       %d (tell-me-more review --quarantine)\n", triageCounts[actionQuarantined])
+	for _, q := range triageQuarantined {
+		fmt.Fprintf(msgOut, "  %s\n", q)
+	}
+	if n := triageCounts[actionError]; n > 0 {
+		fmt.Fprintf(msgOut, "Failed:            %d\n", n)
+	}
+}
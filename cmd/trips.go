@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tripGap        time.Duration
+	tripHome       []float64
+	tripHomeRadius float64
+	tripOrganize   bool
+)
+
+var tripsCmd = &cobra.Command{
+	Use:   "trips <dir>...",
+	Short: "Find trips from photo GPS positions and name them after the places and dates",
+	Long: `Photos taken more than --home-radius km from home belong to a trip, and a trip
+ends when no away photo is taken for --gap. Home is --home if given, otherwise
+the place most photos were taken. Photos without a position join the trip
+they were taken during.
+
+Each trip is named from its reverse-geocoded places and dates, for example
+lisbon_porto_june_2023. With --organize the photos are moved into a folder
+of that name once you confirm.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(tripHome) != 0 && len(tripHome) != 2 {
+			return fmt.Errorf("--home wants lat,lon")
+		}
+		for _, dir := range args {
+			images, err := folderImages(dir)
+			if err != nil {
+				log.Printf("Error walking %s: %v", dir, err)
+				continue
+			}
+			for _, trip := range findTrips(images) {
+				if err := reportTrip(dir, trip); err != nil {
+					log.Printf("Error organizing trip %s: %v", trip.name, err)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	tripsCmd.Flags().DurationVar(&tripGap, "gap", 48*time.Hour, "time without away photos that ends a trip")
+	tripsCmd.Flags().Float64SliceVar(&tripHome, "home", nil, "home position as lat,lon (default: where most photos were taken)")
+	tripsCmd.Flags().Float64Var(&tripHomeRadius, "home-radius", 50, "distance in km from home that counts as away")
+	tripsCmd.Flags().BoolVar(&tripOrganize, "organize", false, "move each trip's photos into a folder named after it")
+	rootCmd.AddCommand(tripsCmd)
+}
+
+type geoPhoto struct {
+	path     string
+	t        time.Time
+	lat, lon float64
+	hasGPS   bool
+}
+
+type trip struct {
+	name       string
+	photos     []geoPhoto
+	start, end time.Time
+}
+
+func findTrips(paths []string) []trip {
+	photos := make([]geoPhoto, 0, len(paths))
+	for _, path := range paths {
+		p := geoPhoto{path: path}
+		p.t, _ = captureTime(path)
+		p.lat, p.lon, p.hasGPS = photoLocation(path)
+		photos = append(photos, p)
+	}
+	sort.SliceStable(photos, func(i, j int) bool { return photos[i].t.Before(photos[j].t) })
+
+	homeLat, homeLon, ok := tripHomePosition(photos)
+	if !ok {
+		log.Printf("No photos with GPS positions found")
+		return nil
+	}
+
+	var trips []trip
+	for _, p := range photos {
+		if !p.hasGPS || haversineKm(p.lat, p.lon, homeLat, homeLon) <= tripHomeRadius {
+			continue
+		}
+		n := len(trips)
+		if n == 0 || p.t.Sub(trips[n-1].end) > tripGap {
+			trips = append(trips, trip{start: p.t})
+			n++
+		}
+		trips[n-1].end = p.t
+	}
+	// Photos without a position belong to whatever trip they fall in.
+	for _, p := range photos {
+		for i := range trips {
+			if !p.t.Before(trips[i].start) && !p.t.After(trips[i].end) {
+				if !p.hasGPS || haversineKm(p.lat, p.lon, homeLat, homeLon) > tripHomeRadius {
+					trips[i].photos = append(trips[i].photos, p)
+				}
+				break
+			}
+		}
+	}
+	for i := range trips {
+		trips[i].name = tripName(trips[i])
+	}
+	return trips
+}
+
+// tripHomePosition is --home or the centre of the grid cell (about 50 km)
+// holding the most photos.
+func tripHomePosition(photos []geoPhoto) (float64, float64, bool) {
+	if len(tripHome) == 2 {
+		return tripHome[0], tripHome[1], true
+	}
+	type cell struct{ lat, lon float64 }
+	counts := map[cell]int{}
+	var best cell
+	for _, p := range photos {
+		if !p.hasGPS {
+			continue
+		}
+		c := cell{math.Round(p.lat*2) / 2, math.Round(p.lon*2) / 2}
+		counts[c]++
+		if counts[c] > counts[best] {
+			best = c
+		}
+	}
+	return best.lat, best.lon, counts[best] > 0
+}
+
+// tripName lists the two places with the most photos, then the month.
+func tripName(t trip) string {
+	counts := map[string]int{}
+	var places []string
+	for _, p := range t.photos {
+		if !p.hasGPS {
+			continue
+		}
+		place := photoPlace(p.path)
+		if place == "" {
+			continue
+		}
+		if counts[place] == 0 {
+			places = append(places, place)
+		}
+		counts[place]++
+	}
+	sort.SliceStable(places, func(i, j int) bool { return counts[places[i]] > counts[places[j]] })
+	if len(places) > 2 {
+		places = places[:2]
+	}
+	if len(places) == 0 {
+		places = []string{"trip"}
+	}
+
+	when := t.start.Format("January_2006")
+	if t.start.Format("2006-01") != t.end.Format("2006-01") {
+		if t.start.Year() == t.end.Year() {
+			when = t.start.Format("January") + "_" + t.end.Format("January_2006")
+		} else {
+			when = t.start.Format("January_2006") + "_" + t.end.Format("January_2006")
+		}
+	}
+	return strings.ToLower(sanitizeFileName(strings.Join(places, "_") + "_" + when))
+}
+
+func reportTrip(dir string, t trip) error {
+	fmt.Printf("%s: %d photos, %s to %s\n", t.name, len(t.photos),
+		t.start.Format("2006-01-02"), t.end.Format("2006-01-02"))
+	if !tripOrganize {
+		return nil
+	}
+
+	target := filepath.Join(dir, t.name)
+	if !confirm(fmt.Sprintf("Do you want to move them into %s?", target)) {
+		return nil
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	moved := 0
+	for _, p := range t.photos {
+		newPath := filepath.Join(target, filepath.Base(p.path))
+		if _, err := os.Stat(newPath); err == nil {
+			log.Printf("Not moving %s: %s already exists", p.path, newPath)
+			continue
+		}
+		if err := renameRetrying(p.path, newPath); err != nil {
+			log.Printf("Failed to move %s: %v", p.path, err)
+			continue
+		}
+		audit("move", absPath(p.path), absPath(newPath), "")
+		journal(p.path, newPath, "", nil)
+		moved++
+	}
+	fmt.Printf("Moved %d of %d photos into %s\n", moved, len(t.photos), target)
+	return nil
+}
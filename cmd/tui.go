@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
+	"github.com/mattn/go-runewidth"
+)
+
+var noTUI bool
+
+func init() {
+	rootCmd.Flags().BoolVar(&noTUI, "no-tui", false, "ask about one file at a time instead of listing them all in a full-screen view")
+}
+
+// useTUI reports whether the suggestions for args are reviewed in the
+// full-screen list. Anything that decides without asking, or a stdin or
+// stdout that is not a terminal, keeps the line-by-line prompts.
+func useTUI(args []string) bool {
+	if noTUI || assumeYes || noInput || dryRun || triageMode || outputFormat != "text" {
+		return false
+	}
+	for _, arg := range args {
+		if isRemoteURL(arg) {
+			return false
+		}
+	}
+	return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// tuiRow is one file in the list and what has been decided about it: ""
+// for nothing yet, "y", "n" or "d" as at the prompt, or "x" to delete an
+// accidental capture.
+type tuiRow struct {
+	s        suggestion
+	decision string
+}
+
+// rowMsg delivers a suggestion as soon as it is ready, so the list fills
+// in while the rest are still being described.
+type rowMsg suggestion
+
+type walkDoneMsg struct{}
+
+type tuiModel struct {
+	rows          []tuiRow
+	cursor        int
+	offset        int
+	width, height int
+	loading       bool
+	editing       bool
+	input         textinput.Model
+	apply         bool
+}
+
+var (
+	tuiCursorStyle = lipgloss.NewStyle().Reverse(true)
+	tuiDimStyle    = lipgloss.NewStyle().Faint(true)
+)
+
+func newTUIModel() tuiModel {
+	input := textinput.New()
+	input.Prompt = "New name: "
+	return tuiModel{loading: true, input: input, width: 80, height: 24}
+}
+
+func (m tuiModel) Init() tea.Cmd { return nil }
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.move(0)
+	case rowMsg:
+		m.rows = append(m.rows, tuiRow{s: suggestion(msg)})
+	case walkDoneMsg:
+		m.loading = false
+	case tea.KeyMsg:
+		if m.editing {
+			return m.updateEdit(msg)
+		}
+		switch msg.String() {
+		case "up", "k":
+			m.move(-1)
+		case "down", "j":
+			m.move(1)
+		case "pgup":
+			m.move(-m.listHeight())
+		case "pgdown":
+			m.move(m.listHeight())
+		case "y", " ":
+			m.decide("y")
+		case "n":
+			m.decide("n")
+		case "d":
+			m.decide("d")
+		case "x":
+			if m.cursor < len(m.rows) && m.rows[m.cursor].s.junk != "" {
+				m.rows[m.cursor].decision = "x"
+				m.move(1)
+			}
+		case "tab":
+			if m.cursor < len(m.rows) && len(m.rows[m.cursor].s.alternatives) > 0 {
+				m.rows[m.cursor].s.cycle()
+			}
+		case "e":
+			if m.cursor < len(m.rows) && m.rows[m.cursor].s.err == nil && m.rows[m.cursor].s.junk == "" {
+				m.editing = true
+				m.input.SetValue(m.rows[m.cursor].s.name)
+				m.input.CursorEnd()
+				return m, m.input.Focus()
+			}
+		case "A":
+			for i := range m.rows {
+				if m.rows[i].s.err == nil && m.rows[i].s.junk == "" && m.rows[i].decision == "" {
+					m.rows[i].decision = "y"
+				}
+			}
+		case "a":
+			m.apply = true
+			return m, tea.Quit
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m tuiModel) updateEdit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if name := sanitizeFileName(strings.TrimSpace(m.input.Value())); name != "" {
+			m.rows[m.cursor].s.name = name
+			m.rows[m.cursor].decision = "y"
+		}
+		m.editing = false
+		m.input.Blur()
+		return m, nil
+	case "esc":
+		m.editing = false
+		m.input.Blur()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// move moves the cursor, scrolling the list to keep it in view.
+func (m *tuiModel) move(by int) {
+	m.cursor = max(0, min(len(m.rows)-1, m.cursor+by))
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if height := m.listHeight(); m.cursor >= m.offset+height {
+		m.offset = m.cursor - height + 1
+	}
+}
+
+// decide records d for the current row and moves on to the next one.
+func (m *tuiModel) decide(d string) {
+	if m.cursor >= len(m.rows) || m.rows[m.cursor].s.err != nil || m.rows[m.cursor].s.junk != "" {
+		return
+	}
+	m.rows[m.cursor].decision = d
+	m.move(1)
+}
+
+// listHeight is how many rows fit between the header and the footer.
+func (m tuiModel) listHeight() int {
+	return max(1, m.height-5)
+}
+
+func (m tuiModel) View() string {
+	var b strings.Builder
+	status := fmt.Sprintf("%d files", len(m.rows))
+	if m.loading {
+		status += ", still looking"
+	}
+	b.WriteString(runewidth.Truncate("tell-me-more: "+status, m.width, "…") + "\n\n")
+
+	height, offset := m.listHeight(), m.offset
+	for i := offset; i < len(m.rows) && i < offset+height; i++ {
+		line := runewidth.Truncate(rowLine(m.rows[i]), m.width, "…")
+		if i == m.cursor {
+			line = tuiCursorStyle.Render(runewidth.FillRight(line, m.width))
+		}
+		b.WriteString(line + "\n")
+	}
+	for i := len(m.rows) - offset; i < height; i++ {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	switch {
+	case m.editing:
+		b.WriteString(m.input.View() + "\n")
+		b.WriteString(tuiDimStyle.Render("enter save and accept · esc cancel"))
+	default:
+		detail := ""
+		if m.cursor < len(m.rows) {
+			r := m.rows[m.cursor]
+			detail = r.s.description
+			if r.s.err != nil {
+				detail = "Error: " + r.s.err.Error()
+			} else if r.s.junk != "" {
+				detail = "Looks like an accidental capture: " + r.s.junk + " (x to delete it)"
+			}
+		}
+		b.WriteString(runewidth.Truncate(detail, m.width, "…") + "\n")
+		b.WriteString(tuiDimStyle.Render(runewidth.Truncate("↑/↓ move · y accept · n reject · d decide later · x delete junk · tab next name · e edit · A accept the rest · a apply · q quit", m.width, "…")))
+	}
+	return b.String()
+}
+
+func rowLine(r tuiRow) string {
+	if r.s.err != nil {
+		return fmt.Sprintf("[!] %s", r.s.path)
+	}
+	if r.s.junk != "" {
+		mark := "~"
+		if r.decision == "x" {
+			mark = "🗑"
+		}
+		return fmt.Sprintf("[%s] %s (%s)", mark, r.s.path, r.s.junk)
+	}
+	mark := " "
+	switch r.decision {
+	case "y":
+		mark = "✓"
+	case "n":
+		mark = "✗"
+	case "d":
+		mark = "…"
+	}
+	line := fmt.Sprintf("[%s] %s → %s", mark, r.s.path, sanitizeFileName(r.s.name))
+	if n := len(r.s.alternatives); n > 0 {
+		line += fmt.Sprintf("  (tab: %d more)", n)
+	}
+	return line
+}
+
+// runTUI lists every suggestion for args in a full-screen view and, once
+// "apply" is chosen, carries out the decisions made there. Log output is
+// held back until the screen is restored.
+func runTUI(args []string) error {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	p := tea.NewProgram(newTUIModel(), tea.WithAltScreen())
+	done := make(chan struct{})
+	go func() {
+		forEachTarget(args, func(s suggestion) {
+			select {
+			case <-done:
+				select {} // the list is closed; stop walking
+			default:
+				p.Send(rowMsg(s))
+			}
+		})
+		p.Send(walkDoneMsg{})
+	}()
+	final, err := p.Run()
+	close(done)
+	log.SetOutput(os.Stderr)
+	os.Stderr.Write(logs.Bytes())
+	if err != nil {
+		return err
+	}
+
+	m := final.(tuiModel)
+	if !m.apply {
+		fmt.Fprintln(msgOut, "Quit without renaming anything")
+		return nil
+	}
+	for _, r := range m.rows {
+		rec := fileRecord{Path: r.s.path, Description: r.s.description, Action: actionSkipped}
+		switch {
+		case r.s.err != nil:
+			rec.Action, rec.Error = actionError, r.s.err.Error()
+		case r.s.junk != "":
+			rec.Junk = r.s.junk
+			if r.decision == "x" || junkPolicy == "delete" {
+				deleteJunk(r.s.path, &rec)
+			}
+		default:
+			rec.Name = sanitizeFileName(r.s.name)
+			rec.Tags, rec.Category = r.s.tags, r.s.category
+			rec = applyDecision(r.s, r.decision, rec)
+		}
+		emitRecord(rec)
+		if r.decision != "" && rec.Action != actionError {
+			markDone(r.s.path)
+		}
+	}
+	finishRun()
+	return nil
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	// resumableThreshold is the size above which files go through the
+	// resumable protocol instead of a single-shot upload.
+	resumableThreshold = 20 << 20
+	// inlineImageLimit is the largest image sent inline with the request
+	// instead of through the Files API. Requests are capped at 20MB, and
+	// base64 makes the image a third bigger.
+	inlineImageLimit = 14 << 20
+	// uploadChunkSize must be a multiple of the 256 KiB upload granularity.
+	uploadChunkSize = 8 << 20
+	uploadRetries   = 5
+	uploadStateFile = "uploads.json"
+
+	geminiUploadURL = "https://generativelanguage.googleapis.com/upload/v1beta/files"
+)
+
+// uploadSession is persisted so an interrupted upload can pick up where it
+// stopped on the next run.
+type uploadSession struct {
+	URL     string    `json:"url"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+type uploadedFile struct {
+	Name string `json:"name"`
+}
+
+// uploadClient sends the chunks. It has no overall timeout, as
+// httpClient does, since under --max-upload-rate a chunk can take longer
+// than that; each chunk gets a deadline of its own instead.
+var uploadClient = &http.Client{}
+
+// chunkTimeout is how long sending size bytes may take: what
+// --max-upload-rate holds it to, plus httpClient's timeout for everything
+// else.
+func chunkTimeout(size int) time.Duration {
+	timeout := httpClient.Timeout
+	if maxUploadRate > 0 {
+		timeout += time.Duration(float64(size) / float64(maxUploadRate) * float64(time.Second))
+	}
+	return timeout
+}
+
+// uploadResumable sends path to the Gemini Files API in chunks, reporting
+// progress on stderr and resuming a previous session for the same file
+// when one exists. It returns the service name of the uploaded file.
+func uploadResumable(ctx context.Context, apiKey, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	session, ok, err := loadUploadSession(key)
+	if err != nil {
+		return "", err
+	}
+
+	var offset int64
+	if ok && session.Size == info.Size() && session.ModTime.Equal(info.ModTime()) {
+		if offset, err = queryUpload(ctx, session.URL); err != nil {
+			log.Printf("Cannot resume upload of %s, starting over: %v", path, err)
+			ok = false
+		} else {
+			log.Printf("Resuming upload of %s at %d%%", path, offset*100/info.Size())
+		}
+	} else {
+		ok = false
+	}
+	if !ok {
+		url, err := startUpload(ctx, apiKey, path, info.Size())
+		if err != nil {
+			return "", err
+		}
+		session = uploadSession{URL: url, Size: info.Size(), ModTime: info.ModTime()}
+		if err := saveUploadSession(key, &session); err != nil {
+			log.Printf("Error saving upload session: %v", err)
+		}
+		offset = 0
+	}
+
+	buf := make([]byte, uploadChunkSize)
+	failures := 0
+	for {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		last := offset+int64(n) >= info.Size()
+		result, err := uploadChunk(ctx, session.URL, buf[:n], offset, last)
+		if err != nil {
+			failures++
+			if failures > uploadRetries || ctx.Err() != nil {
+				return "", fmt.Errorf("uploading %s: %v", path, err)
+			}
+			time.Sleep(time.Duration(failures) * time.Second)
+			if offset, err = queryUpload(ctx, session.URL); err != nil {
+				return "", fmt.Errorf("uploading %s: %v", path, err)
+			}
+			continue
+		}
+		failures = 0
+		offset += int64(n)
+		fmt.Fprintf(os.Stderr, "\rUploading %s: %3d%% (%d/%d MB)", filepath.Base(path),
+			offset*100/info.Size(), offset>>20, info.Size()>>20)
+		if last {
+			fmt.Fprintln(os.Stderr)
+			if err := saveUploadSession(key, nil); err != nil {
+				log.Printf("Error saving upload session: %v", err)
+			}
+			return result.Name, nil
+		}
+	}
+}
+
+// uploadSessionsMu serialises changes to uploads.json, which concurrent
+// uploads would otherwise overwrite each other's sessions in.
+var uploadSessionsMu sync.Mutex
+
+func loadUploadSession(key string) (uploadSession, bool, error) {
+	uploadSessionsMu.Lock()
+	defer uploadSessionsMu.Unlock()
+	var sessions map[string]uploadSession
+	if err := loadState(uploadStateFile, &sessions); err != nil {
+		return uploadSession{}, false, err
+	}
+	session, ok := sessions[key]
+	return session, ok, nil
+}
+
+// saveUploadSession records session as the upload of key, or forgets the
+// upload when session is nil.
+func saveUploadSession(key string, session *uploadSession) error {
+	uploadSessionsMu.Lock()
+	defer uploadSessionsMu.Unlock()
+	var sessions map[string]uploadSession
+	if err := loadState(uploadStateFile, &sessions); err != nil {
+		return err
+	}
+	if sessions == nil {
+		sessions = map[string]uploadSession{}
+	}
+	if session == nil {
+		delete(sessions, key)
+	} else {
+		sessions[key] = *session
+	}
+	return saveState(uploadStateFile, sessions)
+}
+
+func startUpload(ctx context.Context, apiKey, path string, size int64) (string, error) {
+	meta, _ := json.Marshal(map[string]any{"file": map[string]string{"display_name": filepath.Base(path)}})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, geminiUploadURL, bytes.NewReader(meta))
+	if err != nil {
+		return "", err
+	}
+	// In a header rather than the query, where errors would print it.
+	req.Header.Set("x-goog-api-key", apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.FormatInt(size, 10))
+	if mt := mime.TypeByExtension(filepath.Ext(path)); mt != "" {
+		req.Header.Set("X-Goog-Upload-Header-Content-Type", mt)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("starting upload: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	url := resp.Header.Get("X-Goog-Upload-URL")
+	if url == "" {
+		return "", fmt.Errorf("starting upload: no upload URL in response")
+	}
+	return url, nil
+}
+
+// queryUpload asks the server how many bytes of the session it has.
+func queryUpload(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Goog-Upload-Command", "query")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("querying upload: %s", resp.Status)
+	}
+	if status := resp.Header.Get("X-Goog-Upload-Status"); status != "active" {
+		return 0, fmt.Errorf("upload session is %q", status)
+	}
+	return strconv.ParseInt(resp.Header.Get("X-Goog-Upload-Size-Received"), 10, 64)
+}
+
+func uploadChunk(ctx context.Context, url string, chunk []byte, offset int64, last bool) (uploadedFile, error) {
+	var result struct {
+		File uploadedFile `json:"file"`
+	}
+	ctx, cancel := context.WithTimeout(ctx, chunkTimeout(len(chunk)))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, throttled(bytes.NewReader(chunk)))
+	if err != nil {
+		return result.File, err
+	}
+	req.ContentLength = int64(len(chunk))
+	command := "upload"
+	if last {
+		command = "upload, finalize"
+	}
+	req.Header.Set("X-Goog-Upload-Command", command)
+	req.Header.Set("X-Goog-Upload-Offset", strconv.FormatInt(offset, 10))
+
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return result.File, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return result.File, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if last {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result.File, err
+		}
+	}
+	return result.File, nil
+}
+
+// waitForFile polls an uploaded file until the service has finished
+// processing it; large files such as recordings are not usable right away.
+func waitForFile(ctx context.Context, client *genai.Client, name string) (*genai.File, error) {
+	for {
+		file, err := client.GetFile(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		switch file.State {
+		case genai.FileStateProcessing:
+		case genai.FileStateFailed:
+			return nil, fmt.Errorf("processing of %s failed", name)
+		default:
+			return file, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChunkTimeout(t *testing.T) {
+	defer func(rate byteSize) { maxUploadRate = rate }(maxUploadRate)
+	tests := []struct {
+		rate byteSize
+		size int
+		want time.Duration
+	}{
+		{0, uploadChunkSize, httpClient.Timeout},
+		{1 << 20, uploadChunkSize, httpClient.Timeout + 8*time.Second},
+		{64_000, uploadChunkSize, httpClient.Timeout + 8<<20*time.Second/64_000},
+		{1 << 20, 512 << 10, httpClient.Timeout + 500*time.Millisecond},
+	}
+	for _, tt := range tests {
+		maxUploadRate = tt.rate
+		if got := chunkTimeout(tt.size); got != tt.want {
+			t.Errorf("chunkTimeout(%d) at %d B/s = %v, want %v", tt.size, tt.rate, got, tt.want)
+		}
+	}
+}
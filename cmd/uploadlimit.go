@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	maxUploadRate byteSize
+	maxUploadSize byteSize
+	// maxUploadEdge is the longest side, in pixels, an image is sent at;
+	// 0 sends images at their full size.
+	maxUploadEdge int
+
+	uploadLimiter rateLimiter
+)
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&maxUploadEdge, "max-edge", 1536, "downscale images longer than this many pixels on their long side to a JPEG before uploading (0 to send them full size)")
+	rootCmd.PersistentFlags().Var(&maxUploadRate, "max-upload-rate", "limit upload bandwidth, e.g. 2MB/s (unlimited by default)")
+	rootCmd.PersistentFlags().Var(&maxUploadSize, "max-upload-size", "never upload more than this per file, e.g. 5MB; larger images are downscaled")
+}
+
+// byteSize is a flag value like "500KB", "2MB", "1.5GiB" or "2MB/s".
+type byteSize int64
+
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gib": 1 << 30,
+}
+
+func (b *byteSize) Set(s string) error {
+	text := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+	i := strings.IndexFunc(text, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(text)
+	}
+	n, err := strconv.ParseFloat(text[:i], 64)
+	unit, ok := byteUnits[strings.TrimSpace(text[i:])]
+	if err != nil || !ok || n < 0 {
+		return fmt.Errorf("invalid size %q (want e.g. 500KB or 2MB)", s)
+	}
+	*b = byteSize(n * unit)
+	return nil
+}
+
+func (b *byteSize) String() string {
+	if *b == 0 {
+		return ""
+	}
+	return formatBytes(int64(*b))
+}
+
+func (b *byteSize) Type() string { return "size" }
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fGB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fMB", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fKB", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// rateLimiter spreads uploads over time so that, together, they stay
+// under --max-upload-rate. It is shared by every upload in flight.
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until n more bytes may be sent.
+func (l *rateLimiter) wait(n int) {
+	if maxUploadRate <= 0 || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(time.Duration(float64(n) / float64(maxUploadRate) * float64(time.Second)))
+	l.mu.Unlock()
+	time.Sleep(time.Until(start))
+}
+
+// throttledReader paces reads of an upload body through uploadLimiter.
+type throttledReader struct {
+	r io.Reader
+}
+
+// throttleChunk keeps the pacing smooth instead of sending a large read in
+// one burst and then pausing.
+const throttleChunk = 32 << 10
+
+func (t throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	uploadLimiter.wait(len(p))
+	return t.r.Read(p)
+}
+
+// throttled wraps an upload body in the bandwidth limit, if there is one.
+func throttled(r io.Reader) io.Reader {
+	if maxUploadRate <= 0 {
+		return r
+	}
+	return throttledReader{r}
+}
+
+// prepareUpload returns the file to upload in place of path: path itself,
+// or a smaller JPEG copy that the returned cleanup removes, when path is
+// over --max-upload-size or --max-edge or --detail asks for less. HEIC
+// images are always sent as a JPEG copy, since not every provider takes
+// them. Files that are not images can't be made smaller and are refused
+// when over the size cap.
+func prepareUpload(path string) (string, func(), error) {
+	noop := func() {}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", noop, err
+	}
+	overCap := maxUploadSize > 0 && info.Size() > int64(maxUploadSize)
+	convert := isHEICFile(path)
+	if !isImageFile(path) || (!convert && !overCap && detail == "high" && !fileOverMaxEdge(path)) {
+		if overCap {
+			return "", noop, fmt.Errorf("%s is %s, over --max-upload-size %s", path, formatBytes(info.Size()), maxUploadSize.String())
+		}
+		return path, noop, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", noop, err
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		if overCap {
+			return "", noop, fmt.Errorf("%s is %s, over --max-upload-size %s, and cannot be downscaled: %v", path, formatBytes(info.Size()), maxUploadSize.String(), err)
+		}
+		if convert {
+			return "", noop, err
+		}
+		return path, noop, nil
+	}
+	data, changed, err := shrinkForUpload(img, overCap)
+	if err != nil {
+		return path, noop, err
+	}
+	if !changed {
+		if !convert {
+			return path, noop, nil
+		}
+		// Small enough as it is: send the converter's JPEG, which decoding
+		// it just made.
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return "", noop, err
+		}
+		if data, err = heicToJPEG(raw); err != nil {
+			return "", noop, err
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "tell-me-more-*"+".jpg")
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", noop, err
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	if changed {
+		log.Printf("Downscaled %s from %s to %s for upload", filepath.Base(path), formatBytes(info.Size()), formatBytes(int64(len(data))))
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// fitImageBytes is prepareUpload for an image already in memory.
+func fitImageBytes(data []byte, mimeType string) ([]byte, string, error) {
+	overCap := maxUploadSize > 0 && int64(len(data)) > int64(maxUploadSize)
+	convert := mimeType == "image/heic" || mimeType == "image/heif"
+	if !convert && !overCap && detail == "high" && !overMaxEdge(bytes.NewReader(data)) {
+		return data, mimeType, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if convert {
+			return nil, "", err
+		}
+		if overCap {
+			return nil, "", fmt.Errorf("image is %s, over --max-upload-size %s, and cannot be downscaled: %v", formatBytes(int64(len(data))), maxUploadSize.String(), err)
+		}
+		return data, mimeType, nil
+	}
+	small, changed, err := shrinkForUpload(img, overCap)
+	if err != nil {
+		return data, mimeType, err
+	}
+	if !changed {
+		if !convert {
+			return data, mimeType, nil
+		}
+		if small, err = heicToJPEG(data); err != nil {
+			return nil, "", err
+		}
+	}
+	return small, "image/jpeg", nil
+}
+
+// overMaxEdge reports whether the image r holds is longer than --max-edge,
+// reading only its header. Images it can't read are left alone.
+func overMaxEdge(r io.Reader) bool {
+	if maxUploadEdge <= 0 {
+		return false
+	}
+	cfg, _, err := image.DecodeConfig(r)
+	return err == nil && max(cfg.Width, cfg.Height) > maxUploadEdge
+}
+
+func fileOverMaxEdge(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return overMaxEdge(f)
+}
+
+// shrinkForUpload applies --max-edge, --detail and, when the original is
+// over the cap, --max-upload-size to img. It reports false when the
+// original can be sent as it is.
+func shrinkForUpload(img image.Image, overCap bool) ([]byte, bool, error) {
+	reduced := false
+	edge := maxUploadEdge
+	if lowDetail(img) && (edge <= 0 || edge > lowDetailSize) {
+		edge = lowDetailSize
+	}
+	if b := img.Bounds(); edge > 0 {
+		if long := max(b.Dx(), b.Dy()); long > edge {
+			img = resizeImage(img, b.Dx()*edge/long, b.Dy()*edge/long)
+			reduced = true
+		}
+	}
+	if !reduced && !overCap {
+		return nil, false, nil
+	}
+	limit := int64(math.MaxInt64)
+	if maxUploadSize > 0 {
+		limit = int64(maxUploadSize)
+	}
+	data, err := downscaleToFit(img, limit)
+	return data, err == nil, err
+}
+
+// resizeImage scales img to w×h, flattened onto white since the result
+// ends up as a JPEG, which has no transparency.
+func resizeImage(img image.Image, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// downscaleToFit re-encodes img as a JPEG no larger than max bytes. It
+// first tries the full resolution, which is often enough for PNGs, and
+// then shrinks the area by how far each attempt was over.
+func downscaleToFit(img image.Image, max int64) ([]byte, error) {
+	bounds := img.Bounds()
+	scale := 1.0
+	for i := 0; i < 10; i++ {
+		w := int(float64(bounds.Dx()) * scale)
+		h := int(float64(bounds.Dy()) * scale)
+		if w < 16 || h < 16 {
+			break
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resizeImage(img, w, h), &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		if int64(buf.Len()) <= max {
+			return buf.Bytes(), nil
+		}
+		scale *= math.Min(0.9, 0.95*math.Sqrt(float64(max)/float64(buf.Len())))
+	}
+	return nil, fmt.Errorf("cannot downscale the image below %s", formatBytes(max))
+}
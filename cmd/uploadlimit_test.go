@@ -0,0 +1,39 @@
+package cmd
+
+import "testing"
+
+func TestByteSizeSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    byteSize
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"500KB", 500_000, false},
+		{"500kb", 500_000, false},
+		{"2MB", 2_000_000, false},
+		{"1.5GiB", 1.5 * (1 << 30), false},
+		{"1MiB", 1 << 20, false},
+		{"10k", 10_000, false},
+		{"2MB/s", 2_000_000, false},
+		{" 3 MB ", 3_000_000, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"5TB", 0, true},
+		{"-1MB", 0, true},
+		{"1.2.3MB", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var got byteSize
+			err := got.Set(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Set(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
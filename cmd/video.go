@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	videoFrames int
+	// videoSegment is --video-segment: how long a stretch of a long video
+	// one contact sheet covers.
+	videoSegment time.Duration
+)
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&videoFrames, "video-frames", 4, "how many evenly spaced frames of a video (or of each segment of a long one) are looked at to describe it")
+	rootCmd.PersistentFlags().DurationVar(&videoSegment, "video-segment", 5*time.Minute, "describe videos longer than this in segments of about this length and merge the descriptions; 0 always uses one contact sheet")
+}
+
+var videoExts = map[string]bool{
+	".mp4":  true,
+	".mov":  true,
+	".m4v":  true,
+	".mkv":  true,
+	".avi":  true,
+	".webm": true,
+}
+
+// isVideoFile reports whether path has a video extension. WebM is also
+// how browsers record audio, so isVideo looks inside those.
+func isVideoFile(path string) bool {
+	return videoExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// isVideo reports whether path is a video to describe by its frames
+// rather than a recording to transcribe.
+func isVideo(path string) bool {
+	if !isVideoFile(path) {
+		return false
+	}
+	if !isAudioFile(path) {
+		return true
+	}
+	out, err := exec.CommandContext(runCtx, "ffprobe", "-v", "error", "-select_streams", "v", "-show_entries", "stream=codec_type", "-of", "csv=p=0", path).Output()
+	return err == nil && strings.Contains(string(out), "video")
+}
+
+const (
+	// videoFrameWidth is the width each frame is scaled to on the contact
+	// sheet, so a sheet of four stays within what the APIs take whole.
+	videoFrameWidth = 640
+	// videoSheetColumns is how many frames are side by side on the sheet.
+	videoSheetColumns = 2
+	// videoMaxSegments caps the requests a long video takes; past it the
+	// segments get longer instead.
+	videoMaxSegments = 12
+)
+
+// describeVideo describes a video from a contact sheet of videoFrames
+// frames spread evenly through it, in one request. Videos longer than
+// --video-segment go to describeLongVideo instead.
+func describeVideo(path string) (string, error) {
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return "", fmt.Errorf("describing videos needs ffmpeg and ffprobe (https://ffmpeg.org) on the PATH")
+		}
+	}
+	duration, err := videoDuration(path)
+	if err != nil {
+		return "", err
+	}
+	if videoSegment > 0 && duration > videoSegment.Seconds() {
+		return describeLongVideo(path, duration)
+	}
+	sheet, n, err := videoSheet(path, 0, duration)
+	if err != nil {
+		return "", err
+	}
+	prompt := fmt.Sprintf("These are %d frames taken evenly from a %s video, in order from left to right and top to bottom. Describe what the video shows and what happens in it, in as much detail as possible, including any text that matters. Describe the video, not the grid of frames.", n, formatDuration(duration)) + langPrompt()
+	return askGeminiAboutImage(sheet, "image/jpeg", prompt)
+}
+
+// describeLongVideo describes each segment of a long recording from its
+// own contact sheet, then merges the descriptions into one, so an hour of
+// meeting is seen as closely as a minute of screen recording without a
+// request too big for the model.
+func describeLongVideo(path string, duration float64) (string, error) {
+	segments := min(int(math.Ceil(duration/videoSegment.Seconds())), videoMaxSegments)
+	length := duration / float64(segments)
+	var parts strings.Builder
+	for i := 0; i < segments; i++ {
+		from, to := float64(i)*length, float64(i+1)*length
+		sheet, n, err := videoSheet(path, from, to)
+		if err != nil {
+			return "", err
+		}
+		prompt := fmt.Sprintf("These are %d frames taken evenly from %s to %s of a %s video, in order from left to right and top to bottom. Describe what this part of the video shows and what happens in it, in as much detail as possible, including any text that matters such as titles, slides, names and window contents. Describe the video, not the grid of frames.", n, formatTimestamp(from), formatTimestamp(to), formatDuration(duration)) + langPrompt()
+		part, err := askGeminiAboutImage(sheet, "image/jpeg", prompt)
+		if err != nil {
+			return "", fmt.Errorf("describing %s to %s of %s: %v", formatTimestamp(from), formatTimestamp(to), path, err)
+		}
+		fmt.Fprintf(&parts, "Part %d (%s to %s): %s\n\n", i+1, formatTimestamp(from), formatTimestamp(to), strings.TrimSpace(part))
+	}
+	prompt := fmt.Sprintf(`These are descriptions of the consecutive parts of a %s video, in order.
+
+%s
+Merge them into one description of the whole video: what kind of video it is (a meeting, a talk, a tutorial, a screen recording of some app), what it is about and what happens from start to end, and any titles, names or other text that identify it. Describe the video as a whole rather than part by part.`, formatDuration(duration), parts.String()) + langPrompt()
+	return askChatGPT(prompt)
+}
+
+// videoSheet is the contact sheet of videoFrames frames spread evenly from
+// from to to seconds into the video, with how many frames it holds.
+func videoSheet(path string, from, to float64) ([]byte, int, error) {
+	n := max(1, videoFrames)
+	var frames []image.Image
+	for i := 0; i < n; i++ {
+		at := from + (to-from)*(float64(i)+0.5)/float64(n)
+		frame, err := videoFrame(path, at)
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading the frame at %.1fs of %s: %v", at, path, err)
+		}
+		frames = append(frames, frame)
+	}
+	sheet, err := contactSheet(frames)
+	return sheet, n, err
+}
+
+func videoDuration(path string) (float64, error) {
+	out, err := exec.CommandContext(runCtx, "ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("reading the length of %s: %v", path, err)
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s has no length ffprobe can tell", path)
+	}
+	return d, nil
+}
+
+// videoFrame decodes the frame at seconds into the video. Seeking before
+// the input lands on the nearest keyframe, which is quick even deep into
+// long recordings.
+func videoFrame(path string, seconds float64) (image.Image, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "ffmpeg", "-v", "error", "-ss", strconv.FormatFloat(seconds, 'f', 3, 64), "-i", path,
+		"-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "-")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	img, _, err := image.Decode(bytes.NewReader(out))
+	return img, err
+}
+
+// contactSheet lays frames out in a grid, each scaled to videoFrameWidth,
+// and encodes it as a JPEG.
+func contactSheet(frames []image.Image) ([]byte, error) {
+	b := frames[0].Bounds()
+	w := videoFrameWidth
+	h := max(1, b.Dy()*w/max(1, b.Dx()))
+	cols := min(videoSheetColumns, len(frames))
+	rows := (len(frames) + cols - 1) / cols
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*w, rows*h))
+	for i, f := range frames {
+		cell := image.Rect((i%cols)*w, (i/cols)*h, (i%cols+1)*w, (i/cols+1)*h)
+		draw.ApproxBiLinear.Scale(sheet, cell, f, f.Bounds(), draw.Src, nil)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatTimestamp writes seconds into a video as 4:05 or 1:02:03.
+func formatTimestamp(seconds float64) string {
+	t := int(seconds + 0.5)
+	if t >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", t/3600, t/60%60, t%60)
+	}
+	return fmt.Sprintf("%d:%02d", t/60, t%60)
+}
+
+// formatDuration says how long a video is, such as "45-second" or
+// "3-minute".
+func formatDuration(seconds float64) string {
+	if seconds < 90 {
+		return fmt.Sprintf("%.0f-second", max(1, seconds))
+	}
+	return fmt.Sprintf("%.0f-minute", seconds/60)
+}
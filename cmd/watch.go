@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchQuiet     time.Duration
+	watchRecursive bool
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <dir>...",
+	Short: "Rename new screenshots and recordings as they appear",
+	Long: `Keeps running and handles every matching file created in the given directories,
+once it has stopped changing for --quiet. Combine with --yes to rename without
+asking, e.g. tell-me-more watch ~/Desktop --yes`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		args, err := prepareRun(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		for _, dir := range args {
+			if err := watchTree(w, dir); err != nil {
+				return err
+			}
+			fmt.Fprintf(msgOut, "Watching %s\n", dir)
+		}
+
+		ready := make(chan string)
+		d := debouncer{timers: map[string]*time.Timer{}, ready: ready, own: map[string]time.Time{}}
+		go func() {
+			for {
+				select {
+				case ev, ok := <-w.Events:
+					if !ok {
+						return
+					}
+					handleWatchEvent(w, &d, ev)
+				case err, ok := <-w.Errors:
+					if !ok {
+						return
+					}
+					log.Printf("Watch error: %v", err)
+				}
+			}
+		}()
+
+		for {
+			var path string
+			select {
+			case path = <-ready:
+			case <-runCtx.Done():
+				// Stopped as a service.
+				return nil
+			}
+			if _, err := os.Stat(path); err != nil {
+				continue // renamed away or deleted while settling
+			}
+			if fileBusy(path) && !waitUntilReady(path) {
+				log.Printf("Skipping %s: still being written or locked", path)
+				continue
+			}
+			if rec := review(suggest(path)); rec.NewPath != "" {
+				d.ignore(rec.NewPath)
+			}
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchQuiet, "quiet", settleTime, "how long a new file must go unchanged before it is handled")
+	watchCmd.Flags().BoolVarP(&watchRecursive, "recursive", "r", false, "also watch subdirectories, including ones created later")
+	rootCmd.AddCommand(watchCmd)
+}
+
+// watchTree adds dir, and with --recursive every directory below it that
+// the walk would not skip.
+func watchTree(w *fsnotify.Watcher, dir string) error {
+	if !watchRecursive {
+		return w.Add(dir)
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if skipDir(dir, path) {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
+
+func handleWatchEvent(w *fsnotify.Watcher, d *debouncer, ev fsnotify.Event) {
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+		return
+	}
+	name := filepath.Base(ev.Name)
+	if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+		if watchRecursive && ev.Has(fsnotify.Create) {
+			if err := watchTree(w, ev.Name); err != nil {
+				log.Printf("Error watching %s: %v", ev.Name, err)
+			}
+		}
+		return
+	}
+	// macOS writes screenshots under a hidden name first and renames them
+	// once they are complete.
+	if strings.HasPrefix(name, ".") || !isTargetPath(ev.Name) {
+		return
+	}
+	d.touch(ev.Name)
+}
+
+// renamedEcho is how long the events for a file we renamed ourselves keep
+// being ignored.
+const renamedEcho = 10 * time.Second
+
+// debouncer delivers a path once no event has arrived for it in
+// watchQuiet, so a file being written is handled once, after the last write.
+type debouncer struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	ready  chan<- string
+	// own are the files we just renamed, whose new name may well still
+	// look like a screenshot.
+	own map[string]time.Time
+}
+
+func (d *debouncer) ignore(path string) {
+	d.mu.Lock()
+	d.own[path] = time.Now().Add(renamedEcho)
+	d.mu.Unlock()
+}
+
+func (d *debouncer) touch(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if until, ok := d.own[path]; ok {
+		if time.Now().Before(until) {
+			return
+		}
+		delete(d.own, path)
+	}
+	if t, ok := d.timers[path]; ok {
+		t.Reset(watchQuiet)
+		return
+	}
+	d.timers[path] = time.AfterFunc(watchQuiet, func() {
+		d.mu.Lock()
+		delete(d.timers, path)
+		d.mu.Unlock()
+		d.ready <- path
+	})
+}
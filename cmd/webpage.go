@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// webpagePrompt is appended to the describe prompt so browser screenshots
+// come back with the address bar and title in a form we can pick out.
+const webpagePrompt = `
+
+If the image is a screenshot of a web browser, start your answer with these two lines before anything else:
+URL: <the exact text of the address bar>
+Page title: <the tab or page title>`
+
+// browserContext pulls the "URL:" and "Page title:" lines requested by
+// webpagePrompt out of a description. The domain has any "www." removed.
+func browserContext(description string) (domain, title string) {
+	scanner := bufio.NewScanner(strings.NewReader(description))
+	for scanner.Scan() {
+		line := strings.Trim(strings.TrimSpace(scanner.Text()), "*")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), "*` ")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "url":
+			if domain == "" {
+				domain = urlDomain(value)
+			}
+		case "page title":
+			if title == "" {
+				title = value
+			}
+		}
+	}
+	return domain, title
+}
+
+func urlDomain(raw string) string {
+	if raw == "" || strings.EqualFold(raw, "none") || strings.EqualFold(raw, "n/a") {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !strings.Contains(u.Hostname(), ".") {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// webpageHint tells the naming model to build the name from the site and
+// page rather than from what the page happens to look like.
+func webpageHint(description string) string {
+	domain, title := browserContext(description)
+	if domain == "" {
+		return ""
+	}
+	page := domain
+	if title != "" {
+		page = fmt.Sprintf("%s (%q)", domain, title)
+	}
+	return fmt.Sprintf(`
+
+This is a screenshot of the web page %s. Prefer a name built from the site and the page, like 'github_actions_pricing_page', over a description of the scene.`, page)
+}
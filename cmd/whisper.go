@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// whisperMode is --whisper: where voice memos are transcribed.
+	whisperMode  = whisperFlag("auto")
+	whisperModel string
+)
+
+func init() {
+	rootCmd.PersistentFlags().Var(&whisperMode, "whisper", "where to transcribe audio: api (OpenAI), local (openai-whisper or whisper.cpp), or auto for the API when an OpenAI key is set and local otherwise")
+	rootCmd.PersistentFlags().StringVar(&whisperModel, "whisper-model", "base", "the local Whisper model: a name for openai-whisper (tiny, base, small, medium, large) or the path to a ggml model file for whisper.cpp")
+}
+
+type whisperFlag string
+
+func (f *whisperFlag) Set(s string) error {
+	switch s {
+	case "auto", "api", "local":
+		*f = whisperFlag(s)
+		return nil
+	}
+	return fmt.Errorf("unknown whisper mode %q (want auto, api or local)", s)
+}
+
+func (f *whisperFlag) String() string { return string(*f) }
+
+func (f *whisperFlag) Type() string { return "mode" }
+
+// whisperCppTools are the names whisper.cpp's command line goes by: the
+// current one, and the one Homebrew installed it as before.
+var whisperCppTools = []string{"whisper-cli", "whisper-cpp"}
+
+// usesWhisperCpp reports whether --whisper-model is a ggml model file,
+// which only whisper.cpp reads.
+func usesWhisperCpp() bool {
+	return strings.HasSuffix(whisperModel, ".bin")
+}
+
+// localWhisperTool is the local Whisper to run, or "" when none is on the
+// PATH.
+func localWhisperTool() string {
+	if !usesWhisperCpp() {
+		if _, err := exec.LookPath("whisper"); err == nil {
+			return "whisper"
+		}
+		return ""
+	}
+	for _, tool := range whisperCppTools {
+		if _, err := exec.LookPath(tool); err == nil {
+			return tool
+		}
+	}
+	return ""
+}
+
+// transcribesLocally reports whether audio goes to a local Whisper rather
+// than the API.
+func transcribesLocally() bool {
+	switch whisperMode {
+	case "local":
+		return true
+	case "api":
+		return false
+	}
+	openaiKeys.load()
+	return len(openaiKeys.keys) == 0 && localWhisperTool() != ""
+}
+
+// validateWhisper checks up front that --whisper local has a Whisper to run.
+func validateWhisper() error {
+	if whisperMode != "local" {
+		return nil
+	}
+	if usesWhisperCpp() {
+		if _, err := os.Stat(whisperModel); err != nil {
+			return fmt.Errorf("--whisper-model: %v", err)
+		}
+		if localWhisperTool() == "" {
+			return fmt.Errorf("--whisper-model %s needs whisper-cli from whisper.cpp (https://github.com/ggerganov/whisper.cpp) and ffmpeg on the PATH", whisperModel)
+		}
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			return fmt.Errorf("--whisper-model %s needs ffmpeg (https://ffmpeg.org) on the PATH", whisperModel)
+		}
+		return nil
+	}
+	if localWhisperTool() == "" {
+		return fmt.Errorf("--whisper local needs whisper from openai-whisper (pip install openai-whisper) on the PATH, or --whisper-model set to a whisper.cpp model file")
+	}
+	return nil
+}
+
+// whisperLanguage is the language to tell Whisper the audio is in, or ""
+// to let it work it out. Whisper only understands ISO 639-1 codes, so
+// "deu"-style ones are skipped.
+func whisperLanguage() string {
+	if len(textLangs) == 1 && len(textLangs[0]) == 2 {
+		return strings.ToLower(textLangs[0])
+	}
+	return ""
+}
+
+// transcribeLocally runs path through the local Whisper and returns the
+// text.
+func transcribeLocally(path string) (string, error) {
+	tool := localWhisperTool()
+	if tool == "" {
+		return "", fmt.Errorf("no local Whisper on the PATH to transcribe %s with", path)
+	}
+	dir, err := os.MkdirTemp("", "tell-me-more-whisper-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	var text string
+	if tool == "whisper" {
+		text, err = runOpenAIWhisper(path, dir)
+	} else {
+		text, err = runWhisperCpp(tool, path, dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("no speech found in %s", path)
+	}
+	return text, nil
+}
+
+// runOpenAIWhisper transcribes with openai-whisper, which reads any format
+// ffmpeg does and writes the text next to where it is told.
+func runOpenAIWhisper(path, dir string) (string, error) {
+	args := []string{path, "--model", whisperModel, "--output_format", "txt", "--output_dir", dir, "--verbose", "False", "--fp16", "False"}
+	if lang := whisperLanguage(); lang != "" {
+		args = append(args, "--language", lang)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "whisper", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("transcribing %s with whisper: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	out, err := os.ReadFile(filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".txt"))
+	if err != nil {
+		return "", fmt.Errorf("reading the transcript of %s: %v", path, err)
+	}
+	return string(out), nil
+}
+
+// runWhisperCpp transcribes with whisper.cpp, which wants 16kHz mono WAV,
+// so the recording is converted with ffmpeg first.
+func runWhisperCpp(tool, path, dir string) (string, error) {
+	wav := filepath.Join(dir, "audio.wav")
+	var stderr bytes.Buffer
+	convert := exec.CommandContext(runCtx, "ffmpeg", "-v", "error", "-i", path, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wav)
+	convert.Stderr = &stderr
+	if err := convert.Run(); err != nil {
+		return "", fmt.Errorf("converting %s for whisper.cpp: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	args := []string{"-m", whisperModel, "-f", wav, "-nt", "-np"}
+	if lang := whisperLanguage(); lang != "" {
+		args = append(args, "-l", lang)
+	}
+	stderr.Reset()
+	cmd := exec.CommandContext(runCtx, tool, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("transcribing %s with %s: %v: %s", path, tool, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
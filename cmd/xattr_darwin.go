@@ -0,0 +1,6 @@
+package cmd
+
+import "golang.org/x/sys/unix"
+
+// errNoXattr is what asking for a missing extended attribute fails with.
+const errNoXattr = unix.ENOATTR
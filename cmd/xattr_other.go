@@ -0,0 +1,19 @@
+//go:build !darwin && !linux
+
+package cmd
+
+import "errors"
+
+var errNoXattrs = errors.New("no extended attributes on this platform")
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errNoXattrs
+}
+
+func setXattr(path, name string, data []byte) error {
+	return errNoXattrs
+}
+
+func removeXattr(path, name string) error {
+	return errNoXattrs
+}
@@ -0,0 +1,38 @@
+//go:build darwin || linux
+
+package cmd
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// getXattr returns the extended attribute name of path, or nil when it
+// has none.
+func getXattr(path, name string) ([]byte, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	if errors.Is(err, errNoXattr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	size, err = unix.Getxattr(path, name, data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+func setXattr(path, name string, data []byte) error {
+	return unix.Setxattr(path, name, data, 0)
+}
+
+func removeXattr(path, name string) error {
+	if err := unix.Removexattr(path, name); err != nil && !errors.Is(err, errNoXattr) {
+		return err
+	}
+	return nil
+}